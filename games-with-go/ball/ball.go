@@ -1,10 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"gfx/capture"
+	"gfx/framebuffer"
+	"gfx/input"
+	"gfx/loop"
+	"gfx/platform"
+	"gfx/raster"
 )
 
 const (
@@ -18,6 +24,7 @@ const (
 
 type vertex struct {
 	x, y   float64
+	px, py float64 // position before the last update, drawing goes between
 	vx, vy float64
 	angle  float64
 	radius float64
@@ -37,7 +44,7 @@ func newBall(x, y int, r int) *ball {
 		x := float64(x) + float64(r)*math.Cos(angleRad)
 		y := float64(y) + float64(r)*math.Sin(angleRad)
 
-		verts[i] = vertex{x, y, 0, 0, angleRad, float64(r)}
+		verts[i] = vertex{x: x, y: y, px: x, py: y, angle: angleRad, radius: float64(r)}
 	}
 
 	return &ball{x, y, verts, -1}
@@ -47,6 +54,10 @@ func (b *ball) update(mouseX, mouseY int32, dragging bool) {
 	x := float64(b.x)
 	y := float64(b.y)
 
+	for i := range b.vertices {
+		b.vertices[i].px, b.vertices[i].py = b.vertices[i].x, b.vertices[i].y
+	}
+
 	for i := 0; i < numVerts; i++ {
 		v := &b.vertices[i]
 
@@ -99,13 +110,19 @@ func (b *ball) update(mouseX, mouseY int32, dragging bool) {
 	}
 }
 
-func (b *ball) draw(r *sdl.Renderer) {
+// draw outlines the ball alpha of the way from its previous update to its
+// latest
+func (b *ball) draw(fb *framebuffer.Buffer, alpha float64, c framebuffer.Color) {
+	at := func(v vertex) (int, int) {
+		return int(v.px + (v.x-v.px)*alpha), int(v.py + (v.y-v.py)*alpha)
+	}
 	for i := 0; i < numVerts; i++ {
 		// this gives next vertex index in polygon
 		// i.e. 0 -> 1, 1 -> 2, 2 -> 3, 3 -> 0
 		j := (i + 1) % numVerts
-		r.DrawLine(int32(b.vertices[i].x), int32(b.vertices[i].y),
-			int32(b.vertices[j].x), int32(b.vertices[j].y))
+		x0, y0 := at(b.vertices[i])
+		x1, y1 := at(b.vertices[j])
+		raster.Line(fb, x0, y0, x1, y1, c)
 	}
 }
 
@@ -139,62 +156,80 @@ func (b *ball) stopDrag() {
 }
 
 func main() {
-	err := sdl.Init(sdl.INIT_VIDEO)
-	if err != nil {
-		fmt.Println(err)
-		return
+	var opts platform.Options
+	opts.Register(flag.CommandLine)
+	var shots capture.Options
+	shots.Register(flag.CommandLine)
+	var cfg loop.Config
+	cfg.Register(flag.CommandLine)
+	bindingsPath := flag.String("bindings", "", "read controls from this `file`, like \"drag mouse:1\"")
+	flag.Parse()
+	cfg.Lockstep = opts.Headless > 0
+
+	controls := input.NewMap()
+	controls.Bind(drag, input.MouseButton(platform.ButtonLeft))
+	if *bindingsPath != "" {
+		if err := controls.Load(*bindingsPath); err != nil {
+			fmt.Println(err)
+			return
+		}
 	}
-	defer sdl.Quit()
 
-	window, err := sdl.CreateWindow("Bawls", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, winWidth, winHeight, sdl.WINDOW_SHOWN)
+	win, err := opts.Open("Bawls", winWidth, winHeight)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	defer window.Destroy()
+	p := capture.Wrap(win, shots, "ball")
+	defer func() {
+		if err := p.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
 
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
-	if err != nil {
+	if err := run(p, cfg, controls); err != nil {
 		fmt.Println(err)
-		return
 	}
-	defer renderer.Destroy()
-
-	ball := newBall(winWidth/2, winHeight/2, radius)
-	var mouseX, mouseY int32
-	var dragging bool
-
-	for {
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			// type switch concept bhul gya tha
-			switch e := event.(type) {
-			case *sdl.QuitEvent:
-				return
-			case *sdl.MouseButtonEvent:
-				mouseX, mouseY = e.X, e.Y
-				if e.Type == sdl.MOUSEBUTTONDOWN && e.Button == sdl.BUTTON_LEFT {
-					dragging = ball.tryStartDrag(mouseX, mouseY)
-				}
-				if e.Type == sdl.MOUSEBUTTONUP && e.Button == sdl.BUTTON_LEFT {
-					dragging = false
-					ball.stopDrag()
-				}
-			case *sdl.MouseMotionEvent:
-				mouseX, mouseY = e.X, e.Y
-			}
-		}
-
-		ball.update(mouseX, mouseY, dragging)
+}
 
-		// because sdl2 needs a color to clear
-		renderer.SetDrawColor(20, 20, 20, 255)
-		renderer.Clear()
+// drag pulls the ball about, the left mouse button unless rebound
+const drag input.Action = "drag"
 
-		renderer.SetDrawColor(200, 255, 200, 255)
-		ball.draw(renderer)
+// demo is the ball and the mouse pulling it about
+type demo struct {
+	ball     *ball
+	controls *input.Map
+	dragging bool
+}
 
-		renderer.Present()
+func (d *demo) Input(e platform.Event) {
+	d.controls.Handle(e)
+}
 
-		sdl.Delay(16)
+// Update ignores dt, the spring constants are per step and the loop keeps
+// steps the same length
+func (d *demo) Update(dt float64) {
+	defer d.controls.Next()
+	x, y := d.controls.Mouse()
+	mouseX, mouseY := int32(x), int32(y)
+	if d.controls.Pressed(drag) {
+		d.dragging = d.ball.tryStartDrag(mouseX, mouseY)
 	}
+	if d.controls.Released(drag) {
+		d.dragging = false
+		d.ball.stopDrag()
+	}
+	d.ball.update(mouseX, mouseY, d.dragging)
+}
+
+func (d *demo) Draw(fb *framebuffer.Buffer, alpha float64) {
+	fb.Fill(framebuffer.RGB(20, 20, 20))
+	d.ball.draw(fb, alpha, framebuffer.RGB(200, 255, 200))
+}
+
+// run is the demo on whatever platform main picked, until it's closed
+func run(p platform.Platform, cfg loop.Config, controls *input.Map) error {
+	fb := framebuffer.New(winWidth, winHeight, framebuffer.ABGR8888)
+	d := &demo{ball: newBall(winWidth/2, winHeight/2, radius), controls: controls}
+	return loop.Run(p, fb, d, cfg)
 }
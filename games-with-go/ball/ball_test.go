@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	"gfx/framebuffer"
+	"gfx/input"
+	"gfx/loop"
+	"gfx/platform"
+)
+
+// play runs the demo headless for frames frames with script's input, one
+// update a frame, and returns the last frame
+func play(t *testing.T, frames int, script string) *framebuffer.Buffer {
+	t.Helper()
+	steps, err := platform.ParseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := platform.NewHeadless(winWidth, winHeight, frames, steps)
+	controls := input.NewMap()
+	controls.Bind(drag, input.MouseButton(platform.ButtonLeft))
+	if err := run(h, loop.Config{Lockstep: true}, controls); err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames() != frames {
+		t.Fatalf("ran %d frames, want %d", h.Frames(), frames)
+	}
+	return h.Last()
+}
+
+// outline is the box around the ball's outline, everything that isn't
+// background
+func outline(fb *framebuffer.Buffer) image.Rectangle {
+	bg := framebuffer.RGB(20, 20, 20)
+	var box image.Rectangle
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Get(x, y) != bg {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// near reports whether every edge of r is within tol pixels of want's
+func near(r, want image.Rectangle, tol int) bool {
+	for _, d := range []int{r.Min.X - want.Min.X, r.Min.Y - want.Min.Y, r.Max.X - want.Max.X, r.Max.Y - want.Max.Y} {
+		if max(d, -d) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func center(r image.Rectangle) image.Point {
+	return r.Min.Add(r.Max).Div(2)
+}
+
+// settled is the ball left alone long enough to stop moving. the springs
+// between neighbors pull tighter than the ones holding each vertex at
+// radius, so it settles smaller than it starts
+func settled(t *testing.T) image.Rectangle {
+	t.Helper()
+	return outline(play(t, 200, ""))
+}
+
+func TestBallSettles(t *testing.T) {
+	box := settled(t)
+	if c := center(box); c != image.Pt(winWidth/2, winHeight/2) {
+		t.Errorf("ball settles around %v, want the middle", c)
+	}
+	if d := box.Dx() - box.Dy(); max(d, -d) > 1 {
+		t.Errorf("settled ball is %dx%d, want round", box.Dx(), box.Dy())
+	}
+	if box.Dx() > 2*radius || box.Dx() < radius/2 {
+		t.Errorf("settled ball is %d across", box.Dx())
+	}
+	if later := outline(play(t, 300, "")); later != box {
+		t.Errorf("ball still moving, %v then %v", box, later)
+	}
+}
+
+func TestBallDrag(t *testing.T) {
+	// grab the rightmost vertex once it's settled, and pull it out to the
+	// right
+	rest := settled(t)
+	script := fmt.Sprintf("200 mousedown %d %d 1\n201 mousemove 700 300\n", rest.Max.X-1, winHeight/2)
+	box := outline(play(t, 260, script))
+	if box.Max.X != 701 {
+		t.Errorf("dragged ball reaches %d, want the mouse at 700", box.Max.X-1)
+	}
+	if box.Min.X < rest.Min.X-5 || box.Min.X > center(rest).X {
+		t.Errorf("dragged ball at %v, only its right side should stretch from %v", box, rest)
+	}
+
+	// let go and it springs back
+	box = outline(play(t, 600, script+"260 mouseup 700 300 1\n"))
+	if !near(box, rest, 1) {
+		t.Errorf("released ball at %v, want back at %v", box, rest)
+	}
+}
+
+func TestBallDragMiss(t *testing.T) {
+	// the middle is nowhere near a vertex, pulling from there does nothing
+	rest := settled(t)
+	box := outline(play(t, 260, "200 mousedown 400 300 1\n201 mousemove 700 300\n"))
+	if !near(box, rest, 1) {
+		t.Errorf("ball at %v, want %v", box, rest)
+	}
+}
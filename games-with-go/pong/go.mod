@@ -2,4 +2,8 @@ module pong
 
 go 1.24.5
 
-require github.com/veandco/go-sdl2 v0.4.40
+require gfx v0.0.0-00010101000000-000000000000
+
+require github.com/veandco/go-sdl2 v0.4.40 // indirect
+
+replace gfx => ../../gfx
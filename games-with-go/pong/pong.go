@@ -1,49 +1,93 @@
 package main
 
 import (
+	"embed"
+	"flag"
 	"fmt"
-	"unsafe"
+	"os"
+	"strconv"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"gfx/capture"
+	"gfx/font"
+	"gfx/framebuffer"
+	"gfx/input"
+	"gfx/loop"
+	"gfx/platform"
+	"gfx/sprite"
 )
 
 const winWidth = 800
 const winHeight = 600
 
-type color struct {
-	r, g, b byte
-}
+const (
+	paddleSpeed = 400 // pixels a second
+	ballSpeed   = 350
+)
 
-func setPixel(x, y int, c color, pixels []byte) {
-	if x < 0 || x >= winWidth || y < 0 || y >= winHeight {
-		return
-	}
+// what the players do, and the controls they start with
+const (
+	p1Up   input.Action = "p1.up"
+	p1Down input.Action = "p1.down"
+	p2Up   input.Action = "p2.up"
+	p2Down input.Action = "p2.down"
+	serve  input.Action = "serve"
+)
 
-	index := (y*winWidth + x) * 4
+// defaultBindings puts both players on one keyboard, w and s on the left and
+// the arrows on the right, or on the first two gamepads
+func defaultBindings() *input.Map {
+	m := input.NewMap()
+	m.Bind(p1Up, input.Key("w"), input.PadButton(0, "dpup"))
+	m.Bind(p1Down, input.Key("s"), input.PadButton(0, "dpdown"))
+	m.Bind(p2Up, input.Key(platform.KeyUp), input.PadButton(1, "dpup"))
+	m.Bind(p2Down, input.Key(platform.KeyDown), input.PadButton(1, "dpdown"))
+	m.Bind(serve, input.Key(platform.KeySpace), input.PadButton(0, "start"), input.PadButton(1, "start"))
+	return m
+}
 
-	if (index + 3) > len(pixels) {
-		return
-	}
+//go:embed assets
+var assets embed.FS
 
-	pixels[index] = c.r
-	pixels[index+1] = c.g
-	pixels[index+2] = c.b
+// loadImage decodes one of the images built into the program
+func loadImage(name string) (*framebuffer.Buffer, error) {
+	f, err := assets.Open("assets/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return sprite.Decode(f)
 }
 
 type pos struct {
-	x, y int
+	x, y float32
 }
 
 type ball struct {
-	pos    // composition, something like inheritance
+	pos        // composition, something like inheritance
+	prev   pos // where it was before the last update, drawing goes between
 	radius int
-	// xvel   float32
-	// yvel   float32
-	// color color
+	xvel   float32 // pixels a second
+	yvel   float32
+	// color framebuffer.Color
+	spin *sprite.Animation // turns while it's moving
+}
+
+// update moves the ball dt seconds on, bouncing off the top and bottom
+func (ball *ball) update(dt float32) {
+	ball.prev = ball.pos
+	ball.spin.Update(float64(dt))
+	ball.x += ball.xvel * dt
+	ball.y += ball.yvel * dt
+
+	r := float32(ball.radius)
+	if ball.y-r < 0 || ball.y+r > winHeight {
+		ball.yvel = -ball.yvel
+		ball.y = min(max(ball.y, r), winHeight-r)
+	}
 }
 
 // this parametric angle way to find dots in a circle is computationally expensive
-// func (ball *ball) draw(pixels []byte) {
+// func (ball *ball) draw(fb *framebuffer.Buffer) {
 // 	for r := 0; r <= ball.radius; r++ {
 // 		for angle := 0; angle < 360; angle++ {
 // 			angleRad := float64(angle) * (math.Pi / 180)
@@ -51,102 +95,213 @@ type ball struct {
 // 			curX := ball.x + int((float64(r) * math.Cos(angleRad)))
 // 			curY := ball.y + int((float64(r) * math.Sin(angleRad)))
 
-// 			setPixel(curX, curY, color{255, 255, 255}, pixels)
+// 			fb.Set(curX, curY, framebuffer.RGB(255, 255, 255))
 // 		}
 // 	}
 // }
 
-// this is simple, bound check way. efficient because normal calculations
-func (ball *ball) draw(pixels []byte) {
-	for y := -ball.radius; y <= ball.radius; y++ {
-		for x := -ball.radius; x <= ball.radius; x++ {
-			if x*x+y*y <= ball.radius*ball.radius {
-				setPixel(ball.x+x, ball.y+y, color{255, 255, 255}, pixels)
-			}
-		}
+// the bound check way tested every pixel of the bounding square, spans fill
+// each row between its two edges straight away, and now the ball is a
+// sprite anyway. alpha is how far from prev to the current position to draw
+// it
+func (ball *ball) draw(fb *framebuffer.Buffer, alpha float32) {
+	x := ball.prev.x + (ball.x-ball.prev.x)*alpha
+	y := ball.prev.y + (ball.y-ball.prev.y)*alpha
+	ball.spin.Frame().DrawCentered(fb, int(x), int(y), sprite.Options{})
+}
+
+// hits bounces the ball off whichever side of the paddle it's overlapping,
+// reporting whether it did
+func (ball *ball) hits(paddle *paddle) bool {
+	// the closest point of the paddle to the ball's center
+	left, top := paddle.x-float32(paddle.w)/2, paddle.y-float32(paddle.h)/2
+	cx := min(max(ball.x, left), left+float32(paddle.w))
+	cy := min(max(ball.y, top), top+float32(paddle.h))
+	dx, dy := ball.x-cx, ball.y-cy
+	r := float32(ball.radius)
+	if dx*dx+dy*dy > r*r {
+		return false
+	}
+	// only ever send it back towards the middle, so it can't get stuck inside.
+	// where it hits steers it, the ends send it off at an angle, only on the
+	// step that turns it and not again on each one it takes to get clear
+	if (paddle.x < winWidth/2) == (ball.xvel < 0) {
+		ball.xvel = -ball.xvel
+		ball.yvel += (ball.y - paddle.y) / float32(paddle.h) * ballSpeed
 	}
+	return true
 }
 
 type paddle struct {
 	pos
-	w     int
-	h     int
-	color color
+	prev   pos
+	w      int
+	h      int
+	sprite sprite.Sprite
+	flip   bool // the right paddle is the left one mirrored
 }
 
-func (paddle *paddle) draw(pixels []byte) {
-	startX := paddle.x - paddle.w/2
-	startY := paddle.y - paddle.h/2
-
-	// y is outer, x is inner
-	// because the 2d matrix is being stored as array
-	// this makes iteration linear
-	for y := 0; y < paddle.h; y++ {
-		for x := 0; x < paddle.w; x++ {
-			setPixel(startX+x, startY+y, color{255, 255, 255}, pixels)
-		}
+// update moves the paddle dt seconds on, up or down while either is held
+func (paddle *paddle) update(dt float32, up, down bool) {
+	paddle.prev = paddle.pos
+	if up {
+		paddle.y -= paddleSpeed * dt
+	}
+	if down {
+		paddle.y += paddleSpeed * dt
 	}
+	half := float32(paddle.h) / 2
+	paddle.y = min(max(paddle.y, half), winHeight-half)
+}
+
+func (paddle *paddle) draw(fb *framebuffer.Buffer, alpha float32) {
+	y := paddle.prev.y + (paddle.y-paddle.prev.y)*alpha
+	startX := int(paddle.x) - paddle.w/2
+	startY := int(y) - paddle.h/2
+
+	// the bitmap has no alpha, its corners are magenta to be keyed out
+	opts := sprite.ColorKey(framebuffer.RGB(255, 0, 255))
+	opts.FlipX = paddle.flip
+	paddle.sprite.Draw(fb, startX, startY, opts)
+}
+
+// drawScore puts both players' points at the top, either side of the middle
+func drawScore(fb *framebuffer.Buffer, left, right int) {
+	style := font.Style{Color: framebuffer.RGB(255, 255, 255), Scale: 4, Align: font.AlignRight}
+	font.DrawText(fb, winWidth/2-30, 20, strconv.Itoa(left), style)
+	style.Align = font.AlignLeft
+	font.DrawText(fb, winWidth/2+30, 20, strconv.Itoa(right), style)
 }
 
 func main() {
-	err := sdl.Init(sdl.INIT_EVERYTHING)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer sdl.Quit()
+	var opts platform.Options
+	opts.Register(flag.CommandLine)
+	var shots capture.Options
+	shots.Register(flag.CommandLine)
+	var cfg loop.Config
+	cfg.Register(flag.CommandLine)
+	bindingsPath := flag.String("bindings", "", "read controls from this `file`, one action per line like \"p1.up key:w pad0:dpup\"")
+	printBindings := flag.Bool("print-bindings", false, "print the default controls in the -bindings format and quit")
+	flag.Parse()
+	cfg.Lockstep = opts.Headless > 0
 
-	window, err := sdl.CreateWindow("Testing SDL2", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(winWidth), int32(winHeight), sdl.WINDOW_SHOWN)
-	if err != nil {
-		fmt.Println(err)
+	controls := defaultBindings()
+	if *printBindings {
+		controls.Write(os.Stdout)
 		return
 	}
-	defer window.Destroy()
+	if *bindingsPath != "" {
+		if err := controls.Load(*bindingsPath); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
+	win, err := opts.Open("Testing SDL2", winWidth, winHeight)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	defer renderer.Destroy()
+	p := capture.Wrap(win, shots, "pong")
+	defer func() {
+		if err := p.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
 
-	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, int32(winWidth), int32(winHeight))
-	if err != nil {
+	if err := run(p, cfg, controls); err != nil {
 		fmt.Println(err)
-		return
 	}
-	defer tex.Destroy()
+}
+
+// game is everything on the court
+type game struct {
+	controls    *input.Map
+	ball        *ball
+	left, right *paddle
+	scores      [2]int // left's and right's points
+	serving     bool   // the ball waits in the middle until someone serves
+	serveDir    float32
+}
+
+func (g *game) Input(ev platform.Event) {
+	g.controls.Handle(ev)
+}
 
-	pixels := make([]byte, winWidth*winHeight*4) // buffer = width * height * 4 as each pixel has 4 values R G B A
+func (g *game) Update(dt float64) {
+	defer g.controls.Next()
+	c := g.controls
+	step := float32(dt)
+	g.left.update(step, c.Held(p1Up), c.Held(p1Down))
+	g.right.update(step, c.Held(p2Up), c.Held(p2Down))
 
-	// p := &paddle{}
+	if g.serving {
+		if c.Pressed(serve) {
+			g.serving = false
+			g.ball.xvel, g.ball.yvel = g.serveDir*ballSpeed, ballSpeed/2
+		}
+		return
+	}
+	g.ball.update(step)
+	if !g.ball.hits(g.left) {
+		g.ball.hits(g.right)
+	}
 
-	// p.x = winWidth / 2
-	// p.y = winHeight / 2
-	// p.w = 20
-	// p.h = 100
-	// p.color = color{}
+	// out past either end is a point to the other side, who the loser
+	// then gets served towards
+	r := float32(g.ball.radius)
+	switch {
+	case g.ball.x+r < 0:
+		g.scores[1]++
+		g.reset(-1)
+	case g.ball.x-r > winWidth:
+		g.scores[0]++
+		g.reset(1)
+	}
+}
 
-	// p.draw(pixels)
+// reset puts the ball back in the middle to be served towards dir
+func (g *game) reset(dir float32) {
+	g.ball.pos = pos{winWidth / 2, winHeight / 2}
+	g.ball.prev = g.ball.pos
+	g.ball.xvel, g.ball.yvel = 0, 0
+	g.serving, g.serveDir = true, dir
+}
 
-	b := &ball{}
-	b.x = winWidth / 2
-	b.y = winHeight / 2
-	b.radius = 200
+func (g *game) Draw(fb *framebuffer.Buffer, alpha float64) {
+	a := float32(alpha)
+	fb.Fill(framebuffer.RGB(0, 0, 0))
+	g.left.draw(fb, a)
+	g.right.draw(fb, a)
+	g.ball.draw(fb, a)
+	drawScore(fb, g.scores[0], g.scores[1])
+}
 
-	b.draw(pixels)
+// run is the game on whatever platform main picked, until it's closed
+func run(p platform.Platform, cfg loop.Config, controls *input.Map) error {
+	fb := framebuffer.New(winWidth, winHeight, framebuffer.ABGR8888)
 
-	tex.Update(nil, unsafe.Pointer(&pixels[0]), winWidth*4)
-	renderer.Copy(tex, nil, nil)
-	renderer.Present()
+	ballImg, err := loadImage("ball.png")
+	if err != nil {
+		return err
+	}
+	paddleImg, err := loadImage("paddle.bmp")
+	if err != nil {
+		return err
+	}
+	spin := &sprite.Animation{Sheet: sprite.Grid(ballImg, 40, 40), FPS: 12, Loop: true}
 
-	for {
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch event.(type) {
-			case *sdl.QuitEvent:
-				return
-			}
-		}
-		sdl.Delay(16)
+	newPaddle := func(x float32, flip bool) *paddle {
+		p := &paddle{pos: pos{x, winHeight / 2}, w: 20, h: 100, sprite: sprite.Whole(paddleImg), flip: flip}
+		p.prev = p.pos
+		return p
+	}
+	g := &game{
+		controls: controls,
+		ball:     &ball{radius: 20, spin: spin},
+		left:     newPaddle(40, false),
+		right:    newPaddle(winWidth-40, true),
 	}
+	g.reset(1)
+	return loop.Run(p, fb, g, cfg)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"gfx/framebuffer"
+	"gfx/loop"
+	"gfx/platform"
+)
+
+// play runs the game headless for frames frames with script's input, one
+// update a frame, and returns the last frame
+func play(t *testing.T, frames int, script string) *framebuffer.Buffer {
+	t.Helper()
+	steps, err := platform.ParseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := platform.NewHeadless(winWidth, winHeight, frames, steps)
+	if err := run(h, loop.Config{Lockstep: true}, defaultBindings()); err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames() != frames {
+		t.Fatalf("ran %d frames, want %d", h.Frames(), frames)
+	}
+	return h.Last()
+}
+
+// extent is the box around everything that isn't black inside r
+func extent(fb *framebuffer.Buffer, r image.Rectangle) image.Rectangle {
+	var box image.Rectangle
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c := fb.Get(x, y); c.R|c.G|c.B != 0 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// where things are drawn: the paddles keep to their columns, the score to
+// the top and the ball to everything in between
+var (
+	leftLane  = image.Rect(0, 0, 60, winHeight)
+	rightLane = image.Rect(winWidth-60, 0, winWidth, winHeight)
+	court     = image.Rect(60, 60, winWidth-60, winHeight)
+	scoreArea = image.Rect(300, 0, 500, 60)
+)
+
+func center(r image.Rectangle) image.Point {
+	return r.Min.Add(r.Max).Div(2)
+}
+
+// near reports whether p is within tol pixels of want on both axes
+func near(p, want image.Point, tol int) bool {
+	d := p.Sub(want)
+	return max(d.X, -d.X) <= tol && max(d.Y, -d.Y) <= tol
+}
+
+// checkScore compares the score drawn in fb with left and right
+func checkScore(t *testing.T, fb *framebuffer.Buffer, left, right int) {
+	t.Helper()
+	want := framebuffer.New(winWidth, winHeight, fb.Format)
+	want.Fill(framebuffer.RGB(0, 0, 0))
+	drawScore(want, left, right)
+	for y := scoreArea.Min.Y; y < scoreArea.Max.Y; y++ {
+		for x := scoreArea.Min.X; x < scoreArea.Max.X; x++ {
+			if fb.Get(x, y) != want.Get(x, y) {
+				t.Errorf("score isn't %d to %d, pixel %d,%d differs", left, right, x, y)
+				return
+			}
+		}
+	}
+}
+
+func TestPongWaitsToServe(t *testing.T) {
+	fb := play(t, 30, "")
+	if c := center(extent(fb, court)); !near(c, image.Pt(winWidth/2, winHeight/2), 1) {
+		t.Errorf("unserved ball at %v, want the middle", c)
+	}
+	for _, lane := range []image.Rectangle{leftLane, rightLane} {
+		if c := center(extent(fb, lane)); !near(c, image.Pt(c.X, winHeight/2), 1) {
+			t.Errorf("paddle at %v, want halfway down", c)
+		}
+	}
+	checkScore(t, fb, 0, 0)
+}
+
+func TestPongServe(t *testing.T) {
+	// served on the second frame, the ball heads right and down
+	fb := play(t, 32, "1 keydown space\n2 keyup space\n")
+	want := image.Pt(winWidth/2+30*ballSpeed/60, winHeight/2+30*ballSpeed/2/60)
+	if c := center(extent(fb, court)); !near(c, want, 8) {
+		t.Errorf("served ball at %v, want near %v", c, want)
+	}
+}
+
+func TestPongPaddles(t *testing.T) {
+	// a second and a half holding w and down takes the paddles to either end
+	fb := play(t, 90, "0 keydown w\n0 keydown down\n")
+	if box := extent(fb, leftLane); box.Min.Y != 0 {
+		t.Errorf("left paddle at %v, want at the top", box)
+	}
+	if box := extent(fb, rightLane); box.Max.Y != winHeight {
+		t.Errorf("right paddle at %v, want at the bottom", box)
+	}
+}
+
+func TestPongPoint(t *testing.T) {
+	// the right paddle stays put, the ball goes past it and the left player
+	// scores. the ball is back in the middle for the right one to serve
+	fb := play(t, 120, "1 keydown space\n2 keyup space\n")
+	checkScore(t, fb, 1, 0)
+	if c := center(extent(fb, court)); !near(c, image.Pt(winWidth/2, winHeight/2), 1) {
+		t.Errorf("ball at %v after the point, want the middle", c)
+	}
+}
+
+func TestPongReturn(t *testing.T) {
+	// the right paddle gets in the way, and the ball comes back left
+	fb := play(t, 100, "0 keydown down\n1 keydown space\n2 keyup space\n21 keyup down\n")
+	checkScore(t, fb, 0, 0)
+	if c := center(extent(fb, court)); c.X >= winWidth-100 || c.X <= winWidth/2 {
+		t.Errorf("ball at %v, want on its way back left", c)
+	}
+}
+
+func TestBallHits(t *testing.T) {
+	left := &paddle{pos: pos{40, 300}, w: 20, h: 100}
+	b := &ball{pos: pos{60, 330}, radius: 20, xvel: -ballSpeed}
+	if !b.hits(left) {
+		t.Fatal("ball overlapping the paddle didn't hit it")
+	}
+	// sent back right, steered down by hitting below the middle
+	wantY := float32(30) / 100 * ballSpeed
+	if b.xvel != ballSpeed || b.yvel != wantY {
+		t.Fatalf("after the hit: velocity %v, %v, want %v, %v", b.xvel, b.yvel, float32(ballSpeed), wantY)
+	}
+	// still overlapping on the steps after, it isn't turned or steered again
+	for range 3 {
+		b.x += 2
+		if !b.hits(left) {
+			t.Fatal("ball left the paddle")
+		}
+		if b.xvel != ballSpeed || b.yvel != wantY {
+			t.Fatalf("overlapping a step later: velocity %v, %v, want %v, %v", b.xvel, b.yvel, float32(ballSpeed), wantY)
+		}
+	}
+
+	b.x = 100
+	if b.hits(left) {
+		t.Error("ball clear of the paddle hit it")
+	}
+}
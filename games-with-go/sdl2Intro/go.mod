@@ -2,4 +2,9 @@ module sdl2Intro
 
 go 1.24.5
 
-require github.com/veandco/go-sdl2 v0.4.40 // indirect
+require (
+	gfx v0.0.0-00010101000000-000000000000
+	github.com/veandco/go-sdl2 v0.4.40
+)
+
+replace gfx => ../../gfx
@@ -0,0 +1,142 @@
+// Package capture saves what a program shows: a screenshot when a hotkey is
+// pressed, and every nth frame while recording, optionally assembled into an
+// animated gif. it wraps a platform, so a program gets it by wrapping the
+// window it opened
+package capture
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gfx/framebuffer"
+	"gfx/platform"
+)
+
+// Options says what to capture and where
+type Options struct {
+	Dir   string       // where the pngs go, "" for the working directory
+	Key   platform.Key // saves a screenshot, the program never sees it
+	Every int          // record every nth frame, 0 doesn't record
+	GIF   string       // assemble the recorded frames into this gif on Close
+	FPS   int          // gif playback rate, 0 for 60 / Every
+}
+
+// Register adds the capture flags to fs
+func (o *Options) Register(fs *flag.FlagSet) {
+	fs.StringVar(&o.Dir, "capture-dir", "", "directory for screenshots and recorded frames")
+	o.Key = "f12"
+	fs.Func("capture-key", "key that saves a timestamped png screenshot (default f12)", func(s string) error {
+		o.Key = platform.Key(s)
+		return nil
+	})
+	fs.IntVar(&o.Every, "record", 0, "save every `n`th frame as a numbered png, 0 doesn't record")
+	fs.StringVar(&o.GIF, "record-gif", "", "with -record, also assemble the recorded frames into this gif on exit")
+	fs.IntVar(&o.FPS, "record-fps", 0, "gif playback rate, 0 for 60 / record")
+}
+
+// Capturer is a platform that passes everything through to the one it
+// wraps, saving frames on the way
+type Capturer struct {
+	platform.Platform
+	opts    Options
+	name    string
+	log     io.Writer
+	frame   int  // frames presented so far
+	pending bool // the hotkey was pressed, the next frame is a screenshot
+	gif     []*image.Paletted
+}
+
+// Wrap captures p's frames as opts says. name starts every file name, so
+// screenshots from different programs don't mix
+func Wrap(p platform.Platform, opts Options, name string) *Capturer {
+	if opts.Key == "" {
+		opts.Key = "f12"
+	}
+	return &Capturer{Platform: p, opts: opts, name: name, log: os.Stderr}
+}
+
+// Shoot makes the next presented frame a screenshot, as the hotkey does
+func (c *Capturer) Shoot() {
+	c.pending = true
+}
+
+// Poll swallows the hotkey, both press and release
+func (c *Capturer) Poll() []platform.Event {
+	events := c.Platform.Poll()
+	out := events[:0]
+	for _, ev := range events {
+		if (ev.Kind == platform.KeyPress || ev.Kind == platform.KeyRelease) && ev.Key == c.opts.Key {
+			if ev.Kind == platform.KeyPress {
+				c.pending = true
+			}
+			continue
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+func (c *Capturer) Present(fb *framebuffer.Buffer) error {
+	record := c.opts.Every > 0 && c.frame%c.opts.Every == 0
+	shoot := c.pending
+	if err := c.Platform.Present(fb); err != nil {
+		return err
+	}
+	c.frame++
+
+	if shoot {
+		c.pending = false
+		stamp := time.Now().Format("20060102-150405.000")
+		if err := c.write(fmt.Sprintf("%s-%s.png", c.name, stamp), fb); err != nil {
+			return err
+		}
+	}
+	if record {
+		if err := c.write(fmt.Sprintf("%s-frame%06d.png", c.name, c.frame-1), fb); err != nil {
+			return err
+		}
+		if c.opts.GIF != "" {
+			c.gif = append(c.gif, Paletted(fb))
+		}
+	}
+	return nil
+}
+
+func (c *Capturer) write(name string, img image.Image) error {
+	if c.opts.Dir != "" {
+		if err := os.MkdirAll(c.opts.Dir, 0o755); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(c.opts.Dir, name)
+	if err := WritePNG(path, img); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.log, "wrote", path)
+	return nil
+}
+
+// Close assembles the gif if recording asked for one, then closes the
+// wrapped platform
+func (c *Capturer) Close() error {
+	var err error
+	if len(c.gif) > 0 {
+		fps := c.opts.FPS
+		if fps <= 0 {
+			fps = max(60/c.opts.Every, 1)
+		}
+		if err = writeGIF(c.opts.GIF, c.gif, fps); err == nil {
+			fmt.Fprintln(c.log, "wrote", c.opts.GIF)
+		}
+		c.gif = nil
+	}
+	if cerr := c.Platform.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package capture
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gfx/platform"
+)
+
+func TestCapturer(t *testing.T) {
+	dir := t.TempDir()
+	h := platform.NewHeadless(8, 4, 0, []platform.Step{
+		{Frame: 1, Event: platform.Event{Kind: platform.KeyPress, Key: "f12"}},
+		{Frame: 1, Event: platform.Event{Kind: platform.KeyPress, Key: "a"}},
+		{Frame: 2, Event: platform.Event{Kind: platform.KeyRelease, Key: "f12"}},
+	})
+	gifPath := filepath.Join(dir, "rec.gif")
+	c := Wrap(h, Options{Dir: dir, Every: 2, GIF: gifPath}, "test")
+	c.log = io.Discard
+
+	var seen []platform.Event
+	for k := range 5 {
+		seen = append(seen, c.Poll()...)
+		if err := c.Present(testFrame(byte(k * 50))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the hotkey never reaches the program
+	if !slices.Equal(seen, []platform.Event{{Kind: platform.KeyPress, Key: "a"}}) {
+		t.Errorf("program saw %v", seen)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames, shots []string
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case strings.HasPrefix(name, "test-frame"):
+			frames = append(frames, name)
+		case strings.HasPrefix(name, "test-"):
+			shots = append(shots, name)
+		}
+	}
+	if want := []string{"test-frame000000.png", "test-frame000002.png", "test-frame000004.png"}; !slices.Equal(frames, want) {
+		t.Errorf("recorded %v, want %v", frames, want)
+	}
+	if len(shots) != 1 {
+		t.Errorf("screenshots %v, want one", shots)
+	}
+
+	// every 2nd of 60 frames a second plays back at 30
+	g := readGIF(t, gifPath)
+	if len(g.Image) != 3 || !slices.Equal(g.Delay, []int{3, 3, 3}) {
+		t.Errorf("gif has %d frames with delays %v", len(g.Image), g.Delay)
+	}
+}
@@ -0,0 +1,62 @@
+package capture
+
+import (
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+)
+
+// WritePNG saves img as a png file
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Paletted dithers img down to the plan9 palette, since gif frames only
+// have 256 colors
+func Paletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(p, img.Bounds(), img, image.Point{})
+	return p
+}
+
+// WriteGIF assembles frames into a looping gif played at fps
+func WriteGIF(path string, frames []image.Image, fps int) error {
+	paletted := make([]*image.Paletted, len(frames))
+	for k, img := range frames {
+		paletted[k] = Paletted(img)
+	}
+	return writeGIF(path, paletted, fps)
+}
+
+func writeGIF(path string, frames []*image.Paletted, fps int) error {
+	anim := &gif.GIF{Image: frames}
+	// gif delays are in 1/100 s, rounded to the nearest: 60 fps is 2, where
+	// truncating to 1 would play it back at 100. at least 1, viewers play 0
+	// at a speed of their own choosing
+	delay := max(int(math.Round(100/float64(max(fps, 1)))), 1)
+	for range frames {
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
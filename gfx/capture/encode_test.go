@@ -0,0 +1,129 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+// testFrame is a small frame with a few flat colors in it
+func testFrame(shade byte) *framebuffer.Buffer {
+	fb := framebuffer.New(8, 4, framebuffer.ABGR8888)
+	fb.Fill(framebuffer.RGB(shade, 0, 0))
+	fb.Set(1, 1, framebuffer.RGB(0, 255, 0))
+	fb.Set(6, 2, framebuffer.RGB(0, 0, 255))
+	return fb
+}
+
+func TestWritePNG(t *testing.T) {
+	fb := testFrame(200)
+	path := filepath.Join(t.TempDir(), "shot.png")
+	if err := WritePNG(path, fb); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != fb.Bounds() {
+		t.Fatalf("decoded %v, want %v", img.Bounds(), fb.Bounds())
+	}
+	for y := range fb.Height {
+		for x := range fb.Width {
+			want := fb.At(x, y)
+			if got := color.NRGBAModel.Convert(img.At(x, y)); got != want {
+				t.Fatalf("pixel %d,%d is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	if err := WritePNG(filepath.Join(t.TempDir(), "missing", "shot.png"), fb); err == nil {
+		t.Error("no error writing into a missing directory")
+	}
+}
+
+func TestPaletted(t *testing.T) {
+	// colors the palette has come through exactly, without dithering noise
+	p := Paletted(testFrame(255))
+	if p.Bounds() != image.Rect(0, 0, 8, 4) {
+		t.Fatalf("bounds %v", p.Bounds())
+	}
+	if !slices.Equal(p.Palette, palette.Plan9) {
+		t.Error("not the plan9 palette")
+	}
+	want := map[image.Point]color.RGBA{
+		{0, 0}: {255, 0, 0, 255},
+		{1, 1}: {0, 255, 0, 255},
+		{6, 2}: {0, 0, 255, 255},
+		{7, 3}: {255, 0, 0, 255},
+	}
+	for pt, c := range want {
+		if got := color.RGBAModel.Convert(p.At(pt.X, pt.Y)); got != c {
+			t.Errorf("pixel %v is %v, want %v", pt, got, c)
+		}
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	tests := []struct {
+		fps, delay int
+	}{
+		{100, 1},
+		{60, 2}, // 1.67, rounded
+		{30, 3},
+		{25, 4},
+		{24, 4}, // 4.17
+		{15, 7}, // 6.67
+		{1, 100},
+		{0, 100},
+		{500, 1}, // never 0
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "anim.gif")
+		frames := []image.Image{testFrame(255), testFrame(128), testFrame(0)}
+		if err := WriteGIF(path, frames, tt.fps); err != nil {
+			t.Fatal(err)
+		}
+		g := readGIF(t, path)
+		if len(g.Image) != 3 {
+			t.Fatalf("%d frames, want 3", len(g.Image))
+		}
+		if !slices.Equal(g.Delay, []int{tt.delay, tt.delay, tt.delay}) {
+			t.Errorf("%d fps: delays %v, want %d", tt.fps, g.Delay, tt.delay)
+		}
+		if g.LoopCount != 0 {
+			t.Errorf("loop count %d, want forever", g.LoopCount)
+		}
+		// frames keep their order
+		if got := color.RGBAModel.Convert(g.Image[1].At(0, 0)).(color.RGBA); got.R < 100 || got.R > 150 {
+			t.Errorf("second frame is %v, want the middle shade", got)
+		}
+	}
+}
+
+func readGIF(t *testing.T, path string) *gif.GIF {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
@@ -0,0 +1,171 @@
+package font
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadBDF reads a font from a bdf file
+func LoadBDF(path string) (*Font, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBDF(f)
+}
+
+// ReadBDF parses the glyph bitmaps out of an adobe bitmap distribution
+// format font. only what drawing needs is kept: the bounding boxes, advances
+// and ascent and descent, everything else is skipped
+func ReadBDF(r io.Reader) (*Font, error) {
+	f := &Font{glyphs: map[rune]*Glyph{}}
+	var (
+		fbb              [4]int // font bounding box w, h, x offset, y offset
+		defaultAdvance   int
+		haveAsc, haveDes bool
+		g                *Glyph
+		code             rune
+		rowsLeft         int // bitmap rows still to read for g
+	)
+
+	sc := bufio.NewScanner(r)
+	line := 0
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("bdf line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+	ints := func(fields []string, n int) ([]int, error) {
+		if len(fields) < n {
+			return nil, fail("%s wants %d numbers", fields[0], n-1)
+		}
+		out := make([]int, n-1)
+		for k := range out {
+			v, err := strconv.Atoi(fields[k+1])
+			if err != nil {
+				return nil, fail("%s: %v", fields[0], err)
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if rowsLeft > 0 {
+			// a row is the glyph's width in bits, padded to whole bytes
+			row, err := hex.DecodeString(text)
+			if err != nil || len(row)*8 < g.Width {
+				return nil, fail("bad bitmap row %q", text)
+			}
+			y := g.Height - rowsLeft
+			for x := range g.Width {
+				g.bits[y*g.Width+x] = row[x/8]&(0x80>>(x%8)) != 0
+			}
+			rowsLeft--
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			v, err := ints(fields, 5)
+			if err != nil {
+				return nil, err
+			}
+			copy(fbb[:], v)
+		case "FONT_ASCENT":
+			v, err := ints(fields, 2)
+			if err != nil {
+				return nil, err
+			}
+			f.Ascent, haveAsc = v[0], true
+		case "FONT_DESCENT":
+			v, err := ints(fields, 2)
+			if err != nil {
+				return nil, err
+			}
+			f.Descent, haveDes = v[0], true
+		case "STARTCHAR":
+			g = &Glyph{Advance: defaultAdvance}
+			code = -1
+		case "ENCODING":
+			v, err := ints(fields, 2)
+			if err != nil {
+				return nil, err
+			}
+			code = rune(v[0])
+		case "DWIDTH":
+			v, err := ints(fields, 3)
+			if err != nil {
+				return nil, err
+			}
+			if g == nil {
+				defaultAdvance = v[0] // font wide, before any glyph
+			} else {
+				g.Advance = v[0]
+			}
+		case "BBX":
+			if g == nil {
+				return nil, fail("BBX outside a glyph")
+			}
+			v, err := ints(fields, 5)
+			if err != nil {
+				return nil, err
+			}
+			if v[0] < 0 || v[1] < 0 {
+				return nil, fail("negative glyph size")
+			}
+			g.Width, g.Height, g.XOff, g.YOff = v[0], v[1], v[2], v[3]
+		case "BITMAP":
+			if g == nil {
+				return nil, fail("BITMAP outside a glyph")
+			}
+			g.bits = make([]bool, g.Width*g.Height)
+			rowsLeft = g.Height
+		case "ENDCHAR":
+			if g == nil {
+				return nil, fail("ENDCHAR outside a glyph")
+			}
+			if g.Advance == 0 {
+				g.Advance = fbb[0]
+			}
+			if g.bits == nil {
+				g.bits = make([]bool, g.Width*g.Height) // no BITMAP, blank
+			}
+			// -1 is a glyph with no code point, nothing can ask for it
+			if code >= 0 {
+				f.glyphs[code] = g
+			}
+			g = nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if rowsLeft > 0 || g != nil {
+		return nil, fail("file ends inside a glyph")
+	}
+	if len(f.glyphs) == 0 {
+		return nil, fmt.Errorf("bdf has no glyphs")
+	}
+
+	// without the properties the bounding box says how far the font reaches
+	// above and below the baseline
+	if !haveAsc {
+		f.Ascent = fbb[1] + fbb[3]
+	}
+	if !haveDes {
+		f.Descent = -fbb[3]
+	}
+	f.fallback = f.glyphs['?']
+	return f, nil
+}
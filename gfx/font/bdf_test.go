@@ -0,0 +1,155 @@
+package font
+
+import (
+	"strings"
+	"testing"
+)
+
+// tiny is a two glyph font with no properties, a font wide advance and a
+// glyph wider than a byte
+const tiny = `STARTFONT 2.1
+FONTBOUNDINGBOX 10 3 0 -1
+DWIDTH 4 0
+CHARS 3
+STARTCHAR A
+ENCODING 65
+BBX 3 3 0 -1
+BITMAP
+40
+A0
+E0
+ENDCHAR
+STARTCHAR wide
+ENCODING 87
+DWIDTH 11 0
+BBX 10 2 1 0
+BITMAP
+8040
+FFC0
+ENDCHAR
+STARTCHAR unencoded
+ENCODING -1
+BBX 1 1 0 0
+BITMAP
+80
+ENDCHAR
+ENDFONT
+`
+
+// bitmap draws a glyph as rows of # and .
+func bitmap(g *Glyph) string {
+	var sb strings.Builder
+	for y := range g.Height {
+		for x := range g.Width {
+			if g.Ink(x, y) {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func TestReadBDF(t *testing.T) {
+	f, err := ReadBDF(strings.NewReader(tiny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no FONT_ASCENT or FONT_DESCENT, so they come from the bounding box
+	if f.Ascent != 2 || f.Descent != 1 {
+		t.Errorf("ascent %d, descent %d, want 2 and 1", f.Ascent, f.Descent)
+	}
+	if len(f.glyphs) != 2 {
+		t.Errorf("%d glyphs, want 2 without the unencoded one", len(f.glyphs))
+	}
+
+	tests := []struct {
+		r          rune
+		w, h       int
+		xoff, yoff int
+		advance    int
+		bits       string
+	}{
+		{'A', 3, 3, 0, -1, 4, ".#.\n#.#\n###\n"},
+		{'W', 10, 2, 1, 0, 11, "#........#\n##########\n"},
+	}
+	for _, tt := range tests {
+		g, ok := f.Glyph(tt.r)
+		if !ok {
+			t.Errorf("%q: missing", tt.r)
+			continue
+		}
+		if g.Width != tt.w || g.Height != tt.h || g.XOff != tt.xoff || g.YOff != tt.yoff || g.Advance != tt.advance {
+			t.Errorf("%q: %dx%d at %d,%d advancing %d", tt.r, g.Width, g.Height, g.XOff, g.YOff, g.Advance)
+		}
+		if got := bitmap(g); got != tt.bits {
+			t.Errorf("%q: bitmap\n%swant\n%s", tt.r, got, tt.bits)
+		}
+	}
+	// no '?', so nothing to fall back on
+	if _, ok := f.Glyph('z'); ok {
+		t.Error("missing glyph found without a fallback")
+	}
+}
+
+func TestReadBDFDefaults(t *testing.T) {
+	// properties win over the bounding box, a glyph with no DWIDTH at all
+	// advances by the box's width, and one with no BITMAP is blank
+	f, err := ReadBDF(strings.NewReader(`FONTBOUNDINGBOX 5 8 0 -1
+FONT_ASCENT 6
+FONT_DESCENT 2
+STARTCHAR blank
+ENCODING 63
+BBX 2 2 0 0
+ENDCHAR
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Ascent != 6 || f.Descent != 2 {
+		t.Errorf("ascent %d, descent %d, want 6 and 2", f.Ascent, f.Descent)
+	}
+	g, _ := f.Glyph('?')
+	if g.Advance != 5 || bitmap(g) != "..\n..\n" {
+		t.Errorf("advance %d, bitmap\n%s", g.Advance, bitmap(g))
+	}
+	if fb, ok := f.Glyph('x'); !ok || fb != g {
+		t.Error("missing glyph doesn't fall back to '?'")
+	}
+}
+
+func TestReadBDFErrors(t *testing.T) {
+	tests := []struct {
+		name, bdf, err string
+	}{
+		{"empty", "", "no glyphs"},
+		{"no glyphs", "FONTBOUNDINGBOX 5 8 0 -1\n", "no glyphs"},
+		{"short bounding box", "FONTBOUNDINGBOX 5 8\n", "line 1: FONTBOUNDINGBOX wants 4 numbers"},
+		{"not a number", "FONT_ASCENT seven\n", "line 1: FONT_ASCENT:"},
+		{"bad encoding", "STARTCHAR a\nENCODING x\n", "line 2: ENCODING:"},
+		{"bbx outside", "BBX 1 1 0 0\n", "line 1: BBX outside a glyph"},
+		{"bitmap outside", "BITMAP\n", "line 1: BITMAP outside a glyph"},
+		{"endchar outside", "ENDCHAR\n", "line 1: ENDCHAR outside a glyph"},
+		{"negative size", "STARTCHAR a\nBBX -1 2 0 0\n", "line 2: negative glyph size"},
+		{"bad row", "STARTCHAR a\nBBX 3 1 0 0\nBITMAP\nzz\nENDCHAR\n", `line 4: bad bitmap row "zz"`},
+		{"short row", "STARTCHAR a\nBBX 10 1 0 0\nBITMAP\nFF\nENDCHAR\n", `line 4: bad bitmap row "FF"`},
+		{"ends in bitmap", "STARTCHAR a\nENCODING 97\nBBX 3 2 0 0\nBITMAP\n80\n", "file ends inside a glyph"},
+		{"ends in glyph", "STARTCHAR a\nENCODING 97\n", "file ends inside a glyph"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBDF(strings.NewReader(tt.bdf))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadBDFMissing(t *testing.T) {
+	if _, err := LoadBDF("testdata/missing.bdf"); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
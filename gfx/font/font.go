@@ -0,0 +1,136 @@
+// Package font draws text into a framebuffer from bitmap fonts: a built in
+// 5x7 ascii set, or any bdf file
+package font
+
+import (
+	"bytes"
+	_ "embed"
+	"strings"
+	"sync"
+
+	"gfx/framebuffer"
+	"gfx/raster"
+)
+
+//go:embed 5x7.bdf
+var builtin []byte
+
+// Default is the built in 5x7 font, 6 pixels per character and 9 per line
+var Default = sync.OnceValue(func() *Font {
+	f, err := ReadBDF(bytes.NewReader(builtin))
+	if err != nil {
+		panic("font: built in font: " + err.Error())
+	}
+	return f
+})
+
+// Glyph is one character's bitmap. offsets are from the pen position on the
+// baseline to the bitmap's bottom left corner, y up as bdf has it
+type Glyph struct {
+	Width, Height int
+	XOff, YOff    int
+	Advance       int // how far the pen moves after it
+	bits          []bool
+}
+
+// Ink reports whether the pixel x, y of the bitmap is set, y down from the
+// top row
+func (g *Glyph) Ink(x, y int) bool {
+	return g.bits[y*g.Width+x]
+}
+
+// Font is a set of glyphs sharing a baseline
+type Font struct {
+	Ascent, Descent int // pixels above and below the baseline
+	glyphs          map[rune]*Glyph
+	fallback        *Glyph // drawn for characters the font lacks, may be nil
+}
+
+// Glyph finds the glyph for r, falling back to '?' if the font has one
+func (f *Font) Glyph(r rune) (*Glyph, bool) {
+	if g, ok := f.glyphs[r]; ok {
+		return g, true
+	}
+	return f.fallback, f.fallback != nil
+}
+
+// LineHeight is the distance between baselines, with a blank row between
+// one line's descenders and the next one's capitals
+func (f *Font) LineHeight() int {
+	return f.Ascent + f.Descent + 1
+}
+
+// Measure is the size text would take at scale 1, the widest line by the
+// number of lines
+func (f *Font) Measure(text string) (w, h int) {
+	lines := strings.Split(text, "\n")
+	for _, l := range lines {
+		w = max(w, f.width(l))
+	}
+	return w, len(lines) * f.LineHeight()
+}
+
+func (f *Font) width(line string) int {
+	w := 0
+	for _, r := range line {
+		if g, ok := f.Glyph(r); ok {
+			w += g.Advance
+		}
+	}
+	return w
+}
+
+// Align is which part of the text lines up with the x it's drawn at
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Style is how DrawText draws
+type Style struct {
+	Font  *Font // nil for Default
+	Color framebuffer.Color
+	Scale int // whole screen pixels per font pixel, 0 counts as 1
+	Align Align
+}
+
+// DrawText draws text with the top of its first line at y, each line
+// aligned on x by itself. colors with alpha blend over what's there
+func DrawText(fb *framebuffer.Buffer, x, y int, text string, st Style) {
+	f := st.Font
+	if f == nil {
+		f = Default()
+	}
+	scale := max(st.Scale, 1)
+
+	for k, l := range strings.Split(text, "\n") {
+		pen := x
+		switch st.Align {
+		case AlignCenter:
+			pen -= f.width(l) * scale / 2
+		case AlignRight:
+			pen -= f.width(l) * scale
+		}
+		baseline := y + (k*f.LineHeight()+f.Ascent)*scale
+
+		for _, r := range l {
+			g, ok := f.Glyph(r)
+			if !ok {
+				continue
+			}
+			top := baseline - (g.YOff+g.Height)*scale
+			left := pen + g.XOff*scale
+			for gy := range g.Height {
+				for gx := range g.Width {
+					if g.Ink(gx, gy) {
+						raster.FillRect(fb, left+gx*scale, top+gy*scale, scale, scale, st.Color)
+					}
+				}
+			}
+			pen += g.Advance * scale
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package font
+
+import (
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+func TestDefault(t *testing.T) {
+	f := Default()
+	if f.Ascent != 7 || f.Descent != 1 || f.LineHeight() != 9 {
+		t.Errorf("ascent %d, descent %d, line height %d", f.Ascent, f.Descent, f.LineHeight())
+	}
+	// all of printable ascii, in the same 5x8 cell
+	for r := rune(' '); r <= '~'; r++ {
+		g, ok := f.glyphs[r]
+		if !ok {
+			t.Errorf("%q missing", r)
+			continue
+		}
+		if g.Width != 5 || g.Height != 8 || g.XOff != 0 || g.YOff != -1 || g.Advance != 6 {
+			t.Errorf("%q: %dx%d at %d,%d advancing %d", r, g.Width, g.Height, g.XOff, g.YOff, g.Advance)
+		}
+	}
+	if len(f.glyphs) != 95 {
+		t.Errorf("%d glyphs, want 95", len(f.glyphs))
+	}
+
+	tests := []struct {
+		r    rune
+		bits string
+	}{
+		{' ', ".....\n.....\n.....\n.....\n.....\n.....\n.....\n.....\n"},
+		{'!', "..#..\n..#..\n..#..\n..#..\n..#..\n.....\n..#..\n.....\n"},
+		{'A', ".###.\n#...#\n#...#\n#####\n#...#\n#...#\n#...#\n.....\n"},
+		{'?', ".###.\n#...#\n....#\n...#.\n..#..\n.....\n..#..\n.....\n"},
+	}
+	for _, tt := range tests {
+		g, _ := f.Glyph(tt.r)
+		if got := bitmap(g); got != tt.bits {
+			t.Errorf("%q:\n%swant\n%s", tt.r, got, tt.bits)
+		}
+	}
+
+	// anything else draws as '?'
+	q, _ := f.Glyph('?')
+	for _, r := range []rune{'é', '\t', 0x7f, '世'} {
+		if g, ok := f.Glyph(r); !ok || g != q {
+			t.Errorf("%q doesn't fall back to '?'", r)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	f := Default()
+	tests := []struct {
+		text string
+		w, h int
+	}{
+		{"", 0, 9},
+		{"a", 6, 9},
+		{"hello", 30, 9},
+		{"ab\ncde", 18, 18},
+		{"\n\n", 0, 27},
+		{"é", 6, 9}, // as wide as the '?' it draws
+	}
+	for _, tt := range tests {
+		if w, h := f.Measure(tt.text); w != tt.w || h != tt.h {
+			t.Errorf("%q: %dx%d, want %dx%d", tt.text, w, h, tt.w, tt.h)
+		}
+	}
+}
+
+// inked is the bounding box of what's been drawn into fb, empty if nothing
+func inked(fb *framebuffer.Buffer) (x0, y0, x1, y1 int) {
+	x0, y0 = fb.Width, fb.Height
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Get(x, y).A != 0 {
+				x0, y0 = min(x0, x), min(y0, y)
+				x1, y1 = max(x1, x+1), max(y1, y+1)
+			}
+		}
+	}
+	return
+}
+
+func TestDrawText(t *testing.T) {
+	white := framebuffer.RGB(255, 255, 255)
+	tests := []struct {
+		name           string
+		x, y           int
+		text           string
+		st             Style
+		x0, y0, x1, y1 int // what gets inked
+	}{
+		// 'A' fills its cell but for the descender row and the gap after it
+		{"left", 4, 2, "A", Style{Color: white}, 4, 2, 9, 9},
+		{"two", 4, 2, "AA", Style{Color: white}, 4, 2, 15, 9},
+		{"scaled", 4, 2, "A", Style{Color: white, Scale: 2}, 4, 2, 14, 16},
+		{"center", 20, 0, "AA", Style{Color: white, Align: AlignCenter}, 14, 0, 25, 7},
+		{"right", 20, 0, "AA", Style{Color: white, Align: AlignRight}, 8, 0, 19, 7},
+		{"second line", 0, 0, "\nA", Style{Color: white}, 0, 9, 5, 16},
+		{"descender", 0, 0, "g", Style{Color: white}, 0, 2, 5, 8},
+		{"clipped", -3, -3, "A", Style{Color: white}, 0, 0, 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := framebuffer.New(40, 20, framebuffer.RGBA32)
+			DrawText(fb, tt.x, tt.y, tt.text, tt.st)
+			if x0, y0, x1, y1 := inked(fb); x0 != tt.x0 || y0 != tt.y0 || x1 != tt.x1 || y1 != tt.y1 {
+				t.Errorf("inked %d,%d to %d,%d, want %d,%d to %d,%d", x0, y0, x1, y1, tt.x0, tt.y0, tt.x1, tt.y1)
+			}
+		})
+	}
+}
+
+func TestDrawTextPixels(t *testing.T) {
+	fb := framebuffer.New(6, 8, framebuffer.RGBA32)
+	DrawText(fb, 0, 0, "!", Style{Color: framebuffer.RGB(255, 0, 0)})
+	g, _ := Default().Glyph('!')
+	n := 0
+	for y := range 8 {
+		for x := range 6 {
+			c := fb.Get(x, y)
+			ink := x < 5 && g.Ink(x, y)
+			if ink && c != framebuffer.RGB(255, 0, 0) || !ink && c.A != 0 {
+				t.Errorf("pixel %d,%d is %v", x, y, c)
+			}
+			if ink {
+				n++
+			}
+		}
+	}
+	if n != 6 {
+		t.Errorf("'!' has %d pixels, want 6", n)
+	}
+}
@@ -0,0 +1,178 @@
+// Package framebuffer is a cpu side pixel buffer with an explicit byte
+// layout, shared by the games and the ray tracer so every program draws and
+// uploads pixels the same way
+package framebuffer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Format is how one pixel is laid out in Pix
+type Format int
+
+const (
+	// RGB24 is three bytes r, g, b with no alpha
+	RGB24 Format = iota
+	// RGBA32 is four bytes r, g, b, a in that order in memory on every
+	// platform
+	RGBA32
+	// ABGR8888 is one native endian uint32 with alpha in the top byte and
+	// red in the bottom. on little endian machines its bytes are the same
+	// as RGBA32, on big endian they're reversed
+	ABGR8888
+)
+
+// BytesPerPixel is the stride between neighbouring pixels in a row
+func (f Format) BytesPerPixel() int {
+	if f == RGB24 {
+		return 3
+	}
+	return 4
+}
+
+func (f Format) String() string {
+	switch f {
+	case RGB24:
+		return "RGB24"
+	case RGBA32:
+		return "RGBA32"
+	case ABGR8888:
+		return "ABGR8888"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Color is an 8 bit per channel color. formats without alpha drop A
+type Color struct {
+	R, G, B, A byte
+}
+
+// RGB is an opaque color
+func RGB(r, g, b byte) Color {
+	return Color{r, g, b, 255}
+}
+
+// RGBA is a color with alpha, 0 transparent to 255 opaque
+func RGBA(r, g, b, a byte) Color {
+	return Color{r, g, b, a}
+}
+
+// Buffer is Width x Height pixels in Format, rows top to bottom with no
+// padding between them
+type Buffer struct {
+	Width, Height int
+	Format        Format
+	Pix           []byte
+}
+
+// New allocates a buffer cleared to zero
+func New(width, height int, format Format) *Buffer {
+	return &Buffer{
+		Width:  width,
+		Height: height,
+		Format: format,
+		Pix:    make([]byte, width*height*format.BytesPerPixel()),
+	}
+}
+
+// Pitch is the length of one row in bytes
+func (b *Buffer) Pitch() int {
+	return b.Width * b.Format.BytesPerPixel()
+}
+
+// In reports whether x, y is inside the buffer
+func (b *Buffer) In(x, y int) bool {
+	return x >= 0 && x < b.Width && y >= 0 && y < b.Height
+}
+
+func (b *Buffer) offset(x, y int) int {
+	return y*b.Pitch() + x*b.Format.BytesPerPixel()
+}
+
+// Set writes one pixel, points outside the buffer are ignored so callers can
+// draw shapes that run off the edge
+func (b *Buffer) Set(x, y int, c Color) {
+	if !b.In(x, y) {
+		return
+	}
+	b.put(b.offset(x, y), c)
+}
+
+func (b *Buffer) put(i int, c Color) {
+	switch b.Format {
+	case RGB24:
+		b.Pix[i+0] = c.R
+		b.Pix[i+1] = c.G
+		b.Pix[i+2] = c.B
+	case RGBA32:
+		b.Pix[i+0] = c.R
+		b.Pix[i+1] = c.G
+		b.Pix[i+2] = c.B
+		b.Pix[i+3] = c.A
+	case ABGR8888:
+		v := uint32(c.A)<<24 | uint32(c.B)<<16 | uint32(c.G)<<8 | uint32(c.R)
+		binary.NativeEndian.PutUint32(b.Pix[i:], v)
+	}
+}
+
+// Get reads one pixel, zero outside the buffer. RGB24 pixels come back opaque
+func (b *Buffer) Get(x, y int) Color {
+	if !b.In(x, y) {
+		return Color{}
+	}
+	i := b.offset(x, y)
+	switch b.Format {
+	case RGB24:
+		return Color{b.Pix[i+0], b.Pix[i+1], b.Pix[i+2], 255}
+	case RGBA32:
+		return Color{b.Pix[i+0], b.Pix[i+1], b.Pix[i+2], b.Pix[i+3]}
+	case ABGR8888:
+		v := binary.NativeEndian.Uint32(b.Pix[i:])
+		return Color{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+	}
+	return Color{}
+}
+
+// Fill sets every pixel to c
+func (b *Buffer) Fill(c Color) {
+	if len(b.Pix) == 0 {
+		return
+	}
+	// write one pixel, then keep doubling the filled prefix
+	n := b.Format.BytesPerPixel()
+	b.put(0, c)
+	for filled := n; filled < len(b.Pix); filled *= 2 {
+		copy(b.Pix[filled:], b.Pix[:filled])
+	}
+}
+
+// Buffer is an image.Image, so it can go straight to the stdlib encoders
+
+func (b *Buffer) ColorModel() color.Model { return color.NRGBAModel }
+
+func (b *Buffer) Bounds() image.Rectangle { return image.Rect(0, 0, b.Width, b.Height) }
+
+func (b *Buffer) At(x, y int) color.Color {
+	c := b.Get(x, y)
+	return color.NRGBA{c.R, c.G, c.B, c.A}
+}
+
+// Image copies the buffer into a new image, for keeping a frame while the
+// buffer is drawn over
+func (b *Buffer) Image() *image.NRGBA {
+	img := image.NewNRGBA(b.Bounds())
+	for y := range b.Height {
+		for x := range b.Width {
+			c := b.Get(x, y)
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = c.R
+			img.Pix[i+1] = c.G
+			img.Pix[i+2] = c.B
+			img.Pix[i+3] = c.A
+		}
+	}
+	return img
+}
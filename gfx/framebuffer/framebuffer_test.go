@@ -0,0 +1,134 @@
+package framebuffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+var c = RGBA(0x11, 0x22, 0x33, 0x44)
+
+func TestLayout(t *testing.T) {
+	// where Set(1, 1, c) puts its bytes in a 3x2 buffer
+	abgr := make([]byte, 4)
+	binary.NativeEndian.PutUint32(abgr, 0x44332211)
+	tests := []struct {
+		format Format
+		bpp    int
+		pitch  int // what Upload hands sdl as the row length
+		offset int
+		bytes  []byte
+		get    Color // what Get reads back
+	}{
+		{RGB24, 3, 9, 12, []byte{0x11, 0x22, 0x33}, RGB(0x11, 0x22, 0x33)},
+		{RGBA32, 4, 12, 16, []byte{0x11, 0x22, 0x33, 0x44}, c},
+		{ABGR8888, 4, 12, 16, abgr, c},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			b := New(3, 2, tt.format)
+			if got := tt.format.BytesPerPixel(); got != tt.bpp {
+				t.Errorf("bytes per pixel %d, want %d", got, tt.bpp)
+			}
+			if got := b.Pitch(); got != tt.pitch {
+				t.Errorf("pitch %d, want %d", got, tt.pitch)
+			}
+			if len(b.Pix) != tt.pitch*2 {
+				t.Errorf("%d bytes, want %d", len(b.Pix), tt.pitch*2)
+			}
+
+			b.Set(1, 1, c)
+			if got := b.Pix[tt.offset : tt.offset+tt.bpp]; !bytes.Equal(got, tt.bytes) {
+				t.Errorf("pixel bytes % x, want % x", got, tt.bytes)
+			}
+			// nothing else was touched
+			for k, v := range b.Pix {
+				if v != 0 && (k < tt.offset || k >= tt.offset+tt.bpp) {
+					t.Fatalf("byte %d set to %#x", k, v)
+				}
+			}
+			if got := b.Get(1, 1); got != tt.get {
+				t.Errorf("Get %v, want %v", got, tt.get)
+			}
+			want := color.NRGBA{tt.get.R, tt.get.G, tt.get.B, tt.get.A}
+			if got := b.At(1, 1); got != want {
+				t.Errorf("At %v, want %v", got, want)
+			}
+			if got := b.Image().NRGBAAt(1, 1); got != want {
+				t.Errorf("Image %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestABGR8888LittleEndian(t *testing.T) {
+	// on little endian machines the native uint32 is the same bytes as RGBA32
+	probe := []byte{1, 0}
+	if binary.NativeEndian.Uint16(probe) != 1 {
+		t.Skip("big endian")
+	}
+	a, b := New(2, 2, ABGR8888), New(2, 2, RGBA32)
+	a.Set(1, 0, c)
+	b.Set(1, 0, c)
+	if !bytes.Equal(a.Pix, b.Pix) {
+		t.Errorf("ABGR8888 % x, RGBA32 % x", a.Pix, b.Pix)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	for _, f := range []Format{RGB24, RGBA32, ABGR8888} {
+		t.Run(f.String(), func(t *testing.T) {
+			b := New(3, 2, f)
+			for _, p := range [][2]int{{-1, 0}, {0, -1}, {3, 0}, {0, 2}, {3, 2}, {-100, 100}} {
+				b.Set(p[0], p[1], c)
+				if got := b.Get(p[0], p[1]); got != (Color{}) {
+					t.Errorf("Get(%d, %d) = %v outside the buffer", p[0], p[1], got)
+				}
+				if b.In(p[0], p[1]) {
+					t.Errorf("In(%d, %d)", p[0], p[1])
+				}
+			}
+			if !bytes.Equal(b.Pix, make([]byte, len(b.Pix))) {
+				t.Error("Set outside the buffer wrote to it")
+			}
+			for _, p := range [][2]int{{0, 0}, {2, 0}, {0, 1}, {2, 1}} {
+				if !b.In(p[0], p[1]) {
+					t.Errorf("!In(%d, %d)", p[0], p[1])
+				}
+			}
+			if got := b.Bounds(); got.Dx() != 3 || got.Dy() != 2 || got.Min.X != 0 || got.Min.Y != 0 {
+				t.Errorf("bounds %v", got)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	for _, f := range []Format{RGB24, RGBA32, ABGR8888} {
+		// odd sizes, so the doubling copy ends part way through a pixel
+		for _, size := range [][2]int{{1, 1}, {3, 2}, {7, 5}, {0, 0}} {
+			b := New(size[0], size[1], f)
+			b.Fill(c)
+			want := c
+			if f == RGB24 {
+				want.A = 255
+			}
+			for y := range size[1] {
+				for x := range size[0] {
+					if got := b.Get(x, y); got != want {
+						t.Fatalf("%v %dx%d: pixel %d,%d is %v, want %v", f, size[0], size[1], x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFormatString(t *testing.T) {
+	for f, want := range map[Format]string{RGB24: "RGB24", RGBA32: "RGBA32", ABGR8888: "ABGR8888", Format(9): "Format(9)"} {
+		if got := f.String(); got != want {
+			t.Errorf("%d: %q, want %q", int(f), got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !nosdl
+
+package framebuffer
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// SDLFormat is the sdl pixel format with the same byte layout
+func (f Format) SDLFormat() uint32 {
+	switch f {
+	case RGB24:
+		return sdl.PIXELFORMAT_RGB24
+	case RGBA32:
+		return sdl.PIXELFORMAT_RGBA32
+	}
+	return sdl.PIXELFORMAT_ABGR8888
+}
+
+// NewTexture creates a streaming texture the buffer can be uploaded to
+func (b *Buffer) NewTexture(renderer *sdl.Renderer) (*sdl.Texture, error) {
+	return renderer.CreateTexture(b.Format.SDLFormat(), sdl.TEXTUREACCESS_STREAMING, int32(b.Width), int32(b.Height))
+}
+
+// Upload copies the whole buffer into tex, which must be the same size and
+// format, as made by NewTexture
+func (b *Buffer) Upload(tex *sdl.Texture) error {
+	if len(b.Pix) == 0 {
+		return nil
+	}
+	format, _, w, h, err := tex.Query()
+	if err != nil {
+		return err
+	}
+	if format != b.Format.SDLFormat() || int(w) != b.Width || int(h) != b.Height {
+		return fmt.Errorf("texture is %dx%d %s, buffer is %dx%d %v", w, h, sdl.GetPixelFormatName(uint(format)), b.Width, b.Height, b.Format)
+	}
+	return tex.Update(nil, unsafe.Pointer(&b.Pix[0]), b.Pitch())
+}
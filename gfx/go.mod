@@ -0,0 +1,5 @@
+module gfx
+
+go 1.24.5
+
+require github.com/veandco/go-sdl2 v0.4.40
@@ -0,0 +1,99 @@
+// Package input maps keys, mouse buttons and gamepad buttons to named
+// actions, so games ask whether "p1.up" is held rather than which key that
+// is this week. bindings can be read from a file, one action per line, which
+// is how controls get rebound and how two players share a keyboard
+package input
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gfx/platform"
+)
+
+// Action is something the game does, named however it likes: "jump",
+// "p2.down"
+type Action string
+
+// Device is what a Binding listens to
+type Device int
+
+const (
+	Keyboard Device = iota
+	Mouse
+	Pad
+)
+
+// Binding is one physical input
+type Binding struct {
+	Device Device
+	Key    platform.Key // the key, or the pad's button
+	Button int          // mouse button
+	Pad    int          // which gamepad
+}
+
+// Key binds a keyboard key
+func Key(k platform.Key) Binding {
+	return Binding{Device: Keyboard, Key: k}
+}
+
+// MouseButton binds a mouse button
+func MouseButton(b int) Binding {
+	return Binding{Device: Mouse, Button: b}
+}
+
+// PadButton binds a button on gamepad pad
+func PadButton(pad int, button platform.Key) Binding {
+	return Binding{Device: Pad, Pad: pad, Key: button}
+}
+
+// String is how the binding is written in files: "key:w", "mouse:1" or
+// "pad0:dpup"
+func (b Binding) String() string {
+	switch b.Device {
+	case Mouse:
+		return "mouse:" + strconv.Itoa(b.Button)
+	case Pad:
+		return fmt.Sprintf("pad%d:%s", b.Pad, b.Key)
+	}
+	return "key:" + string(b.Key)
+}
+
+// ParseBinding reads a binding written as String writes it
+func ParseBinding(s string) (Binding, error) {
+	dev, name, ok := strings.Cut(strings.ToLower(s), ":")
+	if !ok || name == "" {
+		return Binding{}, fmt.Errorf("binding %q: want device:input, like key:w", s)
+	}
+	switch {
+	case dev == "key":
+		return Key(platform.Key(name)), nil
+	case dev == "mouse":
+		b, err := strconv.Atoi(name)
+		if err != nil || b < 1 {
+			return Binding{}, fmt.Errorf("binding %q: bad mouse button", s)
+		}
+		return MouseButton(b), nil
+	case strings.HasPrefix(dev, "pad"):
+		pad, err := strconv.Atoi(dev[3:])
+		if err != nil || pad < 0 {
+			return Binding{}, fmt.Errorf("binding %q: want a pad number, like pad0", s)
+		}
+		return PadButton(pad, platform.Key(name)), nil
+	}
+	return Binding{}, fmt.Errorf("binding %q: unknown device %q", s, dev)
+}
+
+// binding finds what an event presses or releases
+func binding(ev platform.Event) (b Binding, down, ok bool) {
+	switch ev.Kind {
+	case platform.KeyPress, platform.KeyRelease:
+		return Key(ev.Key), ev.Kind == platform.KeyPress, true
+	case platform.MouseDown, platform.MouseUp:
+		return MouseButton(ev.Button), ev.Kind == platform.MouseDown, true
+	case platform.PadDown, platform.PadUp:
+		return PadButton(ev.Pad, ev.Key), ev.Kind == platform.PadDown, true
+	}
+	return Binding{}, false, false
+}
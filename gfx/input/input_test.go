@@ -0,0 +1,57 @@
+package input
+
+import (
+	"strings"
+	"testing"
+
+	"gfx/platform"
+)
+
+func TestParseBinding(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Binding
+		out  string // how String writes it back
+	}{
+		{"key:w", Key("w"), "key:w"},
+		{"KEY:Space", Key(platform.KeySpace), "key:space"},
+		{"key:f11", Key(platform.KeyFullscreen), "key:f11"},
+		{"mouse:1", MouseButton(platform.ButtonLeft), "mouse:1"},
+		{"mouse:3", MouseButton(platform.ButtonRight), "mouse:3"},
+		{"pad0:dpup", PadButton(0, "dpup"), "pad0:dpup"},
+		{"pad12:Start", PadButton(12, "start"), "pad12:start"},
+	}
+	for _, tt := range tests {
+		b, err := ParseBinding(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if b != tt.want {
+			t.Errorf("%q: %+v, want %+v", tt.in, b, tt.want)
+		}
+		if got := b.String(); got != tt.out {
+			t.Errorf("%q: written as %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestParseBindingErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"w", "want device:input"},
+		{"key:", "want device:input"},
+		{"mouse:left", "bad mouse button"},
+		{"mouse:0", "bad mouse button"},
+		{"pad:a", "want a pad number"},
+		{"padx:a", "want a pad number"},
+		{"pad-1:a", "want a pad number"},
+		{"joystick:1", `unknown device "joystick"`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseBinding(tt.in); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: error %v, want %q", tt.in, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gfx/platform"
+)
+
+// Map turns events into action states. an action is held while any of its
+// bindings is down, pressed when the first of them goes down and released
+// when the last comes up. pressed and released stick until Next, so a tap
+// that starts and ends between two updates still shows up in the second
+type Map struct {
+	actions  []Action // in the order first bound, for Write
+	bindings map[Action][]Binding
+	down     map[Binding]bool
+	pressed  map[Action]bool
+	released map[Action]bool
+	mouseX   int
+	mouseY   int
+}
+
+func NewMap() *Map {
+	return &Map{
+		bindings: map[Action][]Binding{},
+		down:     map[Binding]bool{},
+		pressed:  map[Action]bool{},
+		released: map[Action]bool{},
+	}
+}
+
+// Bind sets what triggers a, replacing what did before
+func (m *Map) Bind(a Action, bs ...Binding) {
+	if _, ok := m.bindings[a]; !ok {
+		m.actions = append(m.actions, a)
+	}
+	m.bindings[a] = slices.Clone(bs)
+}
+
+// Bindings is what triggers a
+func (m *Map) Bindings(a Action) []Binding {
+	return m.bindings[a]
+}
+
+// Handle takes in an event and reports whether any action uses it
+func (m *Map) Handle(ev platform.Event) bool {
+	switch ev.Kind {
+	case platform.MouseDown, platform.MouseUp, platform.MouseMove:
+		m.mouseX, m.mouseY = ev.X, ev.Y
+	}
+	b, down, ok := binding(ev)
+	if !ok || m.down[b] == down {
+		return false // not a button, or a repeat
+	}
+
+	var bound []Action
+	var was []bool
+	for _, a := range m.actions {
+		if slices.Contains(m.bindings[a], b) {
+			bound = append(bound, a)
+			was = append(was, m.Held(a))
+		}
+	}
+	if down {
+		m.down[b] = true
+	} else {
+		delete(m.down, b)
+	}
+	// another binding of the same action may still be holding it
+	for k, a := range bound {
+		switch now := m.Held(a); {
+		case now && !was[k]:
+			m.pressed[a] = true
+		case !now && was[k]:
+			m.released[a] = true
+		}
+	}
+	return len(bound) > 0
+}
+
+// Held reports whether a is down now
+func (m *Map) Held(a Action) bool {
+	for _, b := range m.bindings[a] {
+		if m.down[b] {
+			return true
+		}
+	}
+	return false
+}
+
+// Pressed reports whether a went down since Next
+func (m *Map) Pressed(a Action) bool {
+	return m.pressed[a]
+}
+
+// Released reports whether a came up since Next
+func (m *Map) Released(a Action) bool {
+	return m.released[a]
+}
+
+// Mouse is where the pointer was last seen
+func (m *Map) Mouse() (x, y int) {
+	return m.mouseX, m.mouseY
+}
+
+// Next forgets presses and releases once they've been acted on, at the end
+// of each update
+func (m *Map) Next() {
+	clear(m.pressed)
+	clear(m.released)
+}
+
+// Read replaces the bindings of the actions r lists, one per line as the
+// action and then its bindings:
+//
+//	p1.up    key:w  pad0:dpup
+//	serve    key:space  mouse:1
+//
+// an action with nothing after it is unbound. blank lines and lines starting
+// with # are skipped. nothing changes unless the whole of r reads cleanly
+func (m *Map) Read(r io.Reader) error {
+	type entry struct {
+		action   Action
+		bindings []Binding
+	}
+	var entries []entry
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var bs []Binding
+		for _, f := range fields[1:] {
+			b, err := ParseBinding(f)
+			if err != nil {
+				return fmt.Errorf("bindings line %d: %w", line, err)
+			}
+			bs = append(bs, b)
+		}
+		entries = append(entries, entry{Action(fields[0]), bs})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		m.Bind(e.action, e.bindings...)
+	}
+	return nil
+}
+
+// Load reads a bindings file over the current bindings
+func (m *Map) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.Read(f)
+}
+
+// Write puts the bindings in the form Read takes, a starting point for a
+// file of your own
+func (m *Map) Write(w io.Writer) error {
+	width := 0
+	for _, a := range m.actions {
+		width = max(width, len(a))
+	}
+	for _, a := range m.actions {
+		line := fmt.Sprintf("%-*s", width, a)
+		for _, b := range m.bindings[a] {
+			line += "  " + b.String()
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package input
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gfx/platform"
+)
+
+const (
+	jump  Action = "jump"
+	left  Action = "left"
+	shoot Action = "shoot"
+)
+
+func testMap() *Map {
+	m := NewMap()
+	m.Bind(jump, Key(platform.KeySpace), PadButton(0, "a"))
+	m.Bind(left, Key("a"), Key(platform.KeyLeft))
+	m.Bind(shoot, MouseButton(platform.ButtonLeft))
+	return m
+}
+
+func keyDown(k platform.Key) platform.Event {
+	return platform.Event{Kind: platform.KeyPress, Key: k}
+}
+
+func keyUp(k platform.Key) platform.Event {
+	return platform.Event{Kind: platform.KeyRelease, Key: k}
+}
+
+func TestMapRead(t *testing.T) {
+	m := testMap()
+	err := m.Read(strings.NewReader(`# rebinding jump, unbinding shoot
+  jump   key:W   pad1:y
+
+shoot
+dash   key:lshift
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[Action][]Binding{
+		jump:   {Key("w"), PadButton(1, "y")},
+		left:   {Key("a"), Key(platform.KeyLeft)}, // not listed, kept
+		shoot:  nil,
+		"dash": {Key("lshift")},
+	}
+	for a, bs := range want {
+		if got := m.Bindings(a); !slices.Equal(got, bs) {
+			t.Errorf("%s: %v, want %v", a, got, bs)
+		}
+	}
+}
+
+func TestMapReadError(t *testing.T) {
+	// a bad line anywhere leaves every binding as it was, even the lines
+	// before it that read fine
+	m := testMap()
+	before := map[Action][]Binding{}
+	for _, a := range []Action{jump, left, shoot} {
+		before[a] = m.Bindings(a)
+	}
+	err := m.Read(strings.NewReader("jump key:w\nshoot\ndash key:x\nleft key:a mouse:nine\n"))
+	if err == nil || !strings.Contains(err.Error(), "bindings line 4") {
+		t.Fatalf("error %v, want one on line 4", err)
+	}
+	for a, bs := range before {
+		if got := m.Bindings(a); !slices.Equal(got, bs) {
+			t.Errorf("%s changed to %v", a, got)
+		}
+	}
+	if m.Bindings("dash") != nil {
+		t.Error("dash was bound")
+	}
+	var sb strings.Builder
+	m.Write(&sb)
+	if strings.Contains(sb.String(), "dash") {
+		t.Errorf("dash was added to the actions:\n%s", sb.String())
+	}
+}
+
+func TestMapWriteRead(t *testing.T) {
+	// Write's output reads back to the same bindings
+	m := testMap()
+	var sb strings.Builder
+	if err := m.Write(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := "jump   key:space  pad0:a\nleft   key:a  key:left\nshoot  mouse:1\n"
+	if sb.String() != want {
+		t.Errorf("wrote\n%swant\n%s", sb.String(), want)
+	}
+	back := NewMap()
+	if err := back.Read(strings.NewReader(sb.String())); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []Action{jump, left, shoot} {
+		if got := back.Bindings(a); !slices.Equal(got, m.Bindings(a)) {
+			t.Errorf("%s read back as %v, want %v", a, got, m.Bindings(a))
+		}
+	}
+}
+
+// state is an action's Pressed, Held and Released
+type state struct {
+	pressed, held, released bool
+}
+
+func stateOf(m *Map, a Action) state {
+	return state{m.Pressed(a), m.Held(a), m.Released(a)}
+}
+
+func TestMapHandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []platform.Event
+		action Action
+		want   state
+	}{
+		{"nothing", nil, jump, state{}},
+		{"press", []platform.Event{keyDown(platform.KeySpace)}, jump, state{true, true, false}},
+		{"tap", []platform.Event{keyDown(platform.KeySpace), keyUp(platform.KeySpace)}, jump, state{true, false, true}},
+		{"pad", []platform.Event{{Kind: platform.PadDown, Pad: 0, Key: "a"}}, jump, state{true, true, false}},
+		{"other pad", []platform.Event{{Kind: platform.PadDown, Pad: 1, Key: "a"}}, jump, state{}},
+		{"mouse", []platform.Event{{Kind: platform.MouseDown, Button: platform.ButtonLeft}}, shoot, state{true, true, false}},
+		{"other button", []platform.Event{{Kind: platform.MouseDown, Button: platform.ButtonRight}}, shoot, state{}},
+		{"unbound key", []platform.Event{keyDown("q")}, left, state{}},
+		// either binding holds it, it's released when the last one comes up
+		{"both bindings", []platform.Event{keyDown("a"), keyDown(platform.KeyLeft)}, left, state{true, true, false}},
+		{"one of two up", []platform.Event{keyDown("a"), keyDown(platform.KeyLeft), keyUp("a")}, left, state{true, true, false}},
+		{"both up", []platform.Event{keyDown("a"), keyDown(platform.KeyLeft), keyUp("a"), keyUp(platform.KeyLeft)}, left, state{true, false, true}},
+		{"repeat", []platform.Event{keyDown("a"), keyDown("a")}, left, state{true, true, false}},
+		{"release never pressed", []platform.Event{keyUp("a")}, left, state{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testMap()
+			for _, ev := range tt.events {
+				m.Handle(ev)
+			}
+			if got := stateOf(m, tt.action); got != tt.want {
+				t.Errorf("pressed, held, released %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapNext(t *testing.T) {
+	m := testMap()
+	if !m.Handle(keyDown(platform.KeySpace)) {
+		t.Error("Handle didn't claim a bound key")
+	}
+	m.Next()
+	// presses last one update, holds until the key comes up
+	if got := stateOf(m, jump); got != (state{false, true, false}) {
+		t.Errorf("after Next: %v", got)
+	}
+	if m.Handle(keyDown(platform.KeySpace)) {
+		t.Error("Handle claimed a repeat")
+	}
+	if got := stateOf(m, jump); got != (state{false, true, false}) {
+		t.Errorf("after a repeat: %v", got)
+	}
+	m.Handle(keyUp(platform.KeySpace))
+	m.Next()
+	if got := stateOf(m, jump); got != (state{}) {
+		t.Errorf("after release and Next: %v", got)
+	}
+	if m.Handle(keyDown("q")) {
+		t.Error("Handle claimed an unbound key")
+	}
+}
+
+func TestMapSharedBinding(t *testing.T) {
+	// one key bound to two actions drives both
+	m := testMap()
+	m.Bind(shoot, MouseButton(platform.ButtonLeft), Key(platform.KeySpace))
+	m.Handle(keyDown(platform.KeySpace))
+	if !m.Pressed(jump) || !m.Pressed(shoot) {
+		t.Errorf("jump %v, shoot %v, want both pressed", m.Pressed(jump), m.Pressed(shoot))
+	}
+}
+
+func TestMapMouse(t *testing.T) {
+	m := testMap()
+	m.Handle(platform.Event{Kind: platform.MouseMove, X: 10, Y: 20})
+	if x, y := m.Mouse(); x != 10 || y != 20 {
+		t.Errorf("mouse at %d,%d after a move", x, y)
+	}
+	m.Handle(platform.Event{Kind: platform.MouseDown, X: 30, Y: 40, Button: platform.ButtonRight})
+	if x, y := m.Mouse(); x != 30 || y != 40 {
+		t.Errorf("mouse at %d,%d after a click", x, y)
+	}
+	m.Handle(keyDown("a"))
+	if x, y := m.Mouse(); x != 30 || y != 40 {
+		t.Errorf("a key moved the mouse to %d,%d", x, y)
+	}
+}
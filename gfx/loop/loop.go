@@ -0,0 +1,158 @@
+// Package loop runs games at a fixed simulation rate whatever the frame
+// rate: updates happen in fixed steps paid for out of an accumulator of real
+// time, and drawing gets how far into the next step it is so motion can be
+// interpolated. it also measures frame times, shows them in an overlay, and
+// pauses and single steps for debugging. f11 switches fullscreen
+package loop
+
+import (
+	"flag"
+	"time"
+
+	"gfx/framebuffer"
+	"gfx/platform"
+)
+
+// Game is what Run drives
+type Game interface {
+	// Input handles one event, before the frame's updates
+	Input(ev platform.Event)
+	// Update advances the simulation by one step of dt seconds, always the
+	// same dt
+	Update(dt float64)
+	// Draw renders the frame. alpha, 0 to 1, is how far real time has got
+	// from the last update towards the next, for drawing positions between
+	// the previous and current state
+	Draw(fb *framebuffer.Buffer, alpha float64)
+}
+
+// Config is how Run runs, the zero value is 60 updates a second
+type Config struct {
+	Rate int // updates a second, 0 for 60
+	// MaxFrame caps how much time one frame can add, so after a stall the
+	// game slows down instead of running dozens of updates to catch up and
+	// falling further behind. 0 for a quarter second
+	MaxFrame time.Duration
+	// Lockstep runs exactly one update per frame, ignoring the clock, for
+	// headless runs that should come out the same every time
+	Lockstep bool
+	Stats    bool // start with the overlay showing
+
+	// debug keys, swallowed before the game sees them. "" for the defaults,
+	// f3, f6 and f7
+	StatsKey, PauseKey, StepKey platform.Key
+	// FullscreenKey toggles fullscreen, swallowed too. "" for f11
+	FullscreenKey platform.Key
+}
+
+// Register adds -rate and -stats to fs
+func (c *Config) Register(fs *flag.FlagSet) {
+	fs.IntVar(&c.Rate, "rate", 60, "simulation updates a second")
+	fs.BoolVar(&c.Stats, "stats", false, "start with the frame time overlay showing, f3 toggles it")
+}
+
+func (c *Config) defaults() {
+	if c.Rate <= 0 {
+		c.Rate = 60
+	}
+	if c.MaxFrame <= 0 {
+		c.MaxFrame = 250 * time.Millisecond
+	}
+	if c.StatsKey == "" {
+		c.StatsKey = "f3"
+	}
+	if c.PauseKey == "" {
+		c.PauseKey = "f6"
+	}
+	if c.StepKey == "" {
+		c.StepKey = "f7"
+	}
+	if c.FullscreenKey == "" {
+		c.FullscreenKey = platform.KeyFullscreen
+	}
+}
+
+// now is the clock Run reads, the tests swap in their own
+var now = time.Now
+
+// Run plays g on p, drawing into fb, until the platform reports Quit
+func Run(p platform.Platform, fb *framebuffer.Buffer, g Game, cfg Config) error {
+	cfg.defaults()
+	step := time.Second / time.Duration(cfg.Rate)
+	dt := step.Seconds()
+
+	var (
+		acc       time.Duration
+		showStats = cfg.Stats
+		paused    bool
+		stats     = &stats{}
+		last      = now()
+	)
+	for {
+		stepOnce := false
+		for _, ev := range p.Poll() {
+			if ev.Kind == platform.Quit {
+				return nil
+			}
+			ours := ev.Key == cfg.StatsKey || ev.Key == cfg.PauseKey || ev.Key == cfg.StepKey || ev.Key == cfg.FullscreenKey
+			if ours && (ev.Kind == platform.KeyPress || ev.Kind == platform.KeyRelease) {
+				if ev.Kind == platform.KeyPress {
+					switch ev.Key {
+					case cfg.FullscreenKey:
+						if err := p.ToggleFullscreen(); err != nil {
+							return err
+						}
+					case cfg.StatsKey:
+						showStats = !showStats
+					case cfg.PauseKey:
+						paused = !paused
+					case cfg.StepKey:
+						paused, stepOnce = true, true
+					}
+				}
+				continue
+			}
+			g.Input(ev)
+		}
+
+		start := now()
+		frame := start.Sub(last)
+		last = start
+		advance := min(frame, cfg.MaxFrame)
+		if cfg.Lockstep {
+			advance = step
+		}
+
+		steps := 0
+		alpha := 1.0 // paused, the current state is the one to show
+		if paused {
+			acc = 0
+			if stepOnce {
+				g.Update(dt)
+				steps++
+			}
+		} else {
+			acc += advance
+			for acc >= step {
+				g.Update(dt)
+				acc -= step
+				steps++
+			}
+			// in lockstep every frame lands exactly on an update
+			if !cfg.Lockstep {
+				alpha = float64(acc) / float64(step)
+			}
+		}
+		updated := now()
+		g.Draw(fb, alpha)
+		drawn := now()
+
+		stats.add(frame, updated.Sub(start), drawn.Sub(updated), steps)
+		if showStats {
+			stats.overlay(fb, cfg.Rate, alpha, paused)
+		}
+		if err := p.Present(fb); err != nil {
+			return err
+		}
+	}
+}
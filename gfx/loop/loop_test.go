@@ -0,0 +1,218 @@
+package loop
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gfx/framebuffer"
+	"gfx/platform"
+)
+
+// timed is a headless platform on a fake clock, which moves only when a
+// frame is presented, by that frame's time. the last time repeats
+type timed struct {
+	*platform.Headless
+	times []time.Duration
+	n     int
+}
+
+// clock is the fake time, set as the loop's clock for the test
+var clock time.Time
+
+func useClock(t *testing.T) {
+	clock = time.Unix(0, 0)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func (p *timed) Present(fb *framebuffer.Buffer) error {
+	clock = clock.Add(p.times[min(p.n, len(p.times)-1)])
+	p.n++
+	return p.Headless.Present(fb)
+}
+
+// recorder counts the updates before each draw and keeps the alphas. it
+// draws nothing, clearing the frame
+type recorder struct {
+	updates int
+	frames  []drawn
+	events  []platform.Event
+	dts     []float64
+}
+
+type drawn struct {
+	updates int
+	alpha   float64
+}
+
+func (r *recorder) Input(ev platform.Event) { r.events = append(r.events, ev) }
+func (r *recorder) Update(dt float64)       { r.updates++; r.dts = append(r.dts, dt) }
+func (r *recorder) Draw(fb *framebuffer.Buffer, alpha float64) {
+	r.frames = append(r.frames, drawn{r.updates, alpha})
+	r.updates = 0
+	fb.Fill(framebuffer.Color{})
+}
+
+// run plays a recorder for frames frames, each taking the next of times
+func run(t *testing.T, cfg Config, frames int, times []time.Duration, script []platform.Step) (*recorder, *timed) {
+	t.Helper()
+	useClock(t)
+	p := &timed{Headless: platform.NewHeadless(8, 8, frames, script), times: times}
+	g := &recorder{}
+	if err := Run(p, framebuffer.New(8, 8, framebuffer.RGBA32), g, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.frames) != frames {
+		t.Fatalf("drew %d frames, want %d", len(g.frames), frames)
+	}
+	return g, p
+}
+
+func TestRunFixedStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  int
+		times []time.Duration
+	}{
+		{"faster than the rate", 60, []time.Duration{7 * time.Millisecond}},
+		{"slower than the rate", 60, []time.Duration{40 * time.Millisecond}},
+		{"uneven", 50, []time.Duration{5 * time.Millisecond, 33 * time.Millisecond, 1 * time.Millisecond, 20 * time.Millisecond, 61 * time.Millisecond}},
+		{"exactly the rate", 100, []time.Duration{10 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const frames = 50
+			g, _ := run(t, Config{Rate: tt.rate}, frames, tt.times, nil)
+			step := time.Second / time.Duration(tt.rate)
+
+			// frame k has been paid for all the frames before it, so the
+			// updates so far are that time in whole steps, and alpha is
+			// what's left over
+			var elapsed time.Duration
+			total := 0
+			for k, f := range g.frames {
+				if k > 0 {
+					elapsed += tt.times[min(k-1, len(tt.times)-1)]
+				}
+				total += f.updates
+				if want := int(elapsed / step); total != want {
+					t.Fatalf("frame %d: %d updates so far, want %d", k, total, want)
+				}
+				if f.alpha < 0 || f.alpha >= 1 {
+					t.Fatalf("frame %d: alpha %v", k, f.alpha)
+				}
+				if want := float64(elapsed%step) / float64(step); f.alpha != want {
+					t.Errorf("frame %d: alpha %v, want %v", k, f.alpha, want)
+				}
+			}
+			for _, dt := range g.dts {
+				if dt != step.Seconds() {
+					t.Fatalf("update of %v, want %v", dt, step.Seconds())
+				}
+			}
+		})
+	}
+}
+
+func TestRunMaxFrame(t *testing.T) {
+	// a two second stall only makes up MaxFrame's worth of updates, then
+	// things carry on at the rate
+	times := []time.Duration{10 * time.Millisecond, 2 * time.Second, 10 * time.Millisecond}
+	g, _ := run(t, Config{Rate: 100, MaxFrame: 100 * time.Millisecond}, 5, times, nil)
+	var got []int
+	for _, f := range g.frames {
+		got = append(got, f.updates)
+	}
+	if want := []int{0, 1, 10, 1, 1}; !slices.Equal(got, want) {
+		t.Errorf("updates a frame %v, want %v", got, want)
+	}
+
+	// the default is a quarter second
+	g, _ = run(t, Config{Rate: 100}, 3, []time.Duration{time.Minute}, nil)
+	if g.frames[1].updates != 25 {
+		t.Errorf("%d updates after a stall, want 25", g.frames[1].updates)
+	}
+}
+
+func TestRunLockstep(t *testing.T) {
+	// one update a frame whatever the clock says, drawn right on it
+	g, _ := run(t, Config{Lockstep: true}, 20, []time.Duration{0, time.Second, 3 * time.Millisecond}, nil)
+	for k, f := range g.frames {
+		if f.updates != 1 || f.alpha != 1 {
+			t.Errorf("frame %d: %d updates, alpha %v", k, f.updates, f.alpha)
+		}
+	}
+}
+
+func TestRunPauseStep(t *testing.T) {
+	key := func(frame int, kind platform.EventKind, k platform.Key) platform.Step {
+		return platform.Step{Frame: frame, Event: platform.Event{Kind: kind, Key: k}}
+	}
+	script := []platform.Step{
+		key(2, platform.KeyPress, "f6"), // pause
+		key(3, platform.KeyRelease, "f6"),
+		key(3, platform.KeyPress, "space"),
+		key(5, platform.KeyPress, "f7"), // one step
+		key(6, platform.KeyRelease, "f7"),
+		key(7, platform.KeyPress, "f7"), // and another
+		key(7, platform.KeyPress, "f7"), // a second press the same frame is still one
+		key(9, platform.KeyPress, "f6"), // carry on
+		key(9, platform.KeyPress, "f3"),
+		key(9, platform.KeyPress, "f11"),
+	}
+	// a step's time a frame, so running frames take one update each
+	g, p := run(t, Config{Rate: 100}, 12, []time.Duration{10 * time.Millisecond}, script)
+	var updates []int
+	for k, f := range g.frames {
+		updates = append(updates, f.updates)
+		paused := k >= 2 && k < 9
+		if paused && f.alpha != 1 {
+			t.Errorf("paused frame %d drawn at alpha %v, want 1", k, f.alpha)
+		}
+	}
+	// unpausing starts from an empty accumulator, the time spent paused
+	// doesn't come back as a burst of updates
+	if want := []int{0, 1, 0, 0, 0, 1, 0, 1, 0, 1, 1, 1}; !slices.Equal(updates, want) {
+		t.Errorf("updates a frame %v, want %v", updates, want)
+	}
+
+	// the debug keys are swallowed, everything else reaches the game
+	if want := []platform.Event{{Kind: platform.KeyPress, Key: "space"}}; !slices.Equal(g.events, want) {
+		t.Errorf("game saw %v, want %v", g.events, want)
+	}
+	if !p.Fullscreen() {
+		t.Error("f11 didn't toggle fullscreen")
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	// the overlay draws over the game's frame, f3 turns it off again
+	script := []platform.Step{{Frame: 3, Event: platform.Event{Kind: platform.KeyPress, Key: "f3"}}}
+	useClock(t)
+	p := &timed{Headless: platform.NewHeadless(200, 100, 3, script), times: []time.Duration{time.Millisecond}}
+	if err := Run(p, framebuffer.New(200, 100, framebuffer.RGBA32), &recorder{}, Config{Stats: true}); err != nil {
+		t.Fatal(err)
+	}
+	if blank(p.Last()) {
+		t.Error("no overlay with Stats set")
+	}
+
+	useClock(t)
+	p = &timed{Headless: platform.NewHeadless(200, 100, 5, script), times: []time.Duration{time.Millisecond}}
+	if err := Run(p, framebuffer.New(200, 100, framebuffer.RGBA32), &recorder{}, Config{Stats: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !blank(p.Last()) {
+		t.Error("overlay still drawn after f3")
+	}
+}
+
+func blank(fb *framebuffer.Buffer) bool {
+	for _, b := range fb.Pix {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package loop
+
+import (
+	"fmt"
+	"time"
+
+	"gfx/font"
+	"gfx/framebuffer"
+	"gfx/raster"
+)
+
+// statsWindow is how many frames the overlay averages over
+const statsWindow = 60
+
+// stats keeps the last statsWindow frames' timings
+type stats struct {
+	frame, update, draw [statsWindow]time.Duration
+	steps               [statsWindow]int
+	n                   int // frames recorded, the newest at (n-1) % statsWindow
+}
+
+func (s *stats) add(frame, update, draw time.Duration, steps int) {
+	k := s.n % statsWindow
+	s.frame[k], s.update[k], s.draw[k], s.steps[k] = frame, update, draw, steps
+	s.n++
+}
+
+// summary averages the window: frames a second, and mean and worst frame,
+// update and draw times
+func (s *stats) summary() (fps float64, frame, worst, update, draw time.Duration, steps float64) {
+	n := min(s.n, statsWindow)
+	if n == 0 {
+		return
+	}
+	var total time.Duration
+	for k := range n {
+		total += s.frame[k]
+		worst = max(worst, s.frame[k])
+		update += s.update[k]
+		draw += s.draw[k]
+		steps += float64(s.steps[k])
+	}
+	if total > 0 {
+		fps = float64(n) / total.Seconds()
+	}
+	d := time.Duration(n)
+	return fps, total / d, worst, update / d, draw / d, steps / float64(n)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// overlay puts the numbers in the top left corner over a dark panel
+func (s *stats) overlay(fb *framebuffer.Buffer, rate int, alpha float64, paused bool) {
+	fps, frame, worst, update, draw, steps := s.summary()
+	text := fmt.Sprintf("fps %.1f\nframe %.2f ms, worst %.2f\nupdate %.2f ms, draw %.2f\nsteps %.2f at %d/s, alpha %.2f",
+		fps, ms(frame), ms(worst), ms(update), ms(draw), steps, rate, alpha)
+	if paused {
+		text += "\npaused"
+	}
+
+	const scale, pad = 2, 4
+	st := font.Style{Color: framebuffer.RGB(255, 255, 0), Scale: scale}
+	w, h := font.Default().Measure(text)
+	raster.FillRect(fb, 0, 0, w*scale+2*pad, h*scale+2*pad, framebuffer.RGBA(0, 0, 0, 180))
+	font.DrawText(fb, pad, pad, text, st)
+}
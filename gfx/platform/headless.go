@@ -0,0 +1,220 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gfx/framebuffer"
+)
+
+// Step is input the script delivers once Frame frames have been presented
+type Step struct {
+	Frame int
+	Event Event
+}
+
+// Headless is a platform with no window. presented frames are copied so
+// they can be inspected, and Poll hands out the script's events as the frame
+// count reaches them. after the last frame it reports Quit, so a program's
+// usual main loop ends by itself
+type Headless struct {
+	width, height int
+	frames        int // quit once this many have been presented, 0 for never
+	script        []Step
+	presented     int
+	last          *framebuffer.Buffer
+	fullscreen    bool
+}
+
+// NewHeadless runs frames frames of width x height with the script's input.
+// the script must be sorted by frame, as ParseScript returns it
+func NewHeadless(width, height, frames int, script []Step) *Headless {
+	return &Headless{width: width, height: height, frames: frames, script: script}
+}
+
+func (h *Headless) Size() (int, int) {
+	return h.width, h.height
+}
+
+func (h *Headless) Present(fb *framebuffer.Buffer) error {
+	if h.last == nil || h.last.Width != fb.Width || h.last.Height != fb.Height || h.last.Format != fb.Format {
+		h.last = framebuffer.New(fb.Width, fb.Height, fb.Format)
+	}
+	copy(h.last.Pix, fb.Pix)
+	h.presented++
+	return nil
+}
+
+func (h *Headless) Poll() []Event {
+	var out []Event
+	for len(h.script) > 0 && h.script[0].Frame <= h.presented {
+		ev := h.script[0].Event
+		if ev.Kind == Resize {
+			h.width, h.height = ev.X, ev.Y
+		}
+		out = append(out, ev)
+		h.script = h.script[1:]
+	}
+	if h.frames > 0 && h.presented >= h.frames {
+		out = append(out, Event{Kind: Quit})
+	}
+	return out
+}
+
+// ToggleFullscreen only remembers, see Fullscreen
+func (h *Headless) ToggleFullscreen() error {
+	h.fullscreen = !h.fullscreen
+	return nil
+}
+
+// Fullscreen reports whether the program last asked for fullscreen
+func (h *Headless) Fullscreen() bool {
+	return h.fullscreen
+}
+
+func (h *Headless) Close() error {
+	return nil
+}
+
+// Frames is how many frames have been presented
+func (h *Headless) Frames() int {
+	return h.presented
+}
+
+// Last is a copy of the last presented frame, nil before the first
+func (h *Headless) Last() *framebuffer.Buffer {
+	return h.last
+}
+
+// ParseScript reads input for Headless, one event per line as the frame it
+// arrives on and what happens:
+//
+//	10 keydown space
+//	12 keyup space
+//	30 mousedown 400 300 1
+//	31 mousemove 420 310
+//	40 mouseup 420 310 1
+//	50 paddown 0 start
+//	52 padup 0 start
+//	60 resize 1280 720
+//	90 quit
+//
+// blank lines and lines starting with # are skipped
+func ParseScript(r io.Reader) ([]Step, error) {
+	var steps []Step
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		step, err := parseStep(fields)
+		if err != nil {
+			return nil, fmt.Errorf("script line %d: %w", line, err)
+		}
+		if len(steps) > 0 && step.Frame < steps[len(steps)-1].Frame {
+			return nil, fmt.Errorf("script line %d: frame %d comes before the line above", line, step.Frame)
+		}
+		steps = append(steps, step)
+	}
+	return steps, sc.Err()
+}
+
+// LoadScript reads a script file
+func LoadScript(path string) ([]Step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScript(f)
+}
+
+func parseStep(fields []string) (Step, error) {
+	if len(fields) < 2 {
+		return Step{}, fmt.Errorf("want a frame and an event")
+	}
+	frame, err := strconv.Atoi(fields[0])
+	if err != nil || frame < 0 {
+		return Step{}, fmt.Errorf("bad frame %q", fields[0])
+	}
+	args := fields[2:]
+	nums := func(n int) ([]int, error) {
+		if len(args) != n {
+			return nil, fmt.Errorf("%s wants %d numbers", fields[1], n)
+		}
+		out := make([]int, n)
+		for k, a := range args {
+			v, err := strconv.Atoi(a)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad number %q", fields[1], a)
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+
+	ev := Event{}
+	switch fields[1] {
+	case "quit":
+		ev.Kind = Quit
+	case "keydown", "keyup":
+		if len(args) != 1 {
+			return Step{}, fmt.Errorf("%s wants a key", fields[1])
+		}
+		ev.Kind = KeyPress
+		if fields[1] == "keyup" {
+			ev.Kind = KeyRelease
+		}
+		ev.Key = Key(strings.ToLower(args[0]))
+	case "mousedown", "mouseup":
+		v, err := nums(3)
+		if err != nil {
+			return Step{}, err
+		}
+		ev.Kind = MouseDown
+		if fields[1] == "mouseup" {
+			ev.Kind = MouseUp
+		}
+		ev.X, ev.Y, ev.Button = v[0], v[1], v[2]
+	case "paddown", "padup":
+		if len(args) != 2 {
+			return Step{}, fmt.Errorf("%s wants a pad and a button", fields[1])
+		}
+		pad, err := strconv.Atoi(args[0])
+		if err != nil || pad < 0 {
+			return Step{}, fmt.Errorf("%s: bad pad %q", fields[1], args[0])
+		}
+		ev.Kind = PadDown
+		if fields[1] == "padup" {
+			ev.Kind = PadUp
+		}
+		ev.Pad, ev.Key = pad, Key(strings.ToLower(args[1]))
+	case "mousemove":
+		v, err := nums(2)
+		if err != nil {
+			return Step{}, err
+		}
+		ev.Kind = MouseMove
+		ev.X, ev.Y = v[0], v[1]
+	case "resize":
+		v, err := nums(2)
+		if err != nil {
+			return Step{}, err
+		}
+		if v[0] <= 0 || v[1] <= 0 {
+			return Step{}, fmt.Errorf("resize wants a positive size")
+		}
+		ev.Kind = Resize
+		ev.X, ev.Y = v[0], v[1]
+	default:
+		return Step{}, fmt.Errorf("unknown event %q", fields[1])
+	}
+	return Step{frame, ev}, nil
+}
@@ -0,0 +1,166 @@
+package platform
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+func TestParseScript(t *testing.T) {
+	steps, err := ParseScript(strings.NewReader(`# every kind of event
+10 keydown Space
+12 keyup space
+
+30 mousedown 400 300 1
+31 mousemove 420 310
+40 mouseup 420 310 1
+50 paddown 1 Start
+52 padup 1 start
+60 resize 1280 720
+60 keydown f11
+90 quit
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Step{
+		{10, Event{Kind: KeyPress, Key: KeySpace}},
+		{12, Event{Kind: KeyRelease, Key: KeySpace}},
+		{30, Event{Kind: MouseDown, X: 400, Y: 300, Button: ButtonLeft}},
+		{31, Event{Kind: MouseMove, X: 420, Y: 310}},
+		{40, Event{Kind: MouseUp, X: 420, Y: 310, Button: ButtonLeft}},
+		{50, Event{Kind: PadDown, Pad: 1, Key: "start"}},
+		{52, Event{Kind: PadUp, Pad: 1, Key: "start"}},
+		{60, Event{Kind: Resize, X: 1280, Y: 720}},
+		{60, Event{Kind: KeyPress, Key: KeyFullscreen}},
+		{90, Event{Kind: Quit}},
+	}
+	if !slices.Equal(steps, want) {
+		t.Errorf("got %+v\nwant %+v", steps, want)
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		name, script, err string
+	}{
+		{"no event", "10\n", "line 1: want a frame and an event"},
+		{"bad frame", "ten keydown a\n", `line 1: bad frame "ten"`},
+		{"negative frame", "-1 quit\n", `line 1: bad frame "-1"`},
+		{"unknown event", "1 jump\n", `line 1: unknown event "jump"`},
+		{"no key", "1 keydown\n", "line 1: keydown wants a key"},
+		{"two keys", "1 keyup a b\n", "line 1: keyup wants a key"},
+		{"short mouse", "1 mousedown 10 20\n", "line 1: mousedown wants 3 numbers"},
+		{"bad mouse", "1 mousemove 10 x\n", `line 1: mousemove: bad number "x"`},
+		{"no button", "1 paddown 0\n", "line 1: paddown wants a pad and a button"},
+		{"bad pad", "1 padup -1 a\n", `line 1: padup: bad pad "-1"`},
+		{"empty resize", "1 resize 0 600\n", "line 1: resize wants a positive size"},
+		{"out of order", "# comment\n5 quit\n\n4 quit\n", "line 4: frame 4 comes before the line above"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := ParseScript(strings.NewReader(tt.script))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+			if steps != nil {
+				t.Errorf("returned %v along with the error", steps)
+			}
+		})
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	h := NewHeadless(4, 3, 3, []Step{
+		{0, Event{Kind: KeyPress, Key: KeySpace}},
+		{1, Event{Kind: KeyRelease, Key: KeySpace}},
+		{1, Event{Kind: Resize, X: 8, Y: 6}},
+	})
+	if h.Last() != nil {
+		t.Error("a frame before any were presented")
+	}
+	if evs := h.Poll(); !slices.Equal(evs, []Event{{Kind: KeyPress, Key: KeySpace}}) {
+		t.Errorf("frame 0: %v", evs)
+	}
+	if evs := h.Poll(); len(evs) != 0 {
+		t.Errorf("frame 0 again: %v", evs)
+	}
+
+	fb := framebuffer.New(4, 3, framebuffer.RGBA32)
+	fb.Set(1, 2, framebuffer.RGB(9, 8, 7))
+	if err := h.Present(fb); err != nil {
+		t.Fatal(err)
+	}
+	// the frame is copied, drawing the next one doesn't change it
+	fb.Fill(framebuffer.Color{})
+	if got := h.Last().Get(1, 2); got != framebuffer.RGB(9, 8, 7) {
+		t.Errorf("presented frame has %v", got)
+	}
+
+	evs := h.Poll()
+	if len(evs) != 2 || evs[0].Kind != KeyRelease || evs[1].Kind != Resize {
+		t.Errorf("frame 1: %v", evs)
+	}
+	if w, hh := h.Size(); w != 8 || hh != 6 {
+		t.Errorf("size %dx%d after the resize", w, hh)
+	}
+
+	h.Present(framebuffer.New(8, 6, framebuffer.RGB24))
+	if h.Last().Width != 8 || h.Last().Format != framebuffer.RGB24 {
+		t.Errorf("last frame is %dx%d %v", h.Last().Width, h.Last().Height, h.Last().Format)
+	}
+	if evs := h.Poll(); len(evs) != 0 {
+		t.Errorf("frame 2: %v", evs)
+	}
+	h.Present(fb)
+	if evs := h.Poll(); !slices.Equal(evs, []Event{{Kind: Quit}}) {
+		t.Errorf("after the last frame: %v, want quit", evs)
+	}
+	if h.Frames() != 3 {
+		t.Errorf("%d frames", h.Frames())
+	}
+}
+
+func TestShowHeadless(t *testing.T) {
+	// Show ends on escape, after toggling fullscreen on the way
+	h := NewHeadless(4, 3, 0, []Step{
+		{2, Event{Kind: KeyPress, Key: KeyFullscreen}},
+		{5, Event{Kind: KeyPress, Key: KeyEscape}},
+	})
+	if err := Show(h, framebuffer.New(4, 3, framebuffer.RGBA32)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Frames() != 5 || !h.Fullscreen() {
+		t.Errorf("%d frames, fullscreen %v", h.Frames(), h.Fullscreen())
+	}
+
+	// ShowResizable draws again when the size changes, and only then
+	h = NewHeadless(4, 3, 6, []Step{{3, Event{Kind: Resize, X: 5, Y: 2}}})
+	var sizes [][2]int
+	err := ShowResizable(h, framebuffer.RGBA32, func(fb *framebuffer.Buffer) {
+		sizes = append(sizes, [2]int{fb.Width, fb.Height})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sizes, [][2]int{{4, 3}, {5, 2}}) {
+		t.Errorf("drew at %v", sizes)
+	}
+}
+
+func TestOpenHeadless(t *testing.T) {
+	o := Options{Headless: 10, Script: "testdata/missing.script"}
+	if _, err := o.Open("test", 4, 3); err == nil {
+		t.Error("no error for a missing script")
+	}
+	o.Script = ""
+	p, err := o.Open("test", 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*Headless); !ok {
+		t.Errorf("-headless opened a %T", p)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build nosdl
+
+package platform
+
+import "errors"
+
+// openWindow fails in builds without sdl, only Headless is available
+func openWindow(title string, width, height int) (Platform, error) {
+	return nil, errors.New("built with -tags nosdl, run with -headless")
+}
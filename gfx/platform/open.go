@@ -0,0 +1,51 @@
+package platform
+
+import "flag"
+
+// Options chooses between a window, the terminal and a headless run from
+// the command line
+type Options struct {
+	Headless    int    // frames to run without a window, 0 opens one
+	Script      string // headless input script, see ParseScript
+	Terminal    bool   // draw in the terminal instead of a window
+	TerminalFPS int    // frames a second Terminal shows at most
+	Fullscreen  bool   // start the window fullscreen
+}
+
+// Register adds -headless, -script, -term, -term-fps and -fullscreen to fs
+func (o *Options) Register(fs *flag.FlagSet) {
+	fs.IntVar(&o.Headless, "headless", 0, "run this many frames without a window, then quit")
+	fs.StringVar(&o.Script, "script", "", "headless: input to play back, one `file` line per event like \"10 keydown space\"")
+	fs.BoolVar(&o.Terminal, "term", false, "draw in the terminal with ansi colors instead of opening a window, e.g. over ssh")
+	fs.IntVar(&o.TerminalFPS, "term-fps", 30, "term: frames a second to draw at most")
+	fs.BoolVar(&o.Fullscreen, "fullscreen", false, "start fullscreen, f11 switches back and forth")
+}
+
+// Open makes the platform the options ask for
+func (o *Options) Open(title string, width, height int) (Platform, error) {
+	if o.Terminal && o.Headless <= 0 {
+		t, err := NewTerminal(width, height, o.TerminalFPS)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if o.Headless <= 0 {
+		p, err := openWindow(title, width, height)
+		if err == nil && o.Fullscreen {
+			if err = p.ToggleFullscreen(); err != nil {
+				p.Close()
+				return nil, err
+			}
+		}
+		return p, err
+	}
+	var script []Step
+	if o.Script != "" {
+		var err error
+		if script, err = LoadScript(o.Script); err != nil {
+			return nil, err
+		}
+	}
+	return NewHeadless(width, height, o.Headless, script), nil
+}
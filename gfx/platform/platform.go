@@ -0,0 +1,153 @@
+// Package platform is what the programs draw to and read input from: a
+// window, presenting a finished framebuffer, and polling input. SDL opens a
+// real window, Terminal draws in the terminal it's run from, and Headless
+// keeps frames in memory and plays back scripted input so programs can run
+// without a display, e.g. in go test. building with -tags nosdl leaves sdl
+// out entirely, for machines without its libraries
+package platform
+
+import "gfx/framebuffer"
+
+// Platform shows frames and collects input
+type Platform interface {
+	// Size is the frame size that fills the window pixel for pixel. it
+	// changes when the window does, and a Resize event says so
+	Size() (width, height int)
+	// Present shows a finished frame. frames of any size can be presented,
+	// ones that don't match Size are scaled to fit, keeping their aspect
+	// with black bars around them, and mouse positions are reported in the
+	// frame's pixels
+	Present(fb *framebuffer.Buffer) error
+	// Poll returns the input that arrived since the last call
+	Poll() []Event
+	// ToggleFullscreen switches between a window and the whole screen,
+	// platforms without windows ignore it
+	ToggleFullscreen() error
+	Close() error
+}
+
+// EventKind says which fields of an Event are meaningful
+type EventKind int
+
+const (
+	Quit       EventKind = iota // the window was closed
+	KeyPress                    // Key
+	KeyRelease                  // Key
+	MouseDown                   // X, Y and Button
+	MouseUp                     // X, Y and Button
+	MouseMove                   // X, Y
+	PadDown                     // Pad and Key, the button's name
+	PadUp                       // Pad and Key
+	Resize                      // X, Y are the new Size
+)
+
+// kindNames are also how scripts spell them
+var kindNames = []string{"quit", "keydown", "keyup", "mousedown", "mouseup", "mousemove", "paddown", "padup", "resize"}
+
+func (k EventKind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// Key names a key by what's printed on it, lowercase: "a", "1", "space",
+// "escape", "left". the same names work in scripts
+type Key string
+
+const (
+	KeyEscape Key = "escape"
+	KeySpace  Key = "space"
+	KeyReturn Key = "return"
+	KeyUp     Key = "up"
+	KeyDown   Key = "down"
+	KeyLeft   Key = "left"
+	KeyRight  Key = "right"
+
+	// KeyFullscreen is the key Show and the game loop toggle fullscreen with
+	KeyFullscreen Key = "f11"
+)
+
+// gamepad buttons are named by their position on an xbox style pad: "a",
+// "b", "x", "y", "back", "guide", "start", "leftstick", "rightstick",
+// "leftshoulder", "rightshoulder", "dpup", "dpdown", "dpleft", "dpright"
+
+// mouse buttons
+const (
+	ButtonLeft   = 1
+	ButtonMiddle = 2
+	ButtonRight  = 3
+)
+
+// Event is one piece of input
+type Event struct {
+	Kind   EventKind
+	Key    Key
+	X, Y   int // mouse position in frame pixels
+	Button int
+	Pad    int // which gamepad, numbered from 0 as they're plugged in
+}
+
+// Show presents the same frame until the window is closed or escape is
+// pressed, for programs that draw one picture. a window of another size
+// shows it scaled to fit
+func Show(p Platform, fb *framebuffer.Buffer) error {
+	for {
+		for _, ev := range p.Poll() {
+			if done, err := handleShow(p, ev); done || err != nil {
+				return err
+			}
+		}
+		if err := p.Present(fb); err != nil {
+			return err
+		}
+	}
+}
+
+// ShowResizable is Show for pictures cheap enough to draw again at each new
+// window size: draw fills a frame of Size, and is called again with a new
+// one whenever the window changes
+func ShowResizable(p Platform, format framebuffer.Format, draw func(fb *framebuffer.Buffer)) error {
+	var fb *framebuffer.Buffer
+	for {
+		for _, ev := range p.Poll() {
+			if done, err := handleShow(p, ev); done || err != nil {
+				return err
+			}
+		}
+		if w, h := p.Size(); fb == nil || fb.Width != w || fb.Height != h {
+			fb = framebuffer.New(max(w, 1), max(h, 1), format)
+			draw(fb)
+		}
+		if err := p.Present(fb); err != nil {
+			return err
+		}
+	}
+}
+
+// handleShow quits on Quit or escape and toggles fullscreen
+func handleShow(p Platform, ev Event) (done bool, err error) {
+	switch {
+	case ev.Kind == Quit || ev.Kind == KeyPress && ev.Key == KeyEscape:
+		return true, nil
+	case ev.Kind == KeyPress && ev.Key == KeyFullscreen:
+		return false, p.ToggleFullscreen()
+	}
+	return false, nil
+}
+
+// Letterbox fits a width x height frame into an outW x outH output as large
+// as it goes without changing its aspect, centered. x, y, w, h is where it
+// lands
+func Letterbox(width, height, outW, outH int) (x, y, w, h int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0, outW, outH
+	}
+	// compare outW/width with outH/height without dividing
+	if outW*height <= outH*width {
+		w, h = outW, height*outW/width
+	} else {
+		w, h = width*outH/height, outH
+	}
+	return (outW - w) / 2, (outH - h) / 2, w, h
+}
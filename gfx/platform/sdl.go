@@ -0,0 +1,253 @@
+//go:build !nosdl
+
+package platform
+
+import (
+	"strings"
+
+	"gfx/framebuffer"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// SDL is a window that shows frames through a streaming texture. the window
+// can be resized and made fullscreen, frames are scaled to fit it
+type SDL struct {
+	window    *sdl.Window
+	renderer  *sdl.Renderer
+	tex       *sdl.Texture
+	texFormat framebuffer.Format
+	pads      map[sdl.JoystickID]*pad
+	// where the last frame landed in the window's pixels, and its size, for
+	// turning mouse positions into frame pixels
+	dst        sdl.Rect
+	fbW, fbH   int
+	fullscreen bool
+}
+
+// pad is an open game controller and the number events report it as
+type pad struct {
+	ctrl  *sdl.GameController
+	index int
+}
+
+// NewSDL initializes sdl's video and game controllers and opens a width x
+// height window. Present waits for vsync, so a loop that presents every
+// frame runs at the display's rate
+func NewSDL(title string, width, height int) (*SDL, error) {
+	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_GAMECONTROLLER); err != nil {
+		return nil, err
+	}
+	p := &SDL{pads: map[sdl.JoystickID]*pad{}}
+	var err error
+	flags := uint32(sdl.WINDOW_SHOWN | sdl.WINDOW_RESIZABLE | sdl.WINDOW_ALLOW_HIGHDPI)
+	p.window, err = sdl.CreateWindow(title, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(width), int32(height), flags)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.renderer, err = sdl.CreateRenderer(p.window, -1, sdl.RENDERER_ACCELERATED|sdl.RENDERER_PRESENTVSYNC)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func openWindow(title string, width, height int) (Platform, error) {
+	p, err := NewSDL(title, width, height)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Size is in the display's pixels, which on high dpi screens is more than
+// the size the window was asked for
+func (p *SDL) Size() (int, int) {
+	w, h, err := p.renderer.GetOutputSize()
+	if err != nil {
+		return 0, 0
+	}
+	return int(w), int(h)
+}
+
+// ToggleFullscreen uses the desktop's resolution rather than changing the
+// display mode, so it's quick and leaves other windows alone
+func (p *SDL) ToggleFullscreen() error {
+	var flags uint32
+	if !p.fullscreen {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	if err := p.window.SetFullscreen(flags); err != nil {
+		return err
+	}
+	p.fullscreen = !p.fullscreen
+	return nil
+}
+
+// Renderer is the window's renderer, for programs that draw with sdl
+// directly as well
+func (p *SDL) Renderer() *sdl.Renderer {
+	return p.renderer
+}
+
+func (p *SDL) Present(fb *framebuffer.Buffer) error {
+	// the texture follows the buffer, made again if its size or format change
+	if p.tex != nil {
+		_, _, w, h, err := p.tex.Query()
+		if err != nil || int(w) != fb.Width || int(h) != fb.Height || p.texFormat != fb.Format {
+			p.tex.Destroy()
+			p.tex = nil
+		}
+	}
+	if p.tex == nil {
+		tex, err := fb.NewTexture(p.renderer)
+		if err != nil {
+			return err
+		}
+		p.tex, p.texFormat = tex, fb.Format
+	}
+
+	if err := fb.Upload(p.tex); err != nil {
+		return err
+	}
+	outW, outH, err := p.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	x, y, w, h := Letterbox(fb.Width, fb.Height, int(outW), int(outH))
+	p.dst = sdl.Rect{X: int32(x), Y: int32(y), W: int32(w), H: int32(h)}
+	p.fbW, p.fbH = fb.Width, fb.Height
+	if w != int(outW) || h != int(outH) {
+		p.renderer.SetDrawColor(0, 0, 0, 255)
+		p.renderer.Clear() // the bars
+	}
+	if err := p.renderer.Copy(p.tex, nil, &p.dst); err != nil {
+		return err
+	}
+	p.renderer.Present()
+	return nil
+}
+
+func (p *SDL) Poll() []Event {
+	var out []Event
+	for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
+		switch e := ev.(type) {
+		case *sdl.QuitEvent:
+			out = append(out, Event{Kind: Quit})
+		case *sdl.KeyboardEvent:
+			if e.Repeat != 0 {
+				continue // held keys stay down until their KeyRelease
+			}
+			kind := KeyPress
+			if e.Type == sdl.KEYUP {
+				kind = KeyRelease
+			}
+			out = append(out, Event{Kind: kind, Key: keyName(e.Keysym.Sym)})
+		case *sdl.WindowEvent:
+			if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+				w, h := p.Size()
+				out = append(out, Event{Kind: Resize, X: w, Y: h})
+			}
+		case *sdl.MouseButtonEvent:
+			kind := MouseDown
+			if e.Type == sdl.MOUSEBUTTONUP {
+				kind = MouseUp
+			}
+			x, y := p.toFrame(e.X, e.Y)
+			out = append(out, Event{Kind: kind, X: x, Y: y, Button: int(e.Button)})
+		case *sdl.MouseMotionEvent:
+			x, y := p.toFrame(e.X, e.Y)
+			out = append(out, Event{Kind: MouseMove, X: x, Y: y})
+		case *sdl.ControllerDeviceEvent:
+			p.controllerDevice(e)
+		case *sdl.ControllerButtonEvent:
+			pd, ok := p.pads[e.Which]
+			if !ok {
+				continue
+			}
+			kind := PadDown
+			if e.Type == sdl.CONTROLLERBUTTONUP {
+				kind = PadUp
+			}
+			name := sdl.GameControllerGetStringForButton(sdl.GameControllerButton(e.Button))
+			out = append(out, Event{Kind: kind, Key: Key(name), Pad: pd.index})
+		}
+	}
+	return out
+}
+
+// controllerDevice opens pads as they're plugged in, sdl sends an added
+// event for each one already there at startup too. a pad takes the lowest
+// free number, so unplugging and replugging keeps the player it was
+func (p *SDL) controllerDevice(e *sdl.ControllerDeviceEvent) {
+	switch e.Type {
+	case sdl.CONTROLLERDEVICEADDED:
+		ctrl := sdl.GameControllerOpen(int(e.Which)) // a device index here
+		if ctrl == nil {
+			return
+		}
+		id := ctrl.Joystick().InstanceID()
+		if _, open := p.pads[id]; open {
+			ctrl.Close()
+			return
+		}
+		index := 0
+		for taken := true; taken; {
+			taken = false
+			for _, pd := range p.pads {
+				if pd.index == index {
+					taken = true
+					index++
+				}
+			}
+		}
+		p.pads[id] = &pad{ctrl, index}
+	case sdl.CONTROLLERDEVICEREMOVED:
+		if pd, ok := p.pads[e.Which]; ok { // an instance id here
+			pd.ctrl.Close()
+			delete(p.pads, e.Which)
+		}
+	}
+}
+
+// toFrame turns a mouse position in window coordinates into the last
+// frame's pixels. on high dpi screens window coordinates are coarser than
+// pixels, and the frame may be scaled and off to one side
+func (p *SDL) toFrame(x, y int32) (int, int) {
+	if p.dst.W == 0 || p.dst.H == 0 {
+		return int(x), int(y)
+	}
+	winW, winH := p.window.GetSize()
+	outW, outH := p.Size()
+	if winW > 0 && winH > 0 {
+		x = x * int32(outW) / winW
+		y = y * int32(outH) / winH
+	}
+	fx := int(x-p.dst.X) * p.fbW / int(p.dst.W)
+	fy := int(y-p.dst.Y) * p.fbH / int(p.dst.H)
+	return fx, fy
+}
+
+// keyName turns sdl's key names, "Left Shift" or "A", into ours
+func keyName(sym sdl.Keycode) Key {
+	return Key(strings.ReplaceAll(strings.ToLower(sdl.GetKeyName(sym)), " ", ""))
+}
+
+// Close destroys the window and shuts sdl down
+func (p *SDL) Close() error {
+	for _, pd := range p.pads {
+		pd.ctrl.Close()
+	}
+	if p.tex != nil {
+		p.tex.Destroy()
+	}
+	if p.renderer != nil {
+		p.renderer.Destroy()
+	}
+	if p.window != nil {
+		p.window.Destroy()
+	}
+	sdl.Quit()
+	return nil
+}
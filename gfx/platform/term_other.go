@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package platform
+
+import "errors"
+
+type termState struct{}
+
+var errNoTerm = errors.New("the terminal backend needs linux or macos")
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errNoTerm
+}
+
+func restoreTerm(fd int, st *termState) error {
+	return errNoTerm
+}
+
+func termSize(fd int) (cols, rows int, err error) {
+	return 0, 0, errNoTerm
+}
@@ -0,0 +1,54 @@
+//go:build linux || darwin
+
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// termState is the terminal's mode before raw mode, for restoring it
+type termState struct {
+	ios syscall.Termios
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw turns off line buffering, echo and signal keys on fd the way
+// cfmakeraw does, so every key arrives as it's pressed
+func makeRaw(fd int) (*termState, error) {
+	var old termState
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old.ios)); err != nil {
+		return nil, err
+	}
+	raw := old.ios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return &old, nil
+}
+
+func restoreTerm(fd int, st *termState) error {
+	return ioctl(fd, ioctlSetTermios, unsafe.Pointer(&st.ios))
+}
+
+// termSize is the terminal's size in character cells
+func termSize(fd int) (cols, rows int, err error) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
@@ -0,0 +1,390 @@
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gfx/framebuffer"
+)
+
+// terminals only say when a key is pressed, and then repeat it while it's
+// held. a key counts as released once it stops repeating: the first repeat
+// comes after the keyboard's repeat delay, the rest much closer together
+const (
+	releaseFirst  = 600 * time.Millisecond
+	releaseRepeat = 100 * time.Millisecond
+)
+
+// Terminal draws frames into a terminal as text, for machines without a
+// display or over ssh. each character cell is an upper half block with the
+// top pixel as its color and the bottom one as the background, in 24 bit
+// ansi color, so cells come out about square. the frame is scaled down to fit
+// the terminal, and input is read from stdin in raw mode, mouse included
+type Terminal struct {
+	width, height int
+	interval      time.Duration // Present waits this long between frames
+	last          time.Time
+	in, out       *os.File
+	w             *bufio.Writer
+	state         *termState
+	events        chan Event
+	held          map[Key]heldKey
+
+	// the picture's layout in the terminal, redone when either size changes
+	cols, rows int
+	fbW, fbH   int
+	left, top  int // cell the picture starts at, zero based
+	pixW, pixH int // picture size in pixels, two per cell vertically
+	cells      []cell
+	redraw     bool
+}
+
+// cell is what one character shows
+type cell struct {
+	top, bottom framebuffer.Color
+}
+
+type heldKey struct {
+	seen     time.Time
+	repeated bool
+}
+
+// NewTerminal puts the terminal into raw mode and takes it over with the
+// alternate screen. programs draw width x height frames and Present shows
+// at most fps of them a second, since there's no vsync to wait for
+func NewTerminal(width, height, fps int) (*Terminal, error) {
+	t := &Terminal{
+		width:  width,
+		height: height,
+		in:     os.Stdin,
+		out:    os.Stdout,
+		events: make(chan Event, 256),
+		held:   map[Key]heldKey{},
+	}
+	if fps > 0 {
+		t.interval = time.Second / time.Duration(fps)
+	}
+	var err error
+	if t.state, err = makeRaw(int(t.in.Fd())); err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal: %w", err)
+	}
+	t.w = bufio.NewWriterSize(t.out, 1<<16)
+	// alternate screen, hide the cursor, report mouse buttons and drags in
+	// sgr form
+	t.w.WriteString("\x1b[?1049h\x1b[?25l\x1b[?1002h\x1b[?1006h")
+	if err := t.w.Flush(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	go t.read()
+	return t, nil
+}
+
+func (t *Terminal) Size() (int, int) {
+	return t.width, t.height
+}
+
+// read runs for the life of the program, stdin can't be unblocked
+func (t *Terminal) read() {
+	buf := make([]byte, 1024)
+	for {
+		n, err := t.in.Read(buf)
+		for _, ev := range parseInput(buf[:n]) {
+			t.events <- ev
+		}
+		if err != nil {
+			t.events <- Event{Kind: Quit}
+			return
+		}
+	}
+}
+
+func (t *Terminal) Poll() []Event {
+	now := time.Now()
+	var out []Event
+	for more := true; more; {
+		select {
+		case ev := <-t.events:
+			switch ev.Kind {
+			case KeyPress:
+				_, down := t.held[ev.Key]
+				t.held[ev.Key] = heldKey{seen: now, repeated: down}
+				if down {
+					continue // a repeat
+				}
+			case MouseDown, MouseUp, MouseMove:
+				ev.X, ev.Y = t.toFrame(ev.X, ev.Y)
+			}
+			out = append(out, ev)
+		default:
+			more = false
+		}
+	}
+	for k, h := range t.held {
+		wait := releaseFirst
+		if h.repeated {
+			wait = releaseRepeat
+		}
+		if now.Sub(h.seen) > wait {
+			delete(t.held, k)
+			out = append(out, Event{Kind: KeyRelease, Key: k})
+		}
+	}
+	return out
+}
+
+// toFrame turns a one based cell position into frame pixels. rows are
+// reported whole, so y lands on the cell's top pixel
+func (t *Terminal) toFrame(col, row int) (int, int) {
+	if t.pixW == 0 || t.pixH == 0 {
+		return 0, 0
+	}
+	x := (col - 1 - t.left) * t.fbW / t.pixW
+	y := (row - 1 - t.top) * 2 * t.fbH / t.pixH
+	return x, y
+}
+
+func (t *Terminal) Present(fb *framebuffer.Buffer) error {
+	if wait := t.interval - time.Since(t.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	t.last = time.Now()
+
+	cols, rows, err := termSize(int(t.out.Fd()))
+	if err != nil {
+		return err
+	}
+	if cols != t.cols || rows != t.rows || fb.Width != t.fbW || fb.Height != t.fbH {
+		t.layout(cols, rows, fb.Width, fb.Height)
+	}
+	if t.pixW == 0 || t.pixH == 0 {
+		return nil
+	}
+
+	// only cells that changed are sent, which is most of the speed over ssh
+	var fg, bg framebuffer.Color
+	colored := false
+	curX, curY := -1, -1
+	for r := range t.pixH / 2 {
+		for c := range t.pixW {
+			cl := cell{t.sample(fb, c, 2*r), t.sample(fb, c, 2*r+1)}
+			k := r*t.pixW + c
+			if !t.redraw && t.cells[k] == cl {
+				continue
+			}
+			t.cells[k] = cl
+			if curX != c || curY != r {
+				fmt.Fprintf(t.w, "\x1b[%d;%dH", t.top+r+1, t.left+c+1)
+			}
+			if !colored || cl.top != fg {
+				fmt.Fprintf(t.w, "\x1b[38;2;%d;%d;%dm", cl.top.R, cl.top.G, cl.top.B)
+			}
+			if !colored || cl.bottom != bg {
+				fmt.Fprintf(t.w, "\x1b[48;2;%d;%d;%dm", cl.bottom.R, cl.bottom.G, cl.bottom.B)
+			}
+			fg, bg, colored = cl.top, cl.bottom, true
+			t.w.WriteString("▀")
+			curX, curY = c+1, r
+		}
+	}
+	t.redraw = false
+	if colored {
+		t.w.WriteString("\x1b[0m")
+	}
+	return t.w.Flush()
+}
+
+// layout fits the frame into the terminal keeping its aspect, centered
+func (t *Terminal) layout(cols, rows, fbW, fbH int) {
+	t.cols, t.rows, t.fbW, t.fbH = cols, rows, fbW, fbH
+	t.pixW, t.pixH = 0, 0
+	if cols > 0 && rows > 0 && fbW > 0 && fbH > 0 {
+		scale := min(float64(cols)/float64(fbW), float64(2*rows)/float64(fbH))
+		t.pixW = max(int(float64(fbW)*scale), 1)
+		t.pixH = max(int(float64(fbH)*scale)&^1, 2)
+	}
+	t.left = (cols - t.pixW) / 2
+	t.top = (rows - t.pixH/2) / 2
+	t.cells = make([]cell, t.pixW*t.pixH/2)
+	t.redraw = true
+	t.w.WriteString("\x1b[0m\x1b[2J")
+}
+
+// sample averages the block of frame pixels that lands on picture pixel
+// x, y. blocks are at least one pixel, so small frames scale up
+func (t *Terminal) sample(fb *framebuffer.Buffer, x, y int) framebuffer.Color {
+	x0, y0 := x*t.fbW/t.pixW, y*t.fbH/t.pixH
+	x1 := max((x+1)*t.fbW/t.pixW, x0+1)
+	y1 := max((y+1)*t.fbH/t.pixH, y0+1)
+	var r, g, b, n int
+	for sy := y0; sy < y1; sy++ {
+		for sx := x0; sx < x1; sx++ {
+			c := fb.Get(sx, sy)
+			r += int(c.R)
+			g += int(c.G)
+			b += int(c.B)
+			n++
+		}
+	}
+	return framebuffer.RGB(byte(r/n), byte(g/n), byte(b/n))
+}
+
+// ToggleFullscreen does nothing, the picture always fills the terminal
+func (t *Terminal) ToggleFullscreen() error {
+	return nil
+}
+
+// Close gives the terminal back the way it was
+func (t *Terminal) Close() error {
+	t.w.WriteString("\x1b[0m\x1b[?1006l\x1b[?1002l\x1b[?25h\x1b[?1049l")
+	err := t.w.Flush()
+	if rerr := restoreTerm(int(t.in.Fd()), t.state); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// parseInput turns what a raw terminal sends into events. mouse positions
+// are left as one based cells for Poll to convert. ctrl-c is Quit, since raw
+// mode stops it from being a signal
+func parseInput(b []byte) []Event {
+	var out []Event
+	for len(b) > 0 {
+		if b[0] == 0x1b && len(b) > 1 {
+			if ev, n, ok := parseEscape(b); n > 0 {
+				if ok {
+					out = append(out, ev)
+				}
+				b = b[n:]
+				continue
+			}
+		}
+		c := b[0]
+		b = b[1:]
+		var key Key
+		switch {
+		case c == 3:
+			out = append(out, Event{Kind: Quit})
+			continue
+		case c == 0x1b:
+			key = KeyEscape
+		case c == '\r' || c == '\n':
+			key = KeyReturn
+		case c == '\t':
+			key = "tab"
+		case c == 0x7f || c == 8:
+			key = "backspace"
+		case c == ' ':
+			key = KeySpace
+		case c >= 'A' && c <= 'Z':
+			key = Key(rune(c - 'A' + 'a'))
+		case c > ' ' && c < 0x7f:
+			key = Key(rune(c))
+		default:
+			continue
+		}
+		out = append(out, Event{Kind: KeyPress, Key: key})
+	}
+	return out
+}
+
+// csiKeys are the keys sent as ESC [ number ~
+var csiKeys = map[int]Key{
+	2: "insert", 3: "delete", 5: "pageup", 6: "pagedown",
+	15: "f5", 17: "f6", 18: "f7", 19: "f8", 20: "f9", 21: "f10", 23: "f11", 24: "f12",
+}
+
+// parseEscape reads one escape sequence from the start of b, returning how
+// many bytes it took, 0 if it isn't one, and ok if it means an event
+func parseEscape(b []byte) (ev Event, n int, ok bool) {
+	arrow := func(c byte) (Key, bool) {
+		switch c {
+		case 'A':
+			return KeyUp, true
+		case 'B':
+			return KeyDown, true
+		case 'C':
+			return KeyRight, true
+		case 'D':
+			return KeyLeft, true
+		case 'H':
+			return "home", true
+		case 'F':
+			return "end", true
+		}
+		return "", false
+	}
+
+	switch b[1] {
+	case 'O': // arrows in application mode, and f1 to f4
+		if len(b) < 3 {
+			return Event{}, 0, false
+		}
+		if k, found := arrow(b[2]); found {
+			return Event{Kind: KeyPress, Key: k}, 3, true
+		}
+		if b[2] >= 'P' && b[2] <= 'S' {
+			return Event{Kind: KeyPress, Key: Key("f" + strconv.Itoa(int(b[2]-'P')+1))}, 3, true
+		}
+		return Event{}, 3, false
+	case '[':
+	default:
+		return Event{}, 0, false
+	}
+
+	// ESC [ parameters final, parameters being 0x30 to 0x3f
+	i := 2
+	for i < len(b) && b[i] >= 0x30 && b[i] <= 0x3f {
+		i++
+	}
+	if i == len(b) {
+		return Event{}, len(b), false // cut off, drop it
+	}
+	params, final := b[2:i], b[i]
+	n = i + 1
+
+	if len(params) > 0 && params[0] == '<' && (final == 'M' || final == 'm') {
+		ev, ok := parseMouse(params[1:], final == 'M')
+		return ev, n, ok
+	}
+	if k, found := arrow(final); found {
+		return Event{Kind: KeyPress, Key: k}, n, true
+	}
+	if final == '~' {
+		first, _, _ := bytes.Cut(params, []byte(";"))
+		code, err := strconv.Atoi(string(first))
+		if k, found := csiKeys[code]; err == nil && found {
+			return Event{Kind: KeyPress, Key: k}, n, true
+		}
+	}
+	return Event{}, n, false
+}
+
+// parseMouse reads an sgr mouse report, "button;col;row". the button's low
+// bits say which, 32 marks a drag and 64 the wheel, which isn't reported
+func parseMouse(params []byte, press bool) (Event, bool) {
+	var v [3]int
+	for k, f := range bytes.SplitN(params, []byte(";"), 3) {
+		v[k], _ = strconv.Atoi(string(f))
+	}
+	if v[0]&64 != 0 {
+		return Event{}, false
+	}
+	ev := Event{X: v[1], Y: v[2]}
+	switch {
+	case v[0]&32 != 0:
+		ev.Kind = MouseMove
+	case press:
+		ev.Kind = MouseDown
+	default:
+		ev.Kind = MouseUp
+	}
+	if ev.Kind != MouseMove {
+		ev.Button = v[0]&3 + 1
+	}
+	return ev, true
+}
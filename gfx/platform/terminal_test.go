@@ -0,0 +1,125 @@
+package platform
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func press(k Key) Event {
+	return Event{Kind: KeyPress, Key: k}
+}
+
+func TestParseInput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Event
+	}{
+		{"letters", "aZ9", []Event{press("a"), press("z"), press("9")}},
+		{"punctuation", "?~", []Event{press("?"), press("~")}},
+		{"space", " ", []Event{press(KeySpace)}},
+		{"return", "\r\n", []Event{press(KeyReturn), press(KeyReturn)}},
+		{"tab and backspace", "\t\x7f\b", []Event{press("tab"), press("backspace"), press("backspace")}},
+		{"ctrl-c", "a\x03", []Event{press("a"), {Kind: Quit}}},
+		{"other control keys", "\x01\x00\x1a", nil},
+		{"escape", "\x1b", []Event{press(KeyEscape)}},
+		{"alt", "\x1bx", []Event{press(KeyEscape), press("x")}},
+		{"two escapes", "\x1b\x1b", []Event{press(KeyEscape), press(KeyEscape)}},
+
+		{"arrows", "\x1b[A\x1b[B\x1b[C\x1b[D", []Event{press(KeyUp), press(KeyDown), press(KeyRight), press(KeyLeft)}},
+		{"application arrows", "\x1bOA\x1bOD", []Event{press(KeyUp), press(KeyLeft)}},
+		{"modified arrow", "\x1b[1;5C", []Event{press(KeyRight)}},
+		{"home and end", "\x1b[H\x1bOF", []Event{press("home"), press("end")}},
+		{"f1 to f4", "\x1bOP\x1bOQ\x1bOR\x1bOS", []Event{press("f1"), press("f2"), press("f3"), press("f4")}},
+		{"f5 to f12", "\x1b[15~\x1b[17~\x1b[18~\x1b[19~\x1b[20~\x1b[21~\x1b[23~\x1b[24~",
+			[]Event{press("f5"), press("f6"), press("f7"), press("f8"), press("f9"), press("f10"), press("f11"), press("f12")}},
+		{"editing keys", "\x1b[2~\x1b[3;2~\x1b[5~\x1b[6~", []Event{press("insert"), press("delete"), press("pageup"), press("pagedown")}},
+		{"unknown sequences", "\x1b[99~\x1b[5n\x1bOZa", []Event{press("a")}},
+		{"cut off", "a\x1b[1;", []Event{press("a")}},
+		{"cut off after O", "\x1bO", []Event{press(KeyEscape), press("o")}},
+
+		{"mouse down", "\x1b[<0;10;5M", []Event{{Kind: MouseDown, X: 10, Y: 5, Button: ButtonLeft}}},
+		{"mouse up", "\x1b[<2;10;5m", []Event{{Kind: MouseUp, X: 10, Y: 5, Button: ButtonRight}}},
+		{"middle button", "\x1b[<1;1;1M", []Event{{Kind: MouseDown, X: 1, Y: 1, Button: ButtonMiddle}}},
+		{"drag", "\x1b[<32;11;6M", []Event{{Kind: MouseMove, X: 11, Y: 6}}},
+		{"wheel", "\x1b[<64;10;5M\x1b[<65;10;5M", nil},
+		{"click between keys", "w\x1b[<0;3;4M\x1b[<0;3;4ms", []Event{
+			press("w"),
+			{Kind: MouseDown, X: 3, Y: 4, Button: ButtonLeft},
+			{Kind: MouseUp, X: 3, Y: 4, Button: ButtonLeft},
+			press("s"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInput([]byte(tt.in)); !slices.Equal(got, tt.want) {
+				t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestTerminal() *Terminal {
+	return &Terminal{
+		events: make(chan Event, 16),
+		held:   map[Key]heldKey{},
+		w:      bufio.NewWriter(io.Discard),
+	}
+}
+
+func TestTerminalToFrame(t *testing.T) {
+	tests := []struct {
+		name                   string
+		cols, rows, fbW, fbH   int
+		col, row, wantX, wantY int
+	}{
+		// two pixels a cell vertically, so 80x24 cells fit 80x48
+		{"filled", 80, 24, 160, 96, 1, 1, 0, 0},
+		{"filled corner", 80, 24, 160, 96, 80, 24, 158, 92},
+		// a wide terminal puts bars either side, 10 columns each
+		{"pillarboxed", 100, 24, 160, 96, 11, 1, 0, 0},
+		{"pillarboxed middle", 100, 24, 160, 96, 50, 12, 78, 44},
+		{"in the bar", 100, 24, 160, 96, 1, 1, -20, 0},
+	}
+	for _, tt := range tests {
+		term := newTestTerminal()
+		term.layout(tt.cols, tt.rows, tt.fbW, tt.fbH)
+		if x, y := term.toFrame(tt.col, tt.row); x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: cell %d,%d is %d,%d, want %d,%d", tt.name, tt.col, tt.row, x, y, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestTerminalKeyRelease(t *testing.T) {
+	term := newTestTerminal()
+	term.events <- press("a")
+	if evs := term.Poll(); !slices.Equal(evs, []Event{press("a")}) {
+		t.Fatalf("first press: %v", evs)
+	}
+
+	// repeats while it's held are swallowed
+	term.events <- press("a")
+	if evs := term.Poll(); len(evs) != 0 {
+		t.Fatalf("repeat: %v", evs)
+	}
+	if !term.held["a"].repeated {
+		t.Fatal("repeat not noted")
+	}
+
+	// once the repeats stop, it comes up
+	term.held["a"] = heldKey{seen: time.Now().Add(-2 * releaseRepeat), repeated: true}
+	if evs := term.Poll(); !slices.Equal(evs, []Event{{Kind: KeyRelease, Key: "a"}}) {
+		t.Fatalf("after the repeats stop: %v", evs)
+	}
+
+	// a key that hasn't repeated yet waits out the keyboard's repeat delay
+	term.events <- press("b")
+	term.Poll()
+	term.held["b"] = heldKey{seen: time.Now().Add(-2 * releaseRepeat)}
+	if evs := term.Poll(); len(evs) != 0 {
+		t.Fatalf("released before the repeat delay: %v", evs)
+	}
+}
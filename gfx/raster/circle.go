@@ -0,0 +1,73 @@
+package raster
+
+import "gfx/framebuffer"
+
+// Circle outlines a circle of radius r around cx, cy with the midpoint
+// algorithm: walk one octant from the top, stepping inward whenever the
+// midpoint between the two candidate pixels falls outside, and mirror it
+func Circle(fb *framebuffer.Buffer, cx, cy, r int, c framebuffer.Color) {
+	if r < 0 {
+		return
+	}
+	if r == 0 {
+		Blend(fb, cx, cy, c)
+		return
+	}
+	// the mirrors coincide on the axes and the diagonal, skip the repeats
+	// so translucent outlines don't darken there
+	plot4 := func(a, b int) {
+		Blend(fb, cx+a, cy+b, c)
+		Blend(fb, cx-a, cy-b, c)
+		if b != 0 {
+			Blend(fb, cx+a, cy-b, c)
+			Blend(fb, cx-a, cy+b, c)
+		}
+	}
+	x, y := r, 0
+	d := 1 - r
+	for x >= y {
+		plot4(x, y)
+		if x != y {
+			if y == 0 {
+				Blend(fb, cx, cy+x, c)
+				Blend(fb, cx, cy-x, c)
+			} else {
+				plot4(y, x)
+			}
+		}
+
+		y++
+		if d < 0 {
+			d += 2*y + 1
+		} else {
+			x--
+			d += 2*(y-x) + 1
+		}
+	}
+}
+
+// FillCircle fills every pixel within r of cx, cy, one horizontal span per
+// row
+func FillCircle(fb *framebuffer.Buffer, cx, cy, r int, c framebuffer.Color) {
+	if r < 0 {
+		return
+	}
+	for dy := -r; dy <= r; dy++ {
+		half := isqrt(r*r - dy*dy)
+		span(fb, cx-half, cx+half, cy+dy, c)
+	}
+}
+
+// isqrt is the largest integer whose square is at most n
+func isqrt(n int) int {
+	x := 0
+	for bit := 1 << 30; bit > 0; bit >>= 2 {
+		if n >= x+bit {
+			n -= x + bit
+			x = x>>1 + bit
+		} else {
+			x >>= 1
+		}
+	}
+	return x
+}
@@ -0,0 +1,112 @@
+package raster
+
+import (
+	"math"
+
+	"gfx/framebuffer"
+)
+
+// Line draws a one pixel line from x0, y0 to x1, y1 inclusive with
+// bresenham's algorithm, integers only
+func Line(fb *framebuffer.Buffer, x0, y0, x1, y1 int, c framebuffer.Color) {
+	line(fb, x0, y0, x1, y1, c, true)
+}
+
+// line leaves out the end point unless last is set, so lines joined end to
+// end don't blend the shared pixel twice
+func line(fb *framebuffer.Buffer, x0, y0, x1, y1 int, c framebuffer.Color, last bool) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	// err tracks how far the ideal line is from the current pixel, in
+	// units that keep it an integer for both axes
+	err := dx + dy
+	for {
+		end := x0 == x1 && y0 == y1
+		if !end || last {
+			Blend(fb, x0, y0, c)
+		}
+		if end {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// LineAA draws an antialiased line between two points in pixel space with
+// xiaolin wu's algorithm: each step along the major axis covers the two
+// pixels straddling the line, weighted by how close it passes to each.
+// pixel centers are at integer coordinates
+func LineAA(fb *framebuffer.Buffer, x0, y0, x1, y1 float64, c framebuffer.Color) {
+	steep := math.Abs(y1-y0) > math.Abs(x1-x0)
+	if steep {
+		x0, y0 = y0, x0
+		x1, y1 = y1, x1
+	}
+	if x0 > x1 {
+		x0, x1 = x1, x0
+		y0, y1 = y1, y0
+	}
+	plot := func(x, y int, coverage float64) {
+		if steep {
+			x, y = y, x
+		}
+		Blend(fb, x, y, withCoverage(c, coverage))
+	}
+
+	dx := x1 - x0
+	gradient := 1.0
+	if dx != 0 {
+		gradient = (y1 - y0) / dx
+	}
+
+	// the end points cover the part of their pixel the line reaches into
+	endpoint := func(x, y, gap float64) int {
+		xe := math.Round(x)
+		ye := y + gradient*(xe-x)
+		yi := math.Floor(ye)
+		f := ye - yi
+		plot(int(xe), int(yi), (1-f)*gap)
+		plot(int(xe), int(yi)+1, f*gap)
+		return int(xe)
+	}
+	xs := endpoint(x0, y0, 1-frac(x0+0.5))
+	xe := endpoint(x1, y1, frac(x1+0.5))
+	if xs == xe {
+		return
+	}
+
+	y := y0 + gradient*(float64(xs+1)-x0)
+	for x := xs + 1; x < xe; x++ {
+		yi := math.Floor(y)
+		f := y - yi
+		plot(x, int(yi), 1-f)
+		plot(x, int(yi)+1, f)
+		y += gradient
+	}
+}
+
+func frac(x float64) float64 {
+	return x - math.Floor(x)
+}
@@ -0,0 +1,56 @@
+package raster
+
+import (
+	"math"
+	"slices"
+
+	"gfx/framebuffer"
+)
+
+// Point is a polygon vertex in pixel space, pixel centers at integers
+type Point struct {
+	X, Y float64
+}
+
+// FillPolygon fills a closed polygon by scanlines, even-odd, so self
+// intersecting outlines leave holes where they overlap. a pixel is filled
+// when its center is inside, with left and top edges counting as inside, so
+// polygons sharing an edge don't overlap
+func FillPolygon(fb *framebuffer.Buffer, pts []Point, c framebuffer.Color) {
+	if len(pts) < 3 {
+		return
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		minY = min(minY, p.Y)
+		maxY = max(maxY, p.Y)
+	}
+	y0 := max(int(math.Ceil(minY)), 0)
+	y1 := min(int(math.Ceil(maxY))-1, fb.Height-1)
+
+	var xs []float64
+	for y := y0; y <= y1; y++ {
+		fy := float64(y)
+		xs = xs[:0]
+		for k, a := range pts {
+			b := pts[(k+1)%len(pts)]
+			// half open in y, so a vertex between two edges counts once
+			if (a.Y <= fy && fy < b.Y) || (b.Y <= fy && fy < a.Y) {
+				xs = append(xs, a.X+(fy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+		slices.Sort(xs)
+		for k := 0; k+1 < len(xs); k += 2 {
+			span(fb, int(math.Ceil(xs[k])), int(math.Ceil(xs[k+1]))-1, y, c)
+		}
+	}
+}
+
+// Polygon outlines a closed polygon with one pixel lines between its
+// vertices rounded to the nearest pixel
+func Polygon(fb *framebuffer.Buffer, pts []Point, c framebuffer.Color) {
+	for k, a := range pts {
+		b := pts[(k+1)%len(pts)]
+		line(fb, int(math.Round(a.X)), int(math.Round(a.Y)), int(math.Round(b.X)), int(math.Round(b.Y)), c, false)
+	}
+}
@@ -0,0 +1,88 @@
+// Package raster draws lines, circles, rectangles and polygons into a
+// framebuffer on the cpu. everything clips to the buffer and blends colors
+// with alpha below 255 over what's already there
+package raster
+
+import "gfx/framebuffer"
+
+// Blend draws c over the pixel at x, y by its alpha, the usual source over
+// compositing. opaque colors just overwrite
+func Blend(fb *framebuffer.Buffer, x, y int, c framebuffer.Color) {
+	if c.A == 255 {
+		fb.Set(x, y, c)
+		return
+	}
+	if c.A == 0 || !fb.In(x, y) {
+		return
+	}
+	fb.Set(x, y, Over(c, fb.Get(x, y)))
+}
+
+// Over composites src on top of dst
+func Over(src, dst framebuffer.Color) framebuffer.Color {
+	a := int(src.A)
+	inv := 255 - a
+	// dst's share is weighted by its own alpha too, so drawing over a
+	// transparent buffer leaves src as it was
+	da := int(dst.A) * inv / 255
+	outA := a + da
+	if outA == 0 {
+		return framebuffer.Color{}
+	}
+	mix := func(s, d byte) byte {
+		return byte((int(s)*a + int(d)*da + outA/2) / outA)
+	}
+	return framebuffer.RGBA(mix(src.R, dst.R), mix(src.G, dst.G), mix(src.B, dst.B), byte(outA))
+}
+
+// withCoverage scales c's alpha by a coverage between 0 and 1, for
+// antialiased edges
+func withCoverage(c framebuffer.Color, coverage float64) framebuffer.Color {
+	c.A = byte(float64(c.A)*max(0, min(coverage, 1)) + 0.5)
+	return c
+}
+
+// HLine fills the span x0..x1 inclusive on row y, in either order
+func HLine(fb *framebuffer.Buffer, x0, x1, y int, c framebuffer.Color) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	span(fb, x0, x1, y, c)
+}
+
+// span is HLine for callers that already ordered the ends, it's empty when
+// x1 < x0
+func span(fb *framebuffer.Buffer, x0, x1, y int, c framebuffer.Color) {
+	if y < 0 || y >= fb.Height {
+		return
+	}
+	x0 = max(x0, 0)
+	x1 = min(x1, fb.Width-1)
+	for x := x0; x <= x1; x++ {
+		Blend(fb, x, y, c)
+	}
+}
+
+// FillRect fills w x h pixels with the top left corner at x, y
+func FillRect(fb *framebuffer.Buffer, x, y, w, h int, c framebuffer.Color) {
+	for row := max(y, 0); row < min(y+h, fb.Height); row++ {
+		span(fb, x, x+w-1, row, c)
+	}
+}
+
+// Rect outlines the rectangle FillRect would fill
+func Rect(fb *framebuffer.Buffer, x, y, w, h int, c framebuffer.Color) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	span(fb, x, x+w-1, y, c)
+	if h > 1 {
+		span(fb, x, x+w-1, y+h-1, c)
+	}
+	for row := y + 1; row < y+h-1; row++ {
+		Blend(fb, x, row, c)
+		if w > 1 {
+			Blend(fb, x+w-1, row, c)
+		}
+	}
+}
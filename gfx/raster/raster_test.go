@@ -0,0 +1,360 @@
+package raster
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+var white = framebuffer.RGB(255, 255, 255)
+
+// picture draws a buffer as rows of # for drawn pixels and . for untouched
+// ones, to compare against the expected shape
+func picture(fb *framebuffer.Buffer) string {
+	var sb strings.Builder
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if fb.Get(x, y).A != 0 {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// rows joins the lines of an expected picture
+func rows(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name           string
+		x0, y0, x1, y1 int
+		want           string
+	}{
+		{"point", 2, 1, 2, 1, rows(
+			".....",
+			"..#..",
+			".....",
+		)},
+		{"horizontal", 0, 1, 4, 1, rows(
+			".....",
+			"#####",
+			".....",
+		)},
+		{"vertical", 1, 0, 1, 2, rows(
+			".#...",
+			".#...",
+			".#...",
+		)},
+		{"diagonal", 0, 0, 2, 2, rows(
+			"#....",
+			".#...",
+			"..#..",
+		)},
+		{"shallow", 0, 0, 4, 2, rows(
+			"#....",
+			".##..",
+			"...##",
+		)},
+		{"steep", 0, 0, 1, 2, rows(
+			"#....",
+			".#...",
+			".#...",
+		)},
+		{"backwards", 4, 1, 0, 1, rows(
+			".....",
+			"#####",
+			".....",
+		)},
+		{"clipped", -3, -3, 7, 7, rows(
+			"#....",
+			".#...",
+			"..#..",
+		)},
+		{"outside", -5, -1, 10, -1, rows(
+			".....",
+			".....",
+			".....",
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := framebuffer.New(5, 3, framebuffer.RGBA32)
+			Line(fb, tt.x0, tt.y0, tt.x1, tt.y1, white)
+			if got := picture(fb); got != tt.want {
+				t.Errorf("got\n%swant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineConnected(t *testing.T) {
+	// a line has one pixel per step along its major axis, both ends
+	// included
+	for _, end := range [][2]int{{9, 3}, {3, 9}, {-9, 4}, {-2, -9}, {7, -7}} {
+		fb := framebuffer.New(21, 21, framebuffer.RGBA32)
+		Line(fb, 10, 10, 10+end[0], 10+end[1], white)
+		n := 0
+		for y := range fb.Height {
+			for x := range fb.Width {
+				if fb.Get(x, y).A != 0 {
+					n++
+				}
+			}
+		}
+		if want := max(abs(end[0]), abs(end[1])) + 1; n != want {
+			t.Errorf("line to %v: %d pixels, want %d", end, n, want)
+		}
+		if fb.Get(10, 10).A == 0 || fb.Get(10+end[0], 10+end[1]).A == 0 {
+			t.Errorf("line to %v is missing an end", end)
+		}
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name string
+		draw func(fb *framebuffer.Buffer)
+		want string
+	}{
+		{"hline", func(fb *framebuffer.Buffer) { HLine(fb, 3, 1, 2, white) }, rows(
+			".....",
+			".....",
+			".###.",
+			".....",
+		)},
+		{"hline clipped", func(fb *framebuffer.Buffer) { HLine(fb, -2, 9, 0, white) }, rows(
+			"#####",
+			".....",
+			".....",
+			".....",
+		)},
+		{"rect", func(fb *framebuffer.Buffer) { FillRect(fb, 1, 1, 3, 2, white) }, rows(
+			".....",
+			".###.",
+			".###.",
+			".....",
+		)},
+		{"rect clipped", func(fb *framebuffer.Buffer) { FillRect(fb, -1, 2, 3, 5, white) }, rows(
+			".....",
+			".....",
+			"##...",
+			"##...",
+		)},
+		{"rect empty", func(fb *framebuffer.Buffer) { FillRect(fb, 1, 1, 0, 3, white) }, rows(
+			".....",
+			".....",
+			".....",
+			".....",
+		)},
+		{"outline", func(fb *framebuffer.Buffer) { Rect(fb, 0, 0, 4, 4, white) }, rows(
+			"####.",
+			"#..#.",
+			"#..#.",
+			"####.",
+		)},
+		{"outline one wide", func(fb *framebuffer.Buffer) { Rect(fb, 2, 0, 1, 3, white) }, rows(
+			"..#..",
+			"..#..",
+			"..#..",
+			".....",
+		)},
+		{"circle", func(fb *framebuffer.Buffer) { FillCircle(fb, 2, 2, 1, white) }, rows(
+			".....",
+			"..#..",
+			".###.",
+			"..#..",
+		)},
+		{"circle outline", func(fb *framebuffer.Buffer) { Circle(fb, 2, 2, 2, white) }, rows(
+			".###.",
+			"#...#",
+			"#...#",
+			"#...#",
+		)},
+		{"triangle", func(fb *framebuffer.Buffer) {
+			FillPolygon(fb, []Point{{0, 0}, {4, 0}, {0, 4}}, white)
+		}, rows(
+			"####.",
+			"###..",
+			"##...",
+			"#....",
+		)},
+		{"square", func(fb *framebuffer.Buffer) {
+			// pixel centers on the right and bottom edges are left out
+			FillPolygon(fb, []Point{{1, 1}, {4, 1}, {4, 3}, {1, 3}}, white)
+		}, rows(
+			".....",
+			".###.",
+			".###.",
+			".....",
+		)},
+		{"bow tie", func(fb *framebuffer.Buffer) {
+			// a crossed outline fills the two triangles either side of
+			// where it crosses
+			FillPolygon(fb, []Point{{0, 0}, {5, 4}, {5, 0}, {0, 4}}, white)
+		}, rows(
+			".....",
+			"##..#",
+			"#####",
+			"##..#",
+		)},
+		{"wound twice", func(fb *framebuffer.Buffer) {
+			// even-odd: inside twice is outside
+			FillPolygon(fb, []Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}, {4, 0}, {4, 4}, {0, 4}}, white)
+		}, rows(
+			".....",
+			".....",
+			".....",
+			".....",
+		)},
+		{"degenerate", func(fb *framebuffer.Buffer) {
+			FillPolygon(fb, []Point{{0, 0}, {4, 4}}, white)
+		}, rows(
+			".....",
+			".....",
+			".....",
+			".....",
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := framebuffer.New(5, 4, framebuffer.RGBA32)
+			tt.draw(fb)
+			if got := picture(fb); got != tt.want {
+				t.Errorf("got\n%swant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillPolygonSharedEdge(t *testing.T) {
+	// two triangles splitting a square cover it once, translucent pixels
+	// blended twice would come out darker than the rest
+	fb := framebuffer.New(8, 8, framebuffer.RGBA32)
+	half := framebuffer.RGBA(255, 255, 255, 128)
+	FillPolygon(fb, []Point{{0, 0}, {8, 0}, {8, 8}}, half)
+	FillPolygon(fb, []Point{{0, 0}, {8, 8}, {0, 8}}, half)
+	for y := range 8 {
+		for x := range 8 {
+			if got := fb.Get(x, y); got != half {
+				t.Fatalf("pixel %d,%d is %v, want %v", x, y, got, half)
+			}
+		}
+	}
+}
+
+func TestOutlinesDrawOnce(t *testing.T) {
+	// translucent outlines don't blend the corners or mirrored points twice
+	half := framebuffer.RGBA(255, 255, 255, 128)
+	for name, draw := range map[string]func(fb *framebuffer.Buffer){
+		"rect":    func(fb *framebuffer.Buffer) { Rect(fb, 1, 1, 6, 5, half) },
+		"circle":  func(fb *framebuffer.Buffer) { Circle(fb, 4, 4, 3, half) },
+		"polygon": func(fb *framebuffer.Buffer) { Polygon(fb, []Point{{1, 1}, {7, 2}, {3, 7}}, half) },
+	} {
+		fb := framebuffer.New(9, 9, framebuffer.RGBA32)
+		draw(fb)
+		for y := range 9 {
+			for x := range 9 {
+				if got := fb.Get(x, y); got.A != 0 && got != half {
+					t.Errorf("%s: pixel %d,%d is %v, blended more than once", name, x, y, got)
+				}
+			}
+		}
+	}
+}
+
+func TestFillCircleRadius(t *testing.T) {
+	// every pixel within r is filled and none outside
+	fb := framebuffer.New(21, 21, framebuffer.RGBA32)
+	FillCircle(fb, 10, 10, 7, white)
+	for y := range 21 {
+		for x := range 21 {
+			d := (x-10)*(x-10) + (y-10)*(y-10)
+			if in := fb.Get(x, y).A != 0; in != (d <= 49) {
+				t.Errorf("pixel %d,%d at distance² %d: filled %v", x, y, d, in)
+			}
+		}
+	}
+}
+
+func TestIsqrt(t *testing.T) {
+	for n := range 10000 {
+		r := isqrt(n)
+		if r*r > n || (r+1)*(r+1) <= n {
+			t.Fatalf("isqrt(%d) = %d", n, r)
+		}
+	}
+}
+
+func TestOver(t *testing.T) {
+	tests := []struct {
+		name     string
+		src, dst framebuffer.Color
+		want     framebuffer.Color
+	}{
+		{"opaque", framebuffer.RGBA(10, 20, 30, 255), framebuffer.RGB(200, 200, 200), framebuffer.RGBA(10, 20, 30, 255)},
+		{"invisible", framebuffer.RGBA(10, 20, 30, 0), framebuffer.RGB(200, 100, 50), framebuffer.RGB(200, 100, 50)},
+		{"half", framebuffer.RGBA(255, 0, 0, 128), framebuffer.RGB(0, 0, 255), framebuffer.RGBA(128, 0, 127, 255)},
+		{"over nothing", framebuffer.RGBA(255, 0, 0, 128), framebuffer.Color{}, framebuffer.RGBA(255, 0, 0, 128)},
+		{"nothing over nothing", framebuffer.Color{}, framebuffer.Color{}, framebuffer.Color{}},
+	}
+	for _, tt := range tests {
+		if got := Over(tt.src, tt.dst); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlend(t *testing.T) {
+	fb := framebuffer.New(2, 1, framebuffer.RGB24)
+	fb.Fill(framebuffer.RGB(0, 0, 200))
+	Blend(fb, 0, 0, framebuffer.RGBA(200, 0, 0, 128))
+	Blend(fb, 1, 0, framebuffer.RGBA(200, 0, 0, 0))
+	Blend(fb, 5, 0, framebuffer.RGBA(200, 0, 0, 128)) // clipped, no panic
+	if got, want := fb.Get(0, 0), framebuffer.RGB(100, 0, 100); got != want {
+		t.Errorf("blended %v, want %v", got, want)
+	}
+	if got, want := fb.Get(1, 0), framebuffer.RGB(0, 0, 200); got != want {
+		t.Errorf("transparent blend changed the pixel to %v", got)
+	}
+}
+
+func TestLineAA(t *testing.T) {
+	// a line along a row of pixel centers covers them fully and nothing else
+	fb := framebuffer.New(8, 3, framebuffer.RGBA32)
+	LineAA(fb, 1, 1, 6, 1, white)
+	if got, want := picture(fb), rows(
+		"........",
+		".######.",
+		"........",
+	); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+
+	// halfway between two rows it splits each column between them, and the
+	// coverage down every column adds up to the whole color
+	for _, steep := range []bool{false, true} {
+		fb := framebuffer.New(10, 10, framebuffer.RGBA32)
+		x0, y0, x1, y1 := 1.0, 4.5, 8.0, 4.5
+		if steep {
+			x0, y0, x1, y1 = y0, x0, y1, x1
+		}
+		LineAA(fb, x0, y0, x1, y1, white)
+		for k := 2; k < 8; k++ {
+			a, b := fb.Get(k, 4), fb.Get(k, 5)
+			if steep {
+				a, b = fb.Get(4, k), fb.Get(5, k)
+			}
+			if math.Abs(float64(a.A)-float64(b.A)) > 1 || int(a.A)+int(b.A) < 254 || int(a.A)+int(b.A) > 256 {
+				t.Errorf("steep %v, step %d: coverage %d and %d, want half each", steep, k, a.A, b.A)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package sprite
+
+// Animation plays a sheet's frames at a steady rate, advanced by the game's
+// updates
+type Animation struct {
+	Sheet  *Sheet
+	Frames []int   // which of the sheet's frames in order, nil for all of them
+	FPS    float64 // frames a second
+	Loop   bool    // start over after the last frame, or stay on it
+	t      float64 // seconds played
+}
+
+// Update plays dt seconds more
+func (a *Animation) Update(dt float64) {
+	a.t += dt
+}
+
+// Reset goes back to the first frame
+func (a *Animation) Reset() {
+	a.t = 0
+}
+
+func (a *Animation) len() int {
+	if a.Frames != nil {
+		return len(a.Frames)
+	}
+	return len(a.Sheet.Frames)
+}
+
+func (a *Animation) index() int {
+	n := a.len()
+	k := int(a.t * a.FPS)
+	if a.Loop {
+		return k % n
+	}
+	return min(k, n-1)
+}
+
+// Done reports whether an animation that doesn't loop has reached its last
+// frame
+func (a *Animation) Done() bool {
+	return !a.Loop && int(a.t*a.FPS) >= a.len()-1
+}
+
+// Frame is the sprite to draw now
+func (a *Animation) Frame() Sprite {
+	k := a.index()
+	if a.Frames != nil {
+		k = a.Frames[k]
+	}
+	return a.Sheet.Frame(k)
+}
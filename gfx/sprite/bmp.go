@@ -0,0 +1,190 @@
+package sprite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// windows bitmaps are registered with the image package here, there isn't
+// a decoder in the standard library. uncompressed 8 bit paletted, 24 bit and
+// 32 bit images are read, which is what paint programs write
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", decodeBMP, decodeBMPConfig)
+}
+
+// bmpHeader is the file header and the start of the info header, which all
+// versions of it share
+type bmpHeader struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	compression   uint32
+	colors        int      // palette entries, for 8 bit
+	masks         []uint32 // r, g, b, a channel masks for bitfields
+	dataOffset    int
+	read          int // bytes of the file read so far
+}
+
+const (
+	biRGB       = 0
+	biBitfields = 3
+)
+
+func readBMPHeader(r io.Reader) (*bmpHeader, error) {
+	var head [54]byte // 14 byte file header, 40 byte BITMAPINFOHEADER
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	if string(head[:2]) != "BM" {
+		return nil, errors.New("bmp: not a bitmap")
+	}
+	infoSize := int(le.Uint32(head[14:]))
+	if infoSize < 40 {
+		return nil, fmt.Errorf("bmp: unsupported %d byte info header", infoSize)
+	}
+	h := &bmpHeader{
+		width:       int(int32(le.Uint32(head[18:]))),
+		height:      int(int32(le.Uint32(head[22:]))),
+		bpp:         int(le.Uint16(head[28:])),
+		compression: le.Uint32(head[30:]),
+		colors:      int(le.Uint32(head[46:])),
+		dataOffset:  int(le.Uint32(head[10:])),
+		read:        54,
+	}
+	if h.height < 0 {
+		h.height, h.topDown = -h.height, true
+	}
+	if h.width <= 0 || h.height == 0 {
+		return nil, errors.New("bmp: empty image")
+	}
+
+	switch {
+	case h.compression == biRGB && (h.bpp == 8 || h.bpp == 24 || h.bpp == 32):
+	case h.compression == biBitfields && h.bpp == 32:
+		// the masks follow a 40 byte header, or are its next fields in the
+		// v4 and v5 ones, alpha only there
+		n := 3
+		if infoSize >= 56 {
+			n = 4
+		}
+		buf := make([]byte, 4*n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		h.read += len(buf)
+		for k := range n {
+			h.masks = append(h.masks, le.Uint32(buf[4*k:]))
+		}
+		if n == 3 {
+			h.masks = append(h.masks, 0)
+		}
+	default:
+		return nil, fmt.Errorf("bmp: unsupported %d bit image with compression %d", h.bpp, h.compression)
+	}
+	if h.bpp == 8 && (h.colors == 0 || h.colors > 256) {
+		h.colors = 256
+	}
+	// skip the rest of a longer header, up to the palette or the pixels
+	if skip := 14 + infoSize - h.read; skip > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+			return nil, err
+		}
+		h.read += skip
+	}
+	return h, nil
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+func decodeBMP(r io.Reader) (image.Image, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	var palette [][4]byte // b, g, r, unused
+	if h.bpp == 8 {
+		buf := make([]byte, 4*h.colors)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		h.read += len(buf)
+		for k := range h.colors {
+			palette = append(palette, [4]byte(buf[4*k:4*k+4]))
+		}
+	}
+	if skip := h.dataOffset - h.read; skip > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+			return nil, err
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	stride := (h.width*h.bpp/8 + 3) &^ 3 // rows are padded to 4 bytes
+	row := make([]byte, stride)
+	anyAlpha := false
+	for k := range h.height {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		y := h.height - 1 - k // bottom row first unless the height was negative
+		if h.topDown {
+			y = k
+		}
+		out := img.Pix[y*img.Stride:]
+		for x := range h.width {
+			p := out[4*x : 4*x+4]
+			switch h.bpp {
+			case 8:
+				i := int(row[x])
+				if i >= len(palette) {
+					return nil, errors.New("bmp: palette index out of range")
+				}
+				c := palette[i]
+				p[0], p[1], p[2], p[3] = c[2], c[1], c[0], 255
+			case 24:
+				p[0], p[1], p[2], p[3] = row[3*x+2], row[3*x+1], row[3*x], 255
+			case 32:
+				v := binary.LittleEndian.Uint32(row[4*x:])
+				if h.masks == nil {
+					p[0], p[1], p[2], p[3] = byte(v>>16), byte(v>>8), byte(v), byte(v>>24)
+				} else {
+					p[0], p[1], p[2], p[3] = channel(v, h.masks[0]), channel(v, h.masks[1]), channel(v, h.masks[2]), channel(v, h.masks[3])
+				}
+				anyAlpha = anyAlpha || p[3] != 0
+			}
+		}
+	}
+	// plenty of writers leave the fourth byte of 32 bit images zero, it only
+	// means alpha when some pixel uses it
+	if h.bpp == 32 && !anyAlpha {
+		for k := 3; k < len(img.Pix); k += 4 {
+			img.Pix[k] = 255
+		}
+	}
+	return img, nil
+}
+
+// channel pulls the bits mask selects out of v, scaled to 8 bits
+func channel(v, mask uint32) byte {
+	if mask == 0 {
+		return 0
+	}
+	shift := 0
+	for mask&1 == 0 {
+		mask >>= 1
+		shift++
+	}
+	c := (v >> shift) & mask
+	return byte(c * 255 / mask)
+}
@@ -0,0 +1,202 @@
+package sprite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+// bmpFile describes a bitmap to build in memory
+type bmpFile struct {
+	width, height int // height negative for top down rows
+	bpp           int
+	compression   uint32
+	infoSize      int      // 0 for the 40 byte BITMAPINFOHEADER
+	masks         []uint32 // bitfields, after the header or in it
+	colors        int      // what the header says the palette holds
+	palette       [][4]byte
+	rows          [][]byte // in file order, padding added
+}
+
+func (f bmpFile) bytes() []byte {
+	le := binary.LittleEndian
+	infoSize := f.infoSize
+	if infoSize == 0 {
+		infoSize = 40
+	}
+	info := make([]byte, infoSize)
+	le.PutUint32(info[0:], uint32(infoSize))
+	le.PutUint32(info[4:], uint32(int32(f.width)))
+	le.PutUint32(info[8:], uint32(int32(f.height)))
+	le.PutUint16(info[12:], 1)
+	le.PutUint16(info[14:], uint16(f.bpp))
+	le.PutUint32(info[16:], f.compression)
+	le.PutUint32(info[32:], uint32(f.colors))
+	var extra []byte
+	for k, m := range f.masks {
+		if infoSize >= 56 {
+			le.PutUint32(info[40+4*k:], m)
+		} else {
+			extra = le.AppendUint32(extra, m)
+		}
+	}
+	for _, c := range f.palette {
+		extra = append(extra, c[:]...)
+	}
+
+	var pix []byte
+	for _, row := range f.rows {
+		pix = append(pix, row...)
+		for len(row)%4 != 0 {
+			row = append(row, 0)
+			pix = append(pix, 0)
+		}
+	}
+
+	offset := 14 + len(info) + len(extra)
+	head := []byte("BM")
+	head = le.AppendUint32(head, uint32(offset+len(pix)))
+	head = le.AppendUint32(head, 0)
+	head = le.AppendUint32(head, uint32(offset))
+	return bytes.Join([][]byte{head, info, extra, pix}, nil)
+}
+
+var (
+	red   = framebuffer.RGB(255, 0, 0)
+	green = framebuffer.RGB(0, 255, 0)
+	blue  = framebuffer.RGB(0, 0, 255)
+	white = framebuffer.RGB(255, 255, 255)
+)
+
+func TestDecodeBMP(t *testing.T) {
+	tests := []struct {
+		name string
+		file bmpFile
+		want [][]framebuffer.Color // top row first
+	}{
+		{"24 bit", bmpFile{
+			width: 3, height: 2, bpp: 24,
+			// bottom row first, blue green red, padded to 12 bytes
+			rows: [][]byte{
+				{0, 0, 255, 0, 255, 0, 255, 0, 0},
+				{255, 255, 255, 0, 0, 0, 0, 0, 255},
+			},
+		}, [][]framebuffer.Color{
+			{white, framebuffer.RGB(0, 0, 0), red},
+			{red, green, blue},
+		}},
+		{"24 bit top down", bmpFile{
+			width: 1, height: -2, bpp: 24,
+			rows: [][]byte{{0, 0, 255}, {255, 0, 0}},
+		}, [][]framebuffer.Color{{red}, {blue}}},
+		{"8 bit", bmpFile{
+			width: 3, height: 2, bpp: 8, colors: 3,
+			palette: [][4]byte{{0, 0, 255, 0}, {0, 255, 0, 0}, {255, 0, 0, 0}},
+			rows:    [][]byte{{0, 1, 2}, {2, 2, 0}},
+		}, [][]framebuffer.Color{
+			{blue, blue, red},
+			{red, green, blue},
+		}},
+		{"8 bit full palette", bmpFile{
+			// 0 colors in the header means all 256
+			width: 2, height: 1, bpp: 8,
+			palette: func() [][4]byte {
+				p := make([][4]byte, 256)
+				p[255] = [4]byte{255, 255, 255, 0}
+				return p
+			}(),
+			rows: [][]byte{{255, 0}},
+		}, [][]framebuffer.Color{{white, framebuffer.RGB(0, 0, 0)}}},
+		{"32 bit, alpha unused", bmpFile{
+			width: 2, height: 1, bpp: 32,
+			rows: [][]byte{{0, 0, 255, 0, 255, 0, 0, 0}},
+		}, [][]framebuffer.Color{{red, blue}}},
+		{"32 bit with alpha", bmpFile{
+			width: 2, height: 1, bpp: 32,
+			rows: [][]byte{{0, 0, 255, 255, 255, 0, 0, 128}},
+		}, [][]framebuffer.Color{{red, framebuffer.RGBA(0, 0, 255, 128)}}},
+		{"bitfields", bmpFile{
+			// rgba byte order, the masks after a plain header
+			width: 2, height: 1, bpp: 32, compression: biBitfields,
+			masks: []uint32{0x000000ff, 0x0000ff00, 0x00ff0000},
+			rows:  [][]byte{{255, 0, 0, 0, 0, 255, 0, 0}},
+		}, [][]framebuffer.Color{{red, green}}},
+		{"bitfields v4", bmpFile{
+			// a 108 byte header, alpha mask included, and the bytes in
+			// alpha, blue, green, red order
+			width: 2, height: 1, bpp: 32, compression: biBitfields, infoSize: 108,
+			masks: []uint32{0xff000000, 0x00ff0000, 0x0000ff00, 0x000000ff},
+			rows:  [][]byte{{255, 0, 0, 255, 64, 0, 255, 0}},
+		}, [][]framebuffer.Color{{red, framebuffer.RGBA(0, 255, 0, 64)}}},
+		{"5 bit bitfields", bmpFile{
+			// 565 spread over 32 bits, channels scale up to 8 bits
+			width: 1, height: 1, bpp: 32, compression: biBitfields,
+			masks: []uint32{0xf800, 0x07e0, 0x001f},
+			rows:  [][]byte{{0x1f, 0xf8, 0, 0}},
+		}, [][]framebuffer.Color{{framebuffer.RGB(255, 0, 255)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.file.bytes()
+			fb, err := Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fb.Width != len(tt.want[0]) || fb.Height != len(tt.want) {
+				t.Fatalf("decoded %dx%d, want %dx%d", fb.Width, fb.Height, len(tt.want[0]), len(tt.want))
+			}
+			for y, row := range tt.want {
+				for x, c := range row {
+					if got := fb.Get(x, y); got != c {
+						t.Errorf("pixel %d,%d is %v, want %v", x, y, got, c)
+					}
+				}
+			}
+
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil || format != "bmp" || cfg.Width != fb.Width || cfg.Height != fb.Height {
+				t.Errorf("config %+v, format %q, error %v", cfg, format, err)
+			}
+		})
+	}
+}
+
+func TestDecodeBMPErrors(t *testing.T) {
+	good := bmpFile{width: 2, height: 2, bpp: 24, rows: [][]byte{make([]byte, 6), make([]byte, 6)}}
+	header := func(edit func(b []byte)) []byte {
+		b := good.bytes()
+		edit(b)
+		return b
+	}
+	le := binary.LittleEndian
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"short", []byte("BM\x00\x00"), "EOF"},
+		{"not a bitmap", header(func(b []byte) { b[0] = 'X' }), "not a bitmap"},
+		{"old header", header(func(b []byte) { le.PutUint32(b[14:], 12) }), "unsupported 12 byte info header"},
+		{"no width", header(func(b []byte) { le.PutUint32(b[18:], 0) }), "empty image"},
+		{"16 bit", header(func(b []byte) { le.PutUint16(b[28:], 16) }), "unsupported 16 bit image"},
+		{"rle", header(func(b []byte) { le.PutUint16(b[28:], 8); le.PutUint32(b[30:], 1) }), "compression 1"},
+		{"cut off", good.bytes()[:len(good.bytes())-3], "EOF"},
+		{"palette index", bmpFile{
+			width: 1, height: 1, bpp: 8, colors: 2,
+			palette: [][4]byte{{}, {}},
+			rows:    [][]byte{{2}},
+		}.bytes(), "palette index out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeBMP(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
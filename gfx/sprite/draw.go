@@ -0,0 +1,70 @@
+package sprite
+
+import (
+	"image"
+
+	"gfx/framebuffer"
+	"gfx/raster"
+)
+
+// Options says how Draw treats the source pixels
+type Options struct {
+	// with UseKey, pixels of exactly Key's color are skipped, the old way
+	// of cutting sprites out of an opaque background like magenta
+	Key    framebuffer.Color
+	UseKey bool
+	FlipX  bool // mirror left to right
+	FlipY  bool // mirror top to bottom
+}
+
+// ColorKey is Options skipping pixels of color c
+func ColorKey(c framebuffer.Color) Options {
+	return Options{Key: c, UseKey: true}
+}
+
+// Draw copies the rectangle r of img onto dst with its top left corner at
+// x, y. pixels blend over dst by their alpha, and what falls outside either
+// buffer is clipped
+func Draw(dst *framebuffer.Buffer, x, y int, img *framebuffer.Buffer, r image.Rectangle, opts Options) {
+	// clip the source to the image, moving the placement along by what came
+	// off the edge that lands at x, y, the far one when flipped. then clip
+	// the placement to dst and carry what it cut off back to the source
+	in := r.Intersect(image.Rect(0, 0, img.Width, img.Height))
+	if in.Empty() {
+		return
+	}
+	if opts.FlipX {
+		x += r.Max.X - in.Max.X
+	} else {
+		x += in.Min.X - r.Min.X
+	}
+	if opts.FlipY {
+		y += r.Max.Y - in.Max.Y
+	} else {
+		y += in.Min.Y - r.Min.Y
+	}
+	r = in
+	place := image.Rect(x, y, x+r.Dx(), y+r.Dy())
+	clipped := place.Intersect(image.Rect(0, 0, dst.Width, dst.Height))
+	if clipped.Empty() {
+		return
+	}
+
+	for dy := clipped.Min.Y; dy < clipped.Max.Y; dy++ {
+		sy := r.Min.Y + dy - y
+		if opts.FlipY {
+			sy = r.Max.Y - 1 - (dy - y)
+		}
+		for dx := clipped.Min.X; dx < clipped.Max.X; dx++ {
+			sx := r.Min.X + dx - x
+			if opts.FlipX {
+				sx = r.Max.X - 1 - (dx - x)
+			}
+			c := img.Get(sx, sy)
+			if opts.UseKey && c.R == opts.Key.R && c.G == opts.Key.G && c.B == opts.Key.B {
+				continue
+			}
+			raster.Blend(dst, dx, dy, c)
+		}
+	}
+}
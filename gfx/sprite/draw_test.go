@@ -0,0 +1,170 @@
+package sprite
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	"gfx/framebuffer"
+)
+
+// numbered is a w x h image whose pixels all differ, opaque
+func numbered(w, h int) *framebuffer.Buffer {
+	img := framebuffer.New(w, h, framebuffer.RGBA32)
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, framebuffer.RGB(byte(10+x), byte(10+y), 200))
+		}
+	}
+	return img
+}
+
+// drawn is what Draw should leave in a dst of w x h: each pixel of r lands
+// where it would if nothing were clipped, flipped within r, and pixels of r
+// outside img draw nothing
+func drawn(w, h, x, y int, img *framebuffer.Buffer, r image.Rectangle, opts Options) *framebuffer.Buffer {
+	want := framebuffer.New(w, h, framebuffer.RGBA32)
+	for dy := range h {
+		for dx := range w {
+			i, j := dx-x, dy-y
+			if i < 0 || j < 0 || i >= r.Dx() || j >= r.Dy() {
+				continue
+			}
+			sx, sy := r.Min.X+i, r.Min.Y+j
+			if opts.FlipX {
+				sx = r.Max.X - 1 - i
+			}
+			if opts.FlipY {
+				sy = r.Max.Y - 1 - j
+			}
+			if img.In(sx, sy) {
+				want.Set(dx, dy, img.Get(sx, sy))
+			}
+		}
+	}
+	return want
+}
+
+func TestDrawClipped(t *testing.T) {
+	// every placement of every source rect, in or partly out of the image,
+	// flipped either way, against the unclipped picture
+	img := numbered(4, 3)
+	const w, h = 5, 4
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 4, 3),   // the whole image
+		image.Rect(1, 1, 3, 2),   // inside it
+		image.Rect(-2, -1, 3, 2), // hanging off the top left
+		image.Rect(2, 1, 6, 5),   // off the bottom right
+		image.Rect(-1, -1, 5, 4), // off every side
+		image.Rect(5, 0, 7, 2),   // entirely outside
+	}
+	for _, r := range rects {
+		for _, flip := range []Options{{}, {FlipX: true}, {FlipY: true}, {FlipX: true, FlipY: true}} {
+			for y := -4; y <= h; y++ {
+				for x := -5; x <= w; x++ {
+					dst := framebuffer.New(w, h, framebuffer.RGBA32)
+					Draw(dst, x, y, img, r, flip)
+					want := drawn(w, h, x, y, img, r, flip)
+					if string(dst.Pix) != string(want.Pix) {
+						t.Fatalf("%v at %d,%d flipped x %v y %v:\ngot\n%swant\n%s", r, x, y, flip.FlipX, flip.FlipY, dump(dst), dump(want))
+					}
+				}
+			}
+		}
+	}
+}
+
+// dump writes a buffer out as the source coordinates numbered left in it
+func dump(fb *framebuffer.Buffer) string {
+	s := ""
+	for y := range fb.Height {
+		for x := range fb.Width {
+			if c := fb.Get(x, y); c.A == 0 {
+				s += "  .  "
+			} else {
+				s += fmt.Sprintf(" %d,%d ", int(c.R)-10, int(c.G)-10)
+			}
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func TestDrawColorKey(t *testing.T) {
+	magenta := framebuffer.RGB(255, 0, 255)
+	img := numbered(3, 1)
+	img.Set(1, 0, magenta)
+	// alpha doesn't matter to the key, only the color
+	img.Set(2, 0, framebuffer.RGBA(255, 0, 255, 128))
+
+	bg := framebuffer.RGB(1, 2, 3)
+	dst := framebuffer.New(3, 1, framebuffer.RGBA32)
+	dst.Fill(bg)
+	Draw(dst, 0, 0, img, image.Rect(0, 0, 3, 1), ColorKey(magenta))
+	if got := dst.Get(0, 0); got != img.Get(0, 0) {
+		t.Errorf("pixel 0 is %v, want %v", got, img.Get(0, 0))
+	}
+	for x := 1; x < 3; x++ {
+		if got := dst.Get(x, 0); got != bg {
+			t.Errorf("keyed pixel %d drew %v", x, got)
+		}
+	}
+
+	// without the key it's drawn like anything else
+	Draw(dst, 0, 0, img, image.Rect(0, 0, 3, 1), Options{})
+	if got := dst.Get(1, 0); got != magenta {
+		t.Errorf("unkeyed pixel is %v, want %v", got, magenta)
+	}
+}
+
+func TestDrawAlpha(t *testing.T) {
+	img := framebuffer.New(3, 1, framebuffer.RGBA32)
+	img.Set(0, 0, framebuffer.RGBA(255, 0, 0, 255))
+	img.Set(1, 0, framebuffer.RGBA(255, 0, 0, 128))
+	img.Set(2, 0, framebuffer.RGBA(255, 0, 0, 0))
+
+	dst := framebuffer.New(3, 1, framebuffer.RGB24)
+	dst.Fill(framebuffer.RGB(0, 0, 255))
+	Whole(img).Draw(dst, 0, 0, Options{})
+	want := []framebuffer.Color{
+		framebuffer.RGB(255, 0, 0),   // opaque replaces
+		framebuffer.RGB(128, 0, 127), // half blends
+		framebuffer.RGB(0, 0, 255),   // transparent leaves it
+	}
+	for x, c := range want {
+		if got := dst.Get(x, 0); got != c {
+			t.Errorf("pixel %d is %v, want %v", x, got, c)
+		}
+	}
+}
+
+func TestSpriteDrawCentered(t *testing.T) {
+	sheet := Grid(numbered(6, 2), 2, 2)
+	if len(sheet.Frames) != 3 {
+		t.Fatalf("%d frames, want 3", len(sheet.Frames))
+	}
+	dst := framebuffer.New(5, 5, framebuffer.RGBA32)
+	sheet.Frame(1).DrawCentered(dst, 2, 2, Options{})
+	// frame 1 is columns 2 and 3, its top left lands at 1,1
+	want := drawn(5, 5, 1, 1, sheet.Image, image.Rect(2, 0, 4, 2), Options{})
+	if string(dst.Pix) != string(want.Pix) {
+		t.Errorf("got\n%swant\n%s", dump(dst), dump(want))
+	}
+}
+
+func TestGrid(t *testing.T) {
+	// cells that don't fit whole are left out
+	s := Grid(numbered(5, 5), 2, 2)
+	want := []image.Rectangle{image.Rect(0, 0, 2, 2), image.Rect(2, 0, 4, 2), image.Rect(0, 2, 2, 4), image.Rect(2, 2, 4, 4)}
+	if len(s.Frames) != len(want) {
+		t.Fatalf("frames %v, want %v", s.Frames, want)
+	}
+	for k := range want {
+		if s.Frames[k] != want[k] {
+			t.Errorf("frame %d is %v, want %v", k, s.Frames[k], want[k])
+		}
+	}
+	if s := Grid(numbered(5, 5), 0, 2); len(s.Frames) != 0 {
+		t.Errorf("zero width cells gave %d frames", len(s.Frames))
+	}
+}
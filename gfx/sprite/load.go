@@ -0,0 +1,55 @@
+// Package sprite loads png and bmp images into framebuffers and draws them,
+// whole or a frame of a sheet at a time. drawing clips to the destination,
+// skips a color key if asked to, and blends by alpha. an Animation steps
+// through a sheet's frames over time
+package sprite
+
+import (
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+	"os"
+
+	"gfx/framebuffer"
+)
+
+// Decode reads a png or bmp into an RGBA32 buffer
+func Decode(r io.Reader) (*framebuffer.Buffer, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromImage(img), nil
+}
+
+// Load reads a png or bmp file into an RGBA32 buffer
+func Load(path string) (*framebuffer.Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// FromImage copies img into an RGBA32 buffer, alpha not premultiplied as
+// framebuffer colors are
+func FromImage(img image.Image) *framebuffer.Buffer {
+	b := img.Bounds()
+	fb := framebuffer.New(b.Dx(), b.Dy(), framebuffer.RGBA32)
+	if n, ok := img.(*image.NRGBA); ok {
+		for y := range fb.Height {
+			row := n.Pix[n.PixOffset(b.Min.X, b.Min.Y+y):]
+			copy(fb.Pix[y*fb.Pitch():(y+1)*fb.Pitch()], row[:fb.Pitch()])
+		}
+		return fb
+	}
+	for y := range fb.Height {
+		for x := range fb.Width {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			fb.Set(x, y, framebuffer.RGBA(c.R, c.G, c.B, c.A))
+		}
+	}
+	return fb
+}
@@ -0,0 +1,60 @@
+package sprite
+
+import (
+	"image"
+
+	"gfx/framebuffer"
+)
+
+// Sprite is a rectangle of an image, drawn as one picture
+type Sprite struct {
+	Image *framebuffer.Buffer
+	Rect  image.Rectangle
+}
+
+// Whole is all of img as a sprite
+func Whole(img *framebuffer.Buffer) Sprite {
+	return Sprite{img, image.Rect(0, 0, img.Width, img.Height)}
+}
+
+// Size is the sprite's width and height
+func (s Sprite) Size() (int, int) {
+	return s.Rect.Dx(), s.Rect.Dy()
+}
+
+// Draw puts the sprite's top left corner at x, y
+func (s Sprite) Draw(dst *framebuffer.Buffer, x, y int, opts Options) {
+	Draw(dst, x, y, s.Image, s.Rect, opts)
+}
+
+// DrawCentered puts the sprite's middle at x, y
+func (s Sprite) DrawCentered(dst *framebuffer.Buffer, x, y int, opts Options) {
+	w, h := s.Size()
+	Draw(dst, x-w/2, y-h/2, s.Image, s.Rect, opts)
+}
+
+// Sheet is an image holding several frames
+type Sheet struct {
+	Image  *framebuffer.Buffer
+	Frames []image.Rectangle
+}
+
+// Grid cuts img into w x h frames, left to right and then top to bottom.
+// cells that don't fit whole at the right and bottom edges are left out
+func Grid(img *framebuffer.Buffer, w, h int) *Sheet {
+	s := &Sheet{Image: img}
+	if w <= 0 || h <= 0 {
+		return s
+	}
+	for y := 0; y+h <= img.Height; y += h {
+		for x := 0; x+w <= img.Width; x += w {
+			s.Frames = append(s.Frames, image.Rect(x, y, x+w, y+h))
+		}
+	}
+	return s
+}
+
+// Frame is frame i as a sprite
+func (s *Sheet) Frame(i int) Sprite {
+	return Sprite{s.Image, s.Frames[i]}
+}
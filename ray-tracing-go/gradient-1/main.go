@@ -2,11 +2,12 @@
 package main
 
 import (
-	"fmt"
+	"flag"
 	"log"
-	"unsafe"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"gfx/capture"
+	"gfx/framebuffer"
+	"gfx/platform"
 )
 
 const (
@@ -14,88 +15,38 @@ const (
 	winHeight = 800
 )
 
-type color struct {
-	r, g, b, a byte
-}
-
-func setPixel(x, y int, c color, pixels []byte) {
-	if x < 0 || x >= winWidth || y < 0 || y >= winHeight {
-		return
-	}
-
-	index := (y*winWidth + x) * 4
-
-	if (index + 3) > len(pixels) {
-		return
-	}
-
-	pixels[index] = c.r
-	pixels[index+1] = c.g
-	pixels[index+2] = c.b
-	pixels[index+3] = c.a
-}
-
 func main() {
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		log.Fatalf("could not initialize sdl: %v", err)
-	}
-	defer sdl.Quit()
+	var opts platform.Options
+	opts.Register(flag.CommandLine)
+	var shots capture.Options
+	shots.Register(flag.CommandLine)
+	flag.Parse()
 
-	win, err := sdl.CreateWindow(
-		"Gradient",
-		sdl.WINDOWPOS_UNDEFINED,
-		sdl.WINDOWPOS_UNDEFINED,
-		winWidth,
-		winHeight,
-		sdl.WINDOW_SHOWN,
-	)
+	win, err := opts.Open("Gradient", winWidth, winHeight)
 	if err != nil {
-		log.Fatalf("could not create window: %v", err)
+		log.Fatalf("could not open window: %v", err)
 	}
-	defer win.Destroy()
-
-	renderer, err := sdl.CreateRenderer(win, -1, sdl.RENDERER_ACCELERATED)
-	if err != nil {
-		log.Fatalf("could not create renderer: %v", err)
-	}
-	defer renderer.Destroy()
-
-	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_ABGR8888, sdl.TEXTUREACCESS_STREAMING, int32(winWidth), int32(winHeight))
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer tex.Destroy()
-
-	pixels := make([]byte, winWidth*winHeight*4)
-
-	for y := range winHeight {
-		for x := range winWidth {
-			r := uint8(float64(y) / float64(winWidth) * 256)
-			g := uint8(float64(x) / float64(winHeight) * 256)
-			b := uint8(0)
-
-			setPixel(x, y, color{r, g, b, 255}, pixels)
+	p := capture.Wrap(win, shots, "gradient-1")
+	defer func() {
+		if err := p.Close(); err != nil {
+			log.Printf("could not close window: %v", err)
 		}
-	}
-
-	tex.Update(nil, unsafe.Pointer(&pixels[0]), winWidth*4)
+	}()
 
-	renderer.Copy(tex, nil, nil)
-	renderer.Present()
+	// the gradient is redrawn at whatever size the window is
+	gradient := func(fb *framebuffer.Buffer) {
+		for y := range fb.Height {
+			for x := range fb.Width {
+				r := uint8(float64(y) / float64(fb.Height) * 256)
+				g := uint8(float64(x) / float64(fb.Width) * 256)
+				b := uint8(0)
 
-	running := true
-	for running {
-		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
-			switch e := ev.(type) {
-			case *sdl.QuitEvent:
-				running = false
-			case *sdl.KeyboardEvent:
-				if e.Type == sdl.KEYDOWN && e.Keysym.Sym == sdl.K_ESCAPE {
-					running = false
-				}
+				fb.Set(x, y, framebuffer.RGB(r, g, b))
 			}
 		}
-		sdl.Delay(16)
+	}
+
+	if err := platform.ShowResizable(p, framebuffer.ABGR8888, gradient); err != nil {
+		log.Fatalf("could not show frame: %v", err)
 	}
 }
@@ -0,0 +1,76 @@
+package main
+
+import "math"
+
+// axis aligned bounding box
+type aabb struct {
+	min, max vec3
+}
+
+func emptyBox() aabb {
+	inf := math.Inf(1)
+	return aabb{vec3{inf, inf, inf}, vec3{-inf, -inf, -inf}}
+}
+
+func (b aabb) union(o aabb) aabb {
+	return aabb{
+		vec3{math.Min(b.min.x, o.min.x), math.Min(b.min.y, o.min.y), math.Min(b.min.z, o.min.z)},
+		vec3{math.Max(b.max.x, o.max.x), math.Max(b.max.y, o.max.y), math.Max(b.max.z, o.max.z)},
+	}
+}
+
+func (b aabb) center() vec3 {
+	return b.min.add(b.max).mul(0.5)
+}
+
+func (b aabb) axis(n int) (lo, hi float64) {
+	switch n {
+	case 0:
+		return b.min.x, b.max.x
+	case 1:
+		return b.min.y, b.max.y
+	}
+	return b.min.z, b.max.z
+}
+
+func component(v vec3, n int) float64 {
+	switch n {
+	case 0:
+		return v.x
+	case 1:
+		return v.y
+	}
+	return v.z
+}
+
+// hit is the slab test: the ray is inside the box where it's inside all three
+// pairs of planes at once
+func (b aabb) hit(r ray, tMin, tMax float64) bool {
+	for n := range 3 {
+		lo, hi := b.axis(n)
+		inv := 1 / component(r.dir, n)
+		o := component(r.orig, n)
+
+		t0 := (lo - o) * inv
+		t1 := (hi - o) * inv
+		if inv < 0 {
+			t0, t1 = t1, t0
+		}
+		tMin = math.Max(t0, tMin)
+		tMax = math.Min(t1, tMax)
+		if tMax <= tMin {
+			return false
+		}
+	}
+	return true
+}
+
+// diskExtent is how far a disk of radius r with unit normal n reaches along
+// each axis from its center
+func diskExtent(n vec3, r float64) vec3 {
+	return vec3{
+		r * math.Sqrt(math.Max(0, 1-n.x*n.x)),
+		r * math.Sqrt(math.Max(0, 1-n.y*n.y)),
+		r * math.Sqrt(math.Max(0, 1-n.z*n.z)),
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type interpolation int
+
+const (
+	linear interpolation = iota
+	catmullRom
+)
+
+func parseInterpolation(s string) (interpolation, error) {
+	switch s {
+	case "linear":
+		return linear, nil
+	case "catmull-rom", "catmullrom":
+		return catmullRom, nil
+	}
+	return linear, fmt.Errorf("unknown interpolation %q (want linear or catmull-rom)", s)
+}
+
+// anything that can be blended as a weighted sum can be keyframed
+type lerpable[T any] interface {
+	add(T) T
+	mul(float64) T
+}
+
+// scalar lets plain numbers (like a scale factor) go on a track
+type scalar float64
+
+func (a scalar) add(b scalar) scalar  { return a + b }
+func (a scalar) mul(s float64) scalar { return a * scalar(s) }
+
+type keyframe[T lerpable[T]] struct {
+	frame float64
+	value T
+}
+
+type track[T lerpable[T]] struct {
+	keys []keyframe[T] // sorted by frame
+}
+
+func newTrack[T lerpable[T]](keys ...keyframe[T]) track[T] {
+	sort.Slice(keys, func(a, b int) bool { return keys[a].frame < keys[b].frame })
+	return track[T]{keys}
+}
+
+// at evaluates the track, holding the first/last value outside the keyed range
+func (tr track[T]) at(frame float64, interp interpolation) T {
+	n := len(tr.keys)
+	if frame <= tr.keys[0].frame {
+		return tr.keys[0].value
+	}
+	if frame >= tr.keys[n-1].frame {
+		return tr.keys[n-1].value
+	}
+
+	// find segment k..k+1 containing frame
+	k := sort.Search(n, func(i int) bool { return tr.keys[i].frame > frame }) - 1
+	k0, k1 := tr.keys[k], tr.keys[k+1]
+	t := (frame - k0.frame) / (k1.frame - k0.frame)
+
+	if interp == linear {
+		return k0.value.mul(1 - t).add(k1.value.mul(t))
+	}
+
+	// catmull-rom needs a neighbour on each side, duplicate the ends
+	p0 := tr.keys[max(k-1, 0)].value
+	p1 := k0.value
+	p2 := k1.value
+	p3 := tr.keys[min(k+2, n-1)].value
+
+	// 0.5 * (2p1 + (-p0 + p2)t + (2p0 - 5p1 + 4p2 - p3)t^2 + (-p0 + 3p1 - 3p2 + p3)t^3)
+	t2 := t * t
+	t3 := t2 * t
+	return p0.mul(-t + 2*t2 - t3).
+		add(p1.mul(2 - 5*t2 + 3*t3)).
+		add(p2.mul(t + 4*t2 - 3*t3)).
+		add(p3.mul(-t2 + t3)).
+		mul(0.5)
+}
+
+// objectTrack animates one scene object relative to its rest pose: an offset
+// added to its position and a scale about the center of its bounding box
+type objectTrack struct {
+	index     int
+	translate track[vec3]
+	scale     track[scalar]
+}
+
+type animation struct {
+	length int // frames in one full clip
+	interp interpolation
+
+	camFrom track[vec3]
+	camAt   track[vec3]
+	objects []objectTrack
+}
+
+// apply poses the camera and scene for a frame. rest is the unanimated scene,
+// s receives the posed copy so frames don't accumulate transforms
+func (a *animation) apply(frame float64, cam *camera, rest, s *scene) {
+	if len(a.camFrom.keys) > 0 {
+		cam.lookFrom = a.camFrom.at(frame, a.interp)
+	}
+	if len(a.camAt.keys) > 0 {
+		cam.lookAt = a.camAt.at(frame, a.interp)
+	}
+
+	s.background, s.sun = rest.background, rest.sun
+	s.objects = append(s.objects[:0], rest.objects...)
+	for _, ot := range a.objects {
+		obj := rest.objects[ot.index]
+		pivot := obj.boundingBox().center()
+		if math.IsNaN(pivot.x + pivot.y + pivot.z) {
+			pivot = vec3{} // unbounded, like a plane
+		}
+		in := &instance{obj: obj, pivot: pivot, scale: 1}
+		if len(ot.translate.keys) > 0 {
+			in.offset = ot.translate.at(frame, a.interp)
+		}
+		if len(ot.scale.keys) > 0 {
+			in.scale = float64(ot.scale.at(frame, a.interp))
+		}
+		s.objects[ot.index] = in
+	}
+}
+
+// turntable orbits the camera once around (0, 0, -1) while the first object,
+// the sphere there in most scenes, bobs up and down. with catmull-rom the four
+// quarter keys give a near-circular orbit, with linear interpolation it's a
+// diamond
+func turntable(length int, interp interpolation) *animation {
+	center := vec3{0, 0, -1}
+	dist := 1.0
+	n := float64(length)
+
+	var orbit []keyframe[vec3]
+	for q := 0; q <= 4; q++ {
+		angle := float64(q) * math.Pi / 2
+		orbit = append(orbit, keyframe[vec3]{
+			frame: n * float64(q) / 4,
+			value: center.add(vec3{dist * math.Sin(angle), 0.2, dist * math.Cos(angle)}),
+		})
+	}
+
+	return &animation{
+		length:  length,
+		interp:  interp,
+		camFrom: newTrack(orbit...),
+		camAt:   newTrack(keyframe[vec3]{0, center}),
+		objects: []objectTrack{
+			{
+				index: 0,
+				translate: newTrack(
+					keyframe[vec3]{0, vec3{0, 0, 0}},
+					keyframe[vec3]{n / 2, vec3{0, 0.15, 0}},
+					keyframe[vec3]{n, vec3{0, 0, 0}},
+				),
+				scale: newTrack(
+					keyframe[scalar]{0, 1},
+					keyframe[scalar]{n / 2, 0.8},
+					keyframe[scalar]{n, 1},
+				),
+			},
+		},
+	}
+}
+
+// readAnimation reads keyframes, one a line: the frame, the track and the
+// value there.
+//
+//	# frame  track    value
+//	0        camera   0 0.2 0
+//	24       camera   1 0.2 -1
+//	0        look     0 0 -1
+//	12       move 2   0 0.15 0
+//	12       scale 2  0.8
+//
+// camera and look key where the camera stands and what it looks at, move and
+// scale key an offset and a scale for the scene object with that index. a
+// track with no keys leaves what it would animate alone
+func readAnimation(r io.Reader, interp interpolation) (*animation, error) {
+	keys := &keyframes{moves: map[int][]keyframe[vec3]{}, scales: map[int][]keyframe[scalar]{}}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := keys.add(fields); err != nil {
+			return nil, fmt.Errorf("keyframes line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return keys.animation(interp), nil
+}
+
+// keyframes collects what readAnimation has read so far, track by track
+type keyframes struct {
+	camFrom, camAt []keyframe[vec3]
+	moves          map[int][]keyframe[vec3]
+	scales         map[int][]keyframe[scalar]
+	last           float64
+}
+
+func (kf *keyframes) add(fields []string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("want a frame, a track and a value")
+	}
+	frame, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || frame < 0 || math.IsInf(frame, 0) {
+		return fmt.Errorf("bad frame %q", fields[0])
+	}
+	name, args := fields[1], fields[2:]
+	index := -1
+	if name == "move" || name == "scale" {
+		if index, err = strconv.Atoi(args[0]); err != nil || index < 0 {
+			return fmt.Errorf("%s: bad object %q", name, args[0])
+		}
+		args = args[1:]
+	}
+	nums := make([]float64, len(args))
+	for k, a := range args {
+		if nums[k], err = strconv.ParseFloat(a, 64); err != nil {
+			return fmt.Errorf("%s: bad number %q", name, a)
+		}
+	}
+	vec := func() (vec3, error) {
+		if len(nums) != 3 {
+			return vec3{}, fmt.Errorf("%s wants 3 numbers", name)
+		}
+		return vec3{nums[0], nums[1], nums[2]}, nil
+	}
+
+	switch name {
+	case "camera", "look", "move":
+		v, err := vec()
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "camera":
+			kf.camFrom, err = addKey(kf.camFrom, frame, v)
+		case "look":
+			kf.camAt, err = addKey(kf.camAt, frame, v)
+		default:
+			kf.moves[index], err = addKey(kf.moves[index], frame, v)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	case "scale":
+		if len(nums) != 1 || nums[0] <= 0 {
+			return fmt.Errorf("scale wants one number above 0")
+		}
+		if kf.scales[index], err = addKey(kf.scales[index], frame, scalar(nums[0])); err != nil {
+			return fmt.Errorf("scale: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown track %q (want camera, look, move or scale)", name)
+	}
+	kf.last = max(kf.last, frame)
+	return nil
+}
+
+// addKey appends a key, refusing a second one at the same frame
+func addKey[T lerpable[T]](keys []keyframe[T], frame float64, value T) ([]keyframe[T], error) {
+	for _, k := range keys {
+		if k.frame == frame {
+			return keys, fmt.Errorf("frame %v is keyed twice", frame)
+		}
+	}
+	return append(keys, keyframe[T]{frame, value}), nil
+}
+
+func (kf *keyframes) animation(interp interpolation) *animation {
+	a := &animation{
+		length:  int(math.Ceil(kf.last)) + 1,
+		interp:  interp,
+		camFrom: newTrack(kf.camFrom...),
+		camAt:   newTrack(kf.camAt...),
+	}
+	var indices []int
+	for k := range kf.moves {
+		indices = append(indices, k)
+	}
+	for k := range kf.scales {
+		if _, ok := kf.moves[k]; !ok {
+			indices = append(indices, k)
+		}
+	}
+	sort.Ints(indices)
+	for _, k := range indices {
+		a.objects = append(a.objects, objectTrack{
+			index:     k,
+			translate: newTrack(kf.moves[k]...),
+			scale:     newTrack(kf.scales[k]...),
+		})
+	}
+	return a
+}
+
+// loadAnimation reads a keyframes file
+func loadAnimation(path string, interp interpolation) (*animation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readAnimation(f, interp)
+}
+
+// validate checks that every animated object is in a scene with n objects
+func (a *animation) validate(n int) error {
+	for _, ot := range a.objects {
+		if ot.index >= n {
+			return fmt.Errorf("keyframes animate object %d, the scene has %d", ot.index, n)
+		}
+	}
+	return nil
+}
+
+// parseFrameRange parses "start:end" (inclusive) or a single frame number
+func parseFrameRange(s string) (int, int, error) {
+	first, last, isRange := strings.Cut(s, ":")
+	if !isRange {
+		last = first
+	}
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad frame range %q (want start:end)", s)
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad frame range %q (want start:end)", s)
+	}
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("bad frame range %q", s)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTrackAt(t *testing.T) {
+	// keys on x² at uniform spacing, which catmull-rom follows exactly away
+	// from the ends
+	square := newTrack(
+		keyframe[scalar]{3, 9},
+		keyframe[scalar]{0, 0},
+		keyframe[scalar]{2, 4},
+		keyframe[scalar]{1, 1},
+	)
+	tests := []struct {
+		name   string
+		tr     track[scalar]
+		interp interpolation
+		frame  float64
+		want   scalar
+	}{
+		{"linear at a key", square, linear, 2, 4},
+		{"linear between", square, linear, 1.5, 2.5},
+		{"linear first segment", square, linear, 0.25, 0.25},
+		{"before the first key", square, linear, -5, 0},
+		{"after the last key", square, linear, 7, 9},
+		{"catmull-rom at a key", square, catmullRom, 1, 1},
+		{"catmull-rom inside", square, catmullRom, 1.5, 2.25},
+		// at the ends the missing neighbour is the end key again
+		{"catmull-rom first segment", square, catmullRom, 0.5, 0.3125},
+		{"catmull-rom last segment", square, catmullRom, 2.5, 6.6875},
+		{"catmull-rom before", square, catmullRom, -1, 0},
+		{"catmull-rom after", square, catmullRom, 3.5, 9},
+		{"one key", newTrack(keyframe[scalar]{4, 2}), catmullRom, 0, 2},
+		{"uneven keys", newTrack(keyframe[scalar]{0, 0}, keyframe[scalar]{10, 1}), linear, 2.5, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tr.at(tt.frame, tt.interp); math.Abs(float64(got-tt.want)) > 1e-12 {
+				t.Errorf("at %v: %v, want %v", tt.frame, got, tt.want)
+			}
+		})
+	}
+
+	// vectors blend per component
+	tr := newTrack(keyframe[vec3]{0, vec3{0, 2, 4}}, keyframe[vec3]{4, vec3{4, 2, 0}})
+	if got := tr.at(1, linear); got != (vec3{1, 2, 3}) {
+		t.Errorf("vector track at 1: %v", got)
+	}
+}
+
+func TestParseFrameRange(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end int
+		ok         bool
+	}{
+		{"0:47", 0, 47, true},
+		{"5", 5, 5, true},
+		{"3:3", 3, 3, true},
+		{"4:2", 0, 0, false},
+		{"-1:4", 0, 0, false},
+		{"1:", 0, 0, false},
+		{":5", 0, 0, false},
+		{"a:b", 0, 0, false},
+		{"1:2:3", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, err := parseFrameRange(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: error %v", tt.in, err)
+			continue
+		}
+		if tt.ok && (start != tt.start || end != tt.end) {
+			t.Errorf("%q: %d to %d, want %d to %d", tt.in, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestAnimationApply(t *testing.T) {
+	ball := &sphere{center: vec3{0, 0, -1}, radius: 0.5}
+	floor := &plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}}
+	rest := &scene{objects: hittableList{ball, floor}, sun: &sunLight{}}
+	anim, err := readAnimation(strings.NewReader(`
+0   camera   0 0 2
+10  camera   0 0 4
+0   move 0   0 0 0
+10  move 0   0 1 0
+10  scale 0  2
+`), linear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cam := newCamera(16, 9)
+	cam.lookAt = vec3{0, 0, -3}
+	posed := &scene{}
+	anim.apply(10, cam, rest, posed)
+
+	if cam.lookFrom != (vec3{0, 0, 4}) || cam.lookAt != (vec3{0, 0, -3}) {
+		t.Errorf("camera from %v at %v, want moved to 0,0,4 and still looking at 0,0,-3", cam.lookFrom, cam.lookAt)
+	}
+	if posed.sun != rest.sun || len(posed.objects) != 2 || posed.objects[1] != floor {
+		t.Fatalf("posed scene %+v, want the rest scene with object 0 placed", posed)
+	}
+	if rest.objects[0] != ball {
+		t.Error("apply changed the rest scene")
+	}
+	// the ball is up 1 and twice the size about its own center, so a ray
+	// down the middle meets it at z = 0
+	var rec hitRecord
+	if !posed.hit(ray{vec3{0, 1, 5}, vec3{0, 0, -1}}, &rec) || math.Abs(rec.t-5) > 1e-9 {
+		t.Errorf("hit %v at t %v, want the moved ball at 5", rec.p, rec.t)
+	}
+
+	// halfway, and again from the rest scene rather than the last pose
+	anim.apply(5, cam, rest, posed)
+	in, ok := posed.objects[0].(*instance)
+	if !ok || in.obj != ball {
+		t.Fatalf("object 0 is %T, want an instance of the ball", posed.objects[0])
+	}
+	if in.pivot != ball.center || in.offset != (vec3{0, 0.5, 0}) || in.scale != 2 {
+		t.Errorf("frame 5: pivot %v, offset %v, scale %v", in.pivot, in.offset, in.scale)
+	}
+	if cam.lookFrom != (vec3{0, 0, 3}) {
+		t.Errorf("frame 5: camera at %v", cam.lookFrom)
+	}
+}
+
+func TestAnimationApplyUnbounded(t *testing.T) {
+	// a plane has no center, it scales about the origin
+	floor := &plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}}
+	anim := &animation{objects: []objectTrack{{index: 0, scale: newTrack(keyframe[scalar]{0, 2})}}}
+	posed := &scene{}
+	anim.apply(0, newCamera(16, 9), &scene{objects: hittableList{floor}}, posed)
+	in := posed.objects[0].(*instance)
+	if in.pivot != (vec3{}) || in.offset != (vec3{}) || in.scale != 2 {
+		t.Errorf("pivot %v, offset %v, scale %v", in.pivot, in.offset, in.scale)
+	}
+}
+
+func TestReadAnimation(t *testing.T) {
+	anim, err := loadAnimation("testdata/bob.keys", catmullRom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.interp != catmullRom || anim.length != 49 {
+		t.Errorf("interpolation %v, length %d", anim.interp, anim.length)
+	}
+	if len(anim.camFrom.keys) != 5 || len(anim.camAt.keys) != 1 {
+		t.Errorf("%d camera keys and %d look keys", len(anim.camFrom.keys), len(anim.camAt.keys))
+	}
+	// keys are sorted whatever order the file has them in
+	for k := 1; k < len(anim.camFrom.keys); k++ {
+		if anim.camFrom.keys[k].frame <= anim.camFrom.keys[k-1].frame {
+			t.Errorf("camera keys out of order: %v", anim.camFrom.keys)
+		}
+	}
+	if len(anim.objects) != 2 || anim.objects[0].index != 0 || anim.objects[1].index != 2 {
+		t.Fatalf("object tracks %+v, want objects 0 and 2", anim.objects)
+	}
+	if len(anim.objects[0].translate.keys) != 3 || len(anim.objects[0].scale.keys) != 0 {
+		t.Errorf("object 0 has %d moves and %d scales", len(anim.objects[0].translate.keys), len(anim.objects[0].scale.keys))
+	}
+	if len(anim.objects[1].translate.keys) != 0 || len(anim.objects[1].scale.keys) != 2 {
+		t.Errorf("object 2 has %d moves and %d scales", len(anim.objects[1].translate.keys), len(anim.objects[1].scale.keys))
+	}
+
+	if err := anim.validate(3); err != nil {
+		t.Error(err)
+	}
+	if err := anim.validate(2); err == nil {
+		t.Error("no error animating object 2 of 2")
+	}
+}
+
+func TestReadAnimationErrors(t *testing.T) {
+	tests := []struct {
+		name, keys, err string
+	}{
+		{"no value", "0 camera\n", "line 1: want a frame, a track and a value"},
+		{"bad frame", "x camera 0 0 0\n", `line 1: bad frame "x"`},
+		{"negative frame", "-1 camera 0 0 0\n", `line 1: bad frame "-1"`},
+		{"unknown track", "# comment\n\n0 spin 1\n", `line 3: unknown track "spin"`},
+		{"short vector", "0 look 0 0\n", "line 1: look wants 3 numbers"},
+		{"bad number", "0 camera 0 y 0\n", `line 1: camera: bad number "y"`},
+		{"no object", "0 move 0 1 0\n", "line 1: move wants 3 numbers"},
+		{"bad object", "0 move a 0 1 0\n", `line 1: move: bad object "a"`},
+		{"negative object", "0 scale -1 2\n", `line 1: scale: bad object "-1"`},
+		{"zero scale", "0 scale 1 0\n", "line 1: scale wants one number above 0"},
+		{"two scales", "0 scale 1 2 3\n", "line 1: scale wants one number above 0"},
+		{"keyed twice", "0 camera 0 0 0\n5 camera 1 0 0\n0 camera 2 0 0\n", "line 3: camera: frame 0 is keyed twice"},
+		{"object keyed twice", "0 move 1 0 0 0\n0 move 2 0 0 0\n0 move 1 1 0 0\n", "line 3: move: frame 0 is keyed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anim, err := readAnimation(strings.NewReader(tt.keys), linear)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+			if anim != nil {
+				t.Error("returned an animation along with the error")
+			}
+		})
+	}
+	if _, err := loadAnimation("testdata/missing.keys", linear); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type projection int
+
+const (
+	perspective projection = iota
+	orthographic
+	fisheye         // equidistant: angle from the view axis grows linearly with image radius
+	equirectangular // full 360x180 panorama, longitude across and latitude down
+)
+
+func parseProjection(s string) (projection, error) {
+	switch s {
+	case "perspective":
+		return perspective, nil
+	case "orthographic", "ortho":
+		return orthographic, nil
+	case "fisheye":
+		return fisheye, nil
+	case "equirectangular", "360":
+		return equirectangular, nil
+	}
+	return perspective, fmt.Errorf("unknown projection %q (want perspective, orthographic, fisheye or equirectangular)", s)
+}
+
+type camera struct {
+	lookFrom vec3
+	lookAt   vec3
+	vup      vec3
+	vfov     float64 // vertical field of view in degrees
+
+	projection projection
+	fisheyeFOV float64 // full field of view across the fisheye circle, in degrees
+
+	width, height int
+
+	// derived in init
+	center  vec3
+	u, v, w vec3
+	pixel00 vec3
+	deltaU  vec3
+	deltaV  vec3
+}
+
+// newCamera matches the original hardcoded setup: camera at origin looking
+// down -z, focal length 1 and a viewport 2 units high (vfov = 90)
+func newCamera(width, height int) *camera {
+	return &camera{
+		lookFrom: vec3{0, 0, 0},
+		lookAt:   vec3{0, 0, -1},
+		vup:      vec3{0, 1, 0},
+		vfov:     90,
+
+		projection: perspective,
+		fisheyeFOV: 180,
+
+		width:  width,
+		height: height,
+	}
+}
+
+func (c *camera) init() {
+	c.center = c.lookFrom
+
+	focalLength := c.lookFrom.sub(c.lookAt).length()
+	theta := c.vfov * math.Pi / 180
+	viewportHeight := 2 * math.Tan(theta/2) * focalLength
+	viewportWidth := viewportHeight * (float64(c.width) / float64(c.height))
+
+	// orthonormal basis, w points backwards (camera looks down -w)
+	c.w = c.lookFrom.sub(c.lookAt).unit()
+	c.u = c.vup.cross(c.w).unit()
+	c.v = c.w.cross(c.u)
+
+	viewportU := c.u.mul(viewportWidth)
+	viewportV := c.v.mul(-viewportHeight) // image rows go down
+
+	c.deltaU = viewportU.div(float64(c.width))
+	c.deltaV = viewportV.div(float64(c.height))
+
+	viewportUpperLeft := c.center.sub(c.w.mul(focalLength)).sub(viewportU.div(2)).sub(viewportV.div(2))
+	c.pixel00 = viewportUpperLeft.add(c.deltaU.add(c.deltaV).mul(0.5))
+}
+
+// getRay returns the unit ray through point (sx, sy) of pixel (i, j), where
+// sx and sy are in [0, 1) and 0.5, 0.5 is the pixel center. ok is false for
+// pixels the projection doesn't cover, i.e. outside the fisheye circle
+func (c *camera) getRay(i, j int, sx, sy float64) (r ray, ok bool) {
+	// pixel00 and the deltas are at pixel centers, shift to the sample point
+	fi := float64(i) + sx - 0.5
+	fj := float64(j) + sy - 0.5
+
+	switch c.projection {
+	case orthographic:
+		// same viewport as perspective, but every ray is parallel to the view
+		// axis and starts on the camera plane instead of the camera center
+		p := c.pixel00.add(c.deltaU.mul(fi)).add(c.deltaV.mul(fj))
+		focalLength := c.lookFrom.sub(c.lookAt).length()
+		return ray{p.add(c.w.mul(focalLength)), c.w.neg()}, true
+
+	case fisheye:
+		// pixel offset from image center, normalized so the circle touches the
+		// shorter image side
+		half := float64(min(c.width, c.height)) / 2
+		x := (fi + 0.5 - float64(c.width)/2) / half
+		y := (float64(c.height)/2 - fj - 0.5) / half
+
+		rad := math.Sqrt(x*x + y*y)
+		if rad > 1 {
+			return ray{}, false
+		}
+		theta := rad * (c.fisheyeFOV / 2) * math.Pi / 180 // angle from view axis
+		phi := math.Atan2(y, x)                           // angle around it
+
+		side := c.u.mul(math.Cos(phi)).add(c.v.mul(math.Sin(phi)))
+		dir := side.mul(math.Sin(theta)).sub(c.w.mul(math.Cos(theta)))
+		return ray{c.center, dir}, true
+
+	case equirectangular:
+		// longitude -pi..pi left to right, latitude pi/2..-pi/2 top to bottom,
+		// image center looks straight ahead
+		lon := (fi+0.5)/float64(c.width)*2*math.Pi - math.Pi
+		lat := math.Pi/2 - (fj+0.5)/float64(c.height)*math.Pi
+
+		dir := c.u.mul(math.Cos(lat) * math.Sin(lon)).
+			add(c.v.mul(math.Sin(lat))).
+			sub(c.w.mul(math.Cos(lat) * math.Cos(lon)))
+		return ray{c.center, dir}, true
+	}
+
+	// incremental pixel position
+	p := c.pixel00.add(c.deltaU.mul(fi)).add(c.deltaV.mul(fj))
+
+	// ray direction = pixel_center - camera_center
+	return ray{c.center, p.sub(c.center).unit()}, true
+}
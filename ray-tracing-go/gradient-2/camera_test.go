@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+const eps = 1e-9
+
+func near(a, b vec3, tol float64) bool {
+	return math.Abs(a.x-b.x) <= tol && math.Abs(a.y-b.y) <= tol && math.Abs(a.z-b.z) <= tol
+}
+
+func TestGetRay(t *testing.T) {
+	// 200x100 so the image center falls between pixels 99 and 100 and the
+	// viewport is 4x2 at focal length 1
+	const w, h = 200, 100
+	tests := []struct {
+		name   string
+		proj   projection
+		i, j   int
+		sx, sy float64
+		orig   vec3
+		dir    vec3
+	}{
+		{"perspective center", perspective, w / 2, h / 2, 0, 0, vec3{}, vec3{0, 0, -1}},
+		{"perspective top left", perspective, 0, 0, 0, 0, vec3{}, vec3{-2, 1, -1}.unit()},
+		{"perspective bottom right", perspective, w - 1, h - 1, 1, 1, vec3{}, vec3{2, -1, -1}.unit()},
+		{"perspective pixel center", perspective, 0, 0, 0.5, 0.5, vec3{}, vec3{-2 + 0.01, 1 - 0.01, -1}.unit()},
+
+		{"orthographic center", orthographic, w / 2, h / 2, 0, 0, vec3{}, vec3{0, 0, -1}},
+		{"orthographic top left", orthographic, 0, 0, 0, 0, vec3{-2, 1, 0}, vec3{0, 0, -1}},
+		{"orthographic bottom right", orthographic, w - 1, h - 1, 1, 1, vec3{2, -1, 0}, vec3{0, 0, -1}},
+
+		// 180 degrees across the circle, which is h pixels wide: its edges
+		// look straight sideways
+		{"fisheye center", fisheye, w / 2, h / 2, 0, 0, vec3{}, vec3{0, 0, -1}},
+		{"fisheye right edge", fisheye, w/2 + h/2 - 1, h / 2, 1, 0, vec3{}, vec3{1, 0, 0}},
+		{"fisheye top edge", fisheye, w / 2, 0, 0, 0, vec3{}, vec3{0, 1, 0}},
+		{"fisheye halfway", fisheye, w/2 + h/4, h / 2, 0, 0, vec3{}, vec3{1, 0, -1}.unit()},
+
+		{"equirectangular center", equirectangular, w / 2, h / 2, 0, 0, vec3{}, vec3{0, 0, -1}},
+		{"equirectangular left edge", equirectangular, 0, h / 2, 0, 0, vec3{}, vec3{0, 0, 1}},
+		{"equirectangular quarter", equirectangular, w / 4, h / 2, 0, 0, vec3{}, vec3{-1, 0, 0}},
+		{"equirectangular three quarters", equirectangular, 3 * w / 4, h / 2, 0, 0, vec3{}, vec3{1, 0, 0}},
+		{"equirectangular top", equirectangular, w / 2, 0, 0, 0, vec3{}, vec3{0, 1, 0}},
+		{"equirectangular bottom", equirectangular, w / 2, h - 1, 0, 1, vec3{}, vec3{0, -1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCamera(w, h)
+			c.projection = tt.proj
+			c.init()
+			r, ok := c.getRay(tt.i, tt.j, tt.sx, tt.sy)
+			if !ok {
+				t.Fatal("no ray")
+			}
+			if !near(r.orig, tt.orig, eps) {
+				t.Errorf("origin %v, want %v", r.orig, tt.orig)
+			}
+			if !near(r.dir.unit(), tt.dir, eps) {
+				t.Errorf("direction %v, want %v", r.dir.unit(), tt.dir)
+			}
+		})
+	}
+}
+
+func TestGetRayUnit(t *testing.T) {
+	for _, proj := range []projection{perspective, orthographic, fisheye, equirectangular} {
+		c := newCamera(64, 48)
+		c.projection = proj
+		c.init()
+		for j := 0; j < 48; j += 7 {
+			for i := 0; i < 64; i += 5 {
+				r, ok := c.getRay(i, j, 0.3, 0.8)
+				if ok && math.Abs(r.dir.length()-1) > eps {
+					t.Errorf("projection %d pixel %d,%d: direction length %v", proj, i, j, r.dir.length())
+				}
+			}
+		}
+	}
+}
+
+func TestGetRayFisheyeCircle(t *testing.T) {
+	c := newCamera(100, 100)
+	c.projection = fisheye
+	c.init()
+	if _, ok := c.getRay(0, 0, 0, 0); ok {
+		t.Error("corner outside the circle got a ray")
+	}
+	if _, ok := c.getRay(50, 0, 0, 0.5); !ok {
+		t.Error("top of the circle got no ray")
+	}
+}
+
+func TestGetRayFisheyeFOV(t *testing.T) {
+	// the edge of the circle is half the field of view off the axis
+	for _, fov := range []float64{90, 180, 220} {
+		c := newCamera(100, 100)
+		c.projection = fisheye
+		c.fisheyeFOV = fov
+		c.init()
+		r, _ := c.getRay(99, 50, 1, 0)
+		got := math.Acos(r.dir.unit().dot(vec3{0, 0, -1})) * 180 / math.Pi
+		if math.Abs(got-fov/2) > 1e-6 {
+			t.Errorf("fov %v: edge is %v degrees off axis, want %v", fov, got, fov/2)
+		}
+	}
+}
+
+func TestGetRayLookAt(t *testing.T) {
+	// every projection looks at lookAt through the image center, whichever
+	// way the camera is turned
+	for _, proj := range []projection{perspective, orthographic, fisheye, equirectangular} {
+		c := newCamera(64, 48)
+		c.lookFrom = vec3{3, 2, 5}
+		c.lookAt = vec3{-1, 0, 1}
+		c.projection = proj
+		c.init()
+		r, _ := c.getRay(32, 24, 0, 0)
+		want := c.lookAt.sub(c.lookFrom).unit()
+		if !near(r.dir.unit(), want, eps) {
+			t.Errorf("projection %d: center ray %v, want %v", proj, r.dir.unit(), want)
+		}
+		if !near(r.orig, c.lookFrom, eps) {
+			t.Errorf("projection %d: center ray starts at %v, want %v", proj, r.orig, c.lookFrom)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// span is one stretch of a ray inside a solid, with the outward surface
+// normals where the ray enters and leaves, and the primitives those surfaces
+// belong to
+type span struct {
+	tIn, tOut float64
+	nIn, nOut vec3
+	in, out   surface
+}
+
+// surface is a primitive a span can start or end on
+type surface interface {
+	// uvAt is the texture coordinates and tangent at p, the same as the
+	// primitive's own hit gives. n is the normal there, either way round
+	uvAt(p, n vec3) (u, v float64, tangent vec3)
+}
+
+// solid is a closed object that can list every span of a ray inside it, not
+// just the nearest hit. constructive solid geometry works on these lists
+type solid interface {
+	hittable
+	spans(r ray) []span // sorted by tIn, non overlapping, t may be negative
+}
+
+// hitSpans turns a solid's spans into the nearest surface crossing in range.
+// entering uses the entry normal, a ray that starts inside hits the exit
+func hitSpans(spans []span, r ray, tMin, tMax float64, rec *hitRecord) bool {
+	for _, s := range spans {
+		var t float64
+		var n vec3
+		var surf surface
+		switch {
+		case s.tIn > tMin && s.tIn < tMax:
+			t, n, surf = s.tIn, s.nIn, s.in
+		case s.tOut > tMin && s.tOut < tMax:
+			t, n, surf = s.tOut, s.nOut, s.out
+		default:
+			continue
+		}
+		rec.t = t
+		rec.p = r.at(t)
+		rec.setFaceNormal(r, n)
+		rec.u, rec.v, rec.tangent = surf.uvAt(rec.p, n)
+		return true
+	}
+	return false
+}
+
+// both roots of the sphere quadratic, unlike hitSphere which keeps the near one
+func (s *sphere) spans(r ray) []span {
+	oc := r.orig.sub(s.center)
+	roots := solveQuadratic(r.dir.dot(r.dir), 2*oc.dot(r.dir), oc.dot(oc)-s.radius*s.radius)
+	if len(roots) < 2 {
+		return nil // a single root only grazes
+	}
+	return []span{{
+		tIn:  roots[0],
+		tOut: roots[1],
+		nIn:  r.at(roots[0]).sub(s.center).div(s.radius),
+		nOut: r.at(roots[1]).sub(s.center).div(s.radius),
+		in:   s,
+		out:  s,
+	}}
+}
+
+func (s *sphere) uvAt(p, _ vec3) (float64, float64, vec3) {
+	outward := p.sub(s.center).div(s.radius)
+	u, v := sphereUV(outward)
+	return u, v, sphereTangent(outward)
+}
+
+// axis aligned box
+type box struct {
+	min, max vec3
+}
+
+func (b *box) spans(r ray) []span {
+	tIn, tOut := math.Inf(-1), math.Inf(1)
+	var nIn, nOut vec3
+
+	for n := range 3 {
+		lo, hi := aabb{b.min, b.max}.axis(n)
+		o, d := component(r.orig, n), component(r.dir, n)
+
+		axis := vec3{}
+		switch n {
+		case 0:
+			axis.x = 1
+		case 1:
+			axis.y = 1
+		default:
+			axis.z = 1
+		}
+
+		if d == 0 {
+			if o < lo || o > hi {
+				return nil
+			}
+			continue
+		}
+
+		// enter through the lo face when moving up the axis, hi otherwise
+		t0, t1 := (lo-o)/d, (hi-o)/d
+		n0, n1 := axis.neg(), axis
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			n0, n1 = n1, n0
+		}
+		if t0 > tIn {
+			tIn, nIn = t0, n0
+		}
+		if t1 < tOut {
+			tOut, nOut = t1, n1
+		}
+	}
+	if tIn >= tOut {
+		return nil
+	}
+	return []span{{tIn, tOut, nIn, nOut, b, b}}
+}
+
+// uvAt maps each face onto 0..1 across it, u along the next axis round from
+// the face's and v along the one after
+func (b *box) uvAt(p, n vec3) (float64, float64, vec3) {
+	face := 0
+	if math.Abs(n.y) > math.Abs(component(n, face)) {
+		face = 1
+	}
+	if math.Abs(n.z) > math.Abs(component(n, face)) {
+		face = 2
+	}
+	across := func(axis int) float64 {
+		lo, hi := aabb{b.min, b.max}.axis(axis)
+		return (component(p, axis) - lo) / (hi - lo)
+	}
+	ua, va := (face+1)%3, (face+2)%3
+	tangent := [3]vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}[ua]
+	return across(ua), across(va), tangent
+}
+
+func (b *box) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	return hitSpans(b.spans(r), r, tMin, tMax, rec)
+}
+
+func (b *box) boundingBox() aabb {
+	return aabb{b.min, b.max}
+}
+
+// the cylinder is the infinite tube's span clipped to the slab between its caps
+func (cy *cylinder) spans(r ray) []span {
+	oc := r.orig.sub(cy.base)
+	dy := r.dir.dot(cy.axis)
+	oy := oc.dot(cy.axis)
+
+	// slab between the caps
+	capIn, capOut := math.Inf(-1), math.Inf(1)
+	nCapIn, nCapOut := vec3{}, vec3{}
+	if dy == 0 {
+		if oy < 0 || oy > cy.height {
+			return nil
+		}
+	} else {
+		capIn, capOut = -oy/dy, (cy.height-oy)/dy
+		nCapIn, nCapOut = cy.axis.neg(), cy.axis
+		if capIn > capOut {
+			capIn, capOut = capOut, capIn
+			nCapIn, nCapOut = nCapOut, nCapIn
+		}
+	}
+
+	// infinite tube
+	dPerp := r.dir.sub(cy.axis.mul(dy))
+	ocPerp := oc.sub(cy.axis.mul(oy))
+	a := dPerp.dot(dPerp)
+	c := ocPerp.dot(ocPerp) - cy.radius*cy.radius
+	tubeIn, tubeOut := math.Inf(-1), math.Inf(1)
+	if a < 1e-12 {
+		if c > 0 { // parallel to the axis and outside the tube
+			return nil
+		}
+	} else {
+		roots := solveQuadratic(a, 2*dPerp.dot(ocPerp), c)
+		if len(roots) < 2 {
+			return nil
+		}
+		tubeIn, tubeOut = roots[0], roots[1]
+	}
+	sideNormal := func(t float64) vec3 {
+		q := r.at(t).sub(cy.base)
+		return q.sub(cy.axis.mul(q.dot(cy.axis))).div(cy.radius)
+	}
+
+	s := span{tIn: tubeIn, tOut: tubeOut, in: cy, out: cy}
+	if capIn > tubeIn {
+		s.tIn, s.nIn = capIn, nCapIn
+	} else {
+		s.nIn = sideNormal(tubeIn)
+	}
+	if capOut < tubeOut {
+		s.tOut, s.nOut = capOut, nCapOut
+	} else {
+		s.nOut = sideNormal(tubeOut)
+	}
+	if s.tIn >= s.tOut {
+		return nil
+	}
+	return []span{s}
+}
+
+// uvAt follows cylinder.hit: around the axis and up it on the side, polar on
+// the caps, and no tangent
+func (cy *cylinder) uvAt(p, n vec3) (float64, float64, vec3) {
+	y := p.sub(cy.base).dot(cy.axis)
+	if math.Abs(n.dot(cy.axis)) > 0.5 {
+		if y < cy.height/2 {
+			u, v := polarUV(p, cy.base, cy.axis.neg(), cy.radius)
+			return u, v, vec3{}
+		}
+		u, v := polarUV(p, cy.base.add(cy.axis.mul(cy.height)), cy.axis, cy.radius)
+		return u, v, vec3{}
+	}
+	u, _ := polarUV(p, cy.base.add(cy.axis.mul(y)), cy.axis, cy.radius)
+	return u, y / cy.height, vec3{}
+}
+
+type csgOp int
+
+const (
+	csgUnion csgOp = iota
+	csgIntersection
+	csgDifference // left minus right
+)
+
+// csg combines two solids. it is itself a solid so trees of any depth work
+type csg struct {
+	op          csgOp
+	left, right solid
+}
+
+func newCSG(op csgOp, left, right solid) *csg {
+	return &csg{op, left, right}
+}
+
+func (c *csg) spans(r ray) []span {
+	a, b := c.left.spans(r), c.right.spans(r)
+	switch c.op {
+	case csgUnion:
+		return unionSpans(a, b)
+	case csgIntersection:
+		return intersectSpans(a, b)
+	case csgDifference:
+		return subtractSpans(a, b)
+	}
+	panic(fmt.Sprintf("unknown csg op %d", c.op))
+}
+
+func (c *csg) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	// cheap reject before walking both children
+	if !c.boundingBox().hit(r, tMin, tMax) {
+		return false
+	}
+	return hitSpans(c.spans(r), r, tMin, tMax, rec)
+}
+
+func (c *csg) boundingBox() aabb {
+	l, r := c.left.boundingBox(), c.right.boundingBox()
+	switch c.op {
+	case csgIntersection:
+		return aabb{
+			vec3{math.Max(l.min.x, r.min.x), math.Max(l.min.y, r.min.y), math.Max(l.min.z, r.min.z)},
+			vec3{math.Min(l.max.x, r.max.x), math.Min(l.max.y, r.max.y), math.Min(l.max.z, r.max.z)},
+		}
+	case csgDifference:
+		return l
+	}
+	return l.union(r)
+}
+
+func unionSpans(a, b []span) []span {
+	// merge by start, then fold overlapping neighbours together
+	all := make([]span, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if j >= len(b) || (i < len(a) && a[i].tIn <= b[j].tIn) {
+			all = append(all, a[i])
+			i++
+		} else {
+			all = append(all, b[j])
+			j++
+		}
+	}
+
+	var out []span
+	for _, s := range all {
+		if n := len(out); n > 0 && s.tIn <= out[n-1].tOut {
+			if s.tOut > out[n-1].tOut {
+				out[n-1].tOut, out[n-1].nOut, out[n-1].out = s.tOut, s.nOut, s.out
+			}
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func intersectSpans(a, b []span) []span {
+	var out []span
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		s := a[i]
+		if b[j].tIn > s.tIn {
+			s.tIn, s.nIn, s.in = b[j].tIn, b[j].nIn, b[j].in
+		}
+		if b[j].tOut < s.tOut {
+			s.tOut, s.nOut, s.out = b[j].tOut, b[j].nOut, b[j].out
+		}
+		if s.tIn < s.tOut {
+			out = append(out, s)
+		}
+		// drop whichever span ends first
+		if a[i].tOut < b[j].tOut {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// subtractSpans removes b from a. where b cuts into a, the new surface is b's
+// surface seen from inside b, so its normals flip
+func subtractSpans(a, b []span) []span {
+	var out []span
+	for _, s := range a {
+		pieces := []span{s}
+		for _, cut := range b {
+			var next []span
+			for _, p := range pieces {
+				if cut.tOut <= p.tIn || cut.tIn >= p.tOut {
+					next = append(next, p)
+					continue
+				}
+				if cut.tIn > p.tIn {
+					next = append(next, span{p.tIn, cut.tIn, p.nIn, cut.nIn.neg(), p.in, cut.in})
+				}
+				if cut.tOut < p.tOut {
+					next = append(next, span{cut.tOut, p.tOut, cut.nOut.neg(), p.nOut, cut.out, p.out})
+				}
+			}
+			pieces = next
+		}
+		out = append(out, pieces...)
+	}
+	return out
+}
+
+// csgScene models a biconvex lens, a carved block, a tube and a capsule-ish union
+func csgScene() *scene {
+	lens := newCSG(csgIntersection,
+		&sphere{center: vec3{-1.1, 0, -1.2}, radius: 0.6},
+		&sphere{center: vec3{-1.1, 0, -2.0}, radius: 0.6},
+	)
+	carved := newCSG(csgDifference,
+		&box{min: vec3{-0.35, -0.5, -1.95}, max: vec3{0.35, 0.2, -1.25}},
+		&sphere{center: vec3{0, 0.2, -1.6}, radius: 0.4},
+	)
+	tube := newCSG(csgDifference,
+		&cylinder{base: vec3{1.1, -0.5, -1.6}, axis: vec3{0, 1, 0}, radius: 0.3, height: 0.6},
+		&cylinder{base: vec3{1.1, -0.6, -1.6}, axis: vec3{0, 1, 0}, radius: 0.2, height: 0.8},
+	)
+	pill := newCSG(csgUnion,
+		newCSG(csgUnion,
+			&sphere{center: vec3{-0.3, 0.55, -2.4}, radius: 0.2},
+			&sphere{center: vec3{0.3, 0.55, -2.4}, radius: 0.2},
+		),
+		&cylinder{base: vec3{-0.3, 0.55, -2.4}, axis: vec3{1, 0, 0}, radius: 0.2, height: 0.6},
+	)
+
+	return &scene{
+		objects: hittableList{
+			lens,
+			&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}},
+			carved,
+			tube,
+			pill,
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// mark is a stand in surface, so the tests can see which spans' ends end up
+// where
+type mark int
+
+func (m mark) uvAt(p, n vec3) (float64, float64, vec3) { return float64(m), 0, vec3{} }
+
+// sp is a span between two marks. its normals are as long as the mark, along
+// x on the way in and y on the way out
+func sp(tIn, tOut float64, in, out mark) span {
+	return span{tIn, tOut, vec3{float64(in), 0, 0}, vec3{0, float64(out), 0}, in, out}
+}
+
+func TestUnionSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []span
+		want []span
+	}{
+		{"empty", nil, nil, nil},
+		{"one side", []span{sp(1, 2, 1, 2)}, nil, []span{sp(1, 2, 1, 2)}},
+		{"disjoint", []span{sp(3, 4, 1, 2)}, []span{sp(1, 2, 3, 4)}, []span{sp(1, 2, 3, 4), sp(3, 4, 1, 2)}},
+		{"touching", []span{sp(1, 2, 1, 2)}, []span{sp(2, 3, 3, 4)}, []span{sp(1, 3, 1, 4)}},
+		{"overlapping", []span{sp(1, 3, 1, 2)}, []span{sp(2, 4, 3, 4)}, []span{sp(1, 4, 1, 4)}},
+		{"nested", []span{sp(1, 4, 1, 2)}, []span{sp(2, 3, 3, 4)}, []span{sp(1, 4, 1, 2)}},
+		{"bridged", []span{sp(1, 2, 1, 2), sp(3, 4, 5, 6)}, []span{sp(1.5, 3.5, 3, 4)}, []span{sp(1, 4, 1, 6)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unionSpans(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+			// and the other way round
+			if got := unionSpans(tt.b, tt.a); !slices.Equal(got, tt.want) {
+				t.Errorf("swapped: got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []span
+		want []span
+	}{
+		{"empty", []span{sp(1, 2, 1, 2)}, nil, nil},
+		{"disjoint", []span{sp(1, 2, 1, 2)}, []span{sp(3, 4, 3, 4)}, nil},
+		{"touching", []span{sp(1, 2, 1, 2)}, []span{sp(2, 3, 3, 4)}, nil},
+		// each end comes from whichever solid it's on
+		{"overlapping", []span{sp(1, 3, 1, 2)}, []span{sp(2, 4, 3, 4)}, []span{sp(2, 3, 3, 2)}},
+		{"nested", []span{sp(1, 4, 1, 2)}, []span{sp(2, 3, 3, 4)}, []span{sp(2, 3, 3, 4)}},
+		{"two pieces", []span{sp(1, 4, 1, 2)}, []span{sp(0, 2, 3, 4), sp(3, 5, 5, 6)}, []span{sp(1, 2, 1, 4), sp(3, 4, 5, 2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersectSpans(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+			if got := intersectSpans(tt.b, tt.a); !slices.Equal(got, tt.want) {
+				t.Errorf("swapped: got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtractSpans(t *testing.T) {
+	// where the cutting solid cuts, the normal is its own turned round,
+	// since the new surface faces into where it was
+	cutIn := func(m mark) vec3 { return vec3{-float64(m), 0, 0} }
+	cutOut := func(m mark) vec3 { return vec3{0, -float64(m), 0} }
+	tests := []struct {
+		name string
+		a, b []span
+		want []span
+	}{
+		{"nothing to cut", []span{sp(1, 2, 1, 2)}, nil, []span{sp(1, 2, 1, 2)}},
+		{"disjoint", []span{sp(1, 2, 1, 2)}, []span{sp(3, 4, 3, 4)}, []span{sp(1, 2, 1, 2)}},
+		{"touching", []span{sp(1, 2, 1, 2)}, []span{sp(2, 3, 3, 4)}, []span{sp(1, 2, 1, 2)}},
+		{"all of it", []span{sp(2, 3, 1, 2)}, []span{sp(1, 4, 3, 4)}, nil},
+		{"exactly", []span{sp(2, 3, 1, 2)}, []span{sp(2, 3, 3, 4)}, nil},
+		{"the front", []span{sp(2, 4, 1, 2)}, []span{sp(1, 3, 3, 4)}, []span{
+			{3, 4, cutOut(4), vec3{0, 2, 0}, mark(4), mark(2)},
+		}},
+		{"the back", []span{sp(1, 3, 1, 2)}, []span{sp(2, 4, 3, 4)}, []span{
+			{1, 2, vec3{1, 0, 0}, cutIn(3), mark(1), mark(3)},
+		}},
+		{"split in two", []span{sp(1, 4, 1, 2)}, []span{sp(2, 3, 3, 4)}, []span{
+			{1, 2, vec3{1, 0, 0}, cutIn(3), mark(1), mark(3)},
+			{3, 4, cutOut(4), vec3{0, 2, 0}, mark(4), mark(2)},
+		}},
+		{"twice", []span{sp(0, 10, 1, 2)}, []span{sp(2, 3, 3, 4), sp(5, 6, 5, 6)}, []span{
+			{0, 2, vec3{1, 0, 0}, cutIn(3), mark(1), mark(3)},
+			{3, 5, cutOut(4), cutIn(5), mark(4), mark(5)},
+			{6, 10, cutOut(6), vec3{0, 2, 0}, mark(6), mark(2)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtractSpans(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameSurface checks that a csg hit has what the primitive's own hit has
+func sameSurface(t *testing.T, got, want hitRecord) {
+	t.Helper()
+	if math.Abs(got.t-want.t) > 1e-9 || got.normal.sub(want.normal).length() > 1e-9 || got.frontFace != want.frontFace {
+		t.Errorf("hit at t %v normal %v front %v, want t %v normal %v front %v",
+			got.t, got.normal, got.frontFace, want.t, want.normal, want.frontFace)
+	}
+	if math.Abs(got.u-want.u) > 1e-9 || math.Abs(got.v-want.v) > 1e-9 || got.tangent.sub(want.tangent).length() > 1e-9 {
+		t.Errorf("uv %v,%v tangent %v, want %v,%v tangent %v", got.u, got.v, got.tangent, want.u, want.v, want.tangent)
+	}
+}
+
+func TestCSGCut(t *testing.T) {
+	// a bowl carved out of the top of a cube
+	bowl := &sphere{center: vec3{0, 0, 1}, radius: 0.5}
+	block := &box{min: vec3{-1, -1, -1}, max: vec3{1, 1, 1}}
+	carved := newCSG(csgDifference, block, bowl)
+
+	// straight down into the bowl the ray meets its floor, which faces up
+	// out of the block: the sphere's normal turned round
+	down := ray{vec3{0, 0, 5}, vec3{0, 0, -1}}
+	var rec hitRecord
+	if !carved.hit(down, 0.001, math.Inf(1), &rec) {
+		t.Fatal("missed the bowl")
+	}
+	// the sphere's own hit from inside it, at the same point, facing the
+	// same way
+	var want hitRecord
+	bowl.hit(ray{bowl.center, down.dir}, 0.001, math.Inf(1), &want)
+	want.t = 4.5
+	want.frontFace = true
+	sameSurface(t, rec, want)
+
+	// beside the bowl it's the block's top face, mapped across the face
+	rec = hitRecord{}
+	if !carved.hit(ray{vec3{0.9, 0.2, 5}, vec3{0, 0, -1}}, 0.001, math.Inf(1), &rec) {
+		t.Fatal("missed the block")
+	}
+	sameSurface(t, rec, hitRecord{t: 4, normal: vec3{0, 0, 1}, frontFace: true, u: 0.95, v: 0.6, tangent: vec3{1, 0, 0}})
+}
+
+func TestCSGSurfaces(t *testing.T) {
+	// a hit on a csg solid has the uv and tangent of the child it landed on
+	post := &cylinder{base: vec3{0, -1, 0}, axis: vec3{0, 1, 0}, radius: 0.5, height: 2}
+	ball := &sphere{center: vec3{0, 1.5, 0}, radius: 0.7}
+	far := &sphere{center: vec3{0, 0, -1.6}, radius: 0.6}
+	near := &sphere{center: vec3{0, 0, -0.8}, radius: 0.6}
+	lens := newCSG(csgIntersection, near, far)
+	tests := []struct {
+		name  string
+		obj   solid
+		child hittable
+		r     ray
+	}{
+		{"cylinder side", newCSG(csgUnion, post, ball), post, ray{vec3{5, 0.3, 0.1}, vec3{-1, 0, 0}}},
+		{"cylinder bottom", newCSG(csgUnion, post, ball), post, ray{vec3{0.2, -5, 0.1}, vec3{0, 1, 0}}},
+		{"sphere in a union", newCSG(csgUnion, post, ball), ball, ray{vec3{0.1, 5, 0.2}, vec3{0, -1, 0}}},
+		// the lens is entered through the far sphere and left through the near
+		{"lens front", lens, far, ray{vec3{0.1, 0.05, 0}, vec3{0, 0, -1}}},
+		{"lens back", lens, near, ray{vec3{0.1, 0.05, -1.3}, vec3{0, 0, -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, want hitRecord
+			if !tt.obj.hit(tt.r, 0.001, math.Inf(1), &got) {
+				t.Fatal("missed")
+			}
+			if !tt.child.hit(tt.r, 0.001, math.Inf(1), &want) {
+				t.Fatal("child missed")
+			}
+			sameSurface(t, got, want)
+		})
+	}
+}
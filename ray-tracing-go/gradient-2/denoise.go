@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"gfx/capture"
+)
+
+// denoiser is an edge-avoiding a-trous wavelet filter (Dammertz et al. 2010).
+// each pass blurs with a 5x5 b-spline kernel whose taps spread twice as far
+// apart as the pass before, so a few passes cover a wide area cheaply. taps
+// are weighted down where color, normal or albedo differ from the center
+// pixel, which keeps edges and texture sharp while the noise averages out
+type denoiser struct {
+	iterations  int
+	sigmaColor  float64 // how different a tap's color may be, halved each pass
+	sigmaNormal float64 // exponent on the normal dot product
+	sigmaAlbedo float64
+}
+
+func newDenoiser() *denoiser {
+	return &denoiser{
+		iterations:  5,
+		sigmaColor:  0.5,
+		sigmaNormal: 64,
+		sigmaAlbedo: 0.1,
+	}
+}
+
+var atrousKernel = [5]float64{1.0 / 16, 1.0 / 4, 3.0 / 8, 1.0 / 4, 1.0 / 16}
+
+// denoise returns a filtered copy of color. normal and albedo may be nil
+func (d *denoiser) denoise(width, height int, color, normal, albedo []vec3) []vec3 {
+	cur := append([]vec3(nil), color...)
+	next := make([]vec3, len(color))
+
+	sigmaColor := d.sigmaColor
+	for it := range d.iterations {
+		step := 1 << it
+		for j := range height {
+			for i := range width {
+				k := j*width + i
+				var sum vec3
+				wsum := 0.0
+
+				for dy := -2; dy <= 2; dy++ {
+					y := j + dy*step
+					if y < 0 || y >= height {
+						continue
+					}
+					for dx := -2; dx <= 2; dx++ {
+						x := i + dx*step
+						if x < 0 || x >= width {
+							continue
+						}
+						q := y*width + x
+						w := atrousKernel[dx+2] * atrousKernel[dy+2]
+
+						dc := cur[k].sub(cur[q]).lengthSquared()
+						w *= math.Exp(-dc / (sigmaColor * sigmaColor))
+						// sky pixels have no normal: they blend with each other
+						// freely and, through the zero dot product, never with surfaces
+						if normal != nil && (normal[k] != vec3{} || normal[q] != vec3{}) {
+							w *= math.Pow(math.Max(0, normal[k].dot(normal[q])), d.sigmaNormal)
+						}
+						if albedo != nil {
+							da := albedo[k].sub(albedo[q]).lengthSquared()
+							w *= math.Exp(-da / (d.sigmaAlbedo * d.sigmaAlbedo))
+						}
+
+						sum = sum.add(cur[q].mul(w))
+						wsum += w
+					}
+				}
+				// the center tap's weight is only its kernel weight times its
+				// normal with itself, which underflows for a normal averaged
+				// to about zero. with nothing to go on, keep the pixel
+				if wsum > 0 {
+					next[k] = sum.div(wsum)
+				} else {
+					next[k] = cur[k]
+				}
+			}
+		}
+		cur, next = next, cur
+		sigmaColor /= 2
+	}
+	return cur
+}
+
+// pfm is the portable float map: a tiny text header then raw float32 rgb,
+// bottom row first. a negative scale means little endian
+func writePFM(path string, width, height int, pix []vec3) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "PF\n%d %d\n-1.0\n", width, height)
+
+	row := make([]float32, width*3)
+	for j := height - 1; j >= 0; j-- {
+		for i := range width {
+			c := pix[j*width+i]
+			row[i*3+0], row[i*3+1], row[i*3+2] = float32(c.x), float32(c.y), float32(c.z)
+		}
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPFM(path string) (width, height int, pix []vec3, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var magic string
+	var scale float64
+	if _, err := fmt.Fscan(r, &magic, &width, &height, &scale); err != nil {
+		return 0, 0, nil, fmt.Errorf("%s: bad pfm header: %w", path, err)
+	}
+	channels := 3
+	switch magic {
+	case "PF":
+	case "Pf":
+		channels = 1 // greyscale
+	default:
+		return 0, 0, nil, fmt.Errorf("%s: not a pfm file", path)
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, nil, fmt.Errorf("%s: bad pfm size %dx%d", path, width, height)
+	}
+	// exactly one whitespace byte separates the header from the data
+	if _, err := r.ReadByte(); err != nil {
+		return 0, 0, nil, err
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	pix = make([]vec3, width*height)
+	row := make([]float32, width*channels)
+	for j := height - 1; j >= 0; j-- {
+		if err := binary.Read(r, order, row); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = fmt.Errorf("%s: pfm data is truncated", path)
+			}
+			return 0, 0, nil, err
+		}
+		for i := range width {
+			if channels == 1 {
+				v := float64(row[i])
+				pix[j*width+i] = vec3{v, v, v}
+			} else {
+				pix[j*width+i] = vec3{float64(row[i*3]), float64(row[i*3+1]), float64(row[i*3+2])}
+			}
+		}
+	}
+	return width, height, pix, nil
+}
+
+// writeFilmPFM saves color, normal and albedo side by side, e.g. out.pfm,
+// out_normal.pfm and out_albedo.pfm, ready for the denoise command
+func writeFilmPFM(path string, f *film) error {
+	base := strings.TrimSuffix(path, ".pfm")
+	for _, layer := range []struct {
+		path string
+		pix  []vec3
+	}{
+		{base + ".pfm", f.color},
+		{base + "_normal.pfm", f.normal},
+		{base + "_albedo.pfm", f.albedo},
+	} {
+		if err := writePFM(layer.path, f.width, f.height, layer.pix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// denoiseCommand is `gradient-2 denoise`, which filters saved pfm renders
+// without re-rendering them
+func denoiseCommand(args []string) error {
+	fs := flag.NewFlagSet("denoise", flag.ExitOnError)
+	in := fs.String("in", "", "noisy color pfm")
+	normalPath := fs.String("normal", "", "normal pfm (defaults to <in>_normal.pfm if present)")
+	albedoPath := fs.String("albedo", "", "albedo pfm (defaults to <in>_albedo.pfm if present)")
+	out := fs.String("out", "denoised.pfm", "filtered pfm output")
+	pngPath := fs.String("png", "", "also write the result as a png")
+	d := newDenoiser()
+	fs.IntVar(&d.iterations, "iterations", d.iterations, "a-trous passes")
+	fs.Float64Var(&d.sigmaColor, "sigma-color", d.sigmaColor, "color edge stopping")
+	fs.Float64Var(&d.sigmaNormal, "sigma-normal", d.sigmaNormal, "normal edge stopping exponent")
+	fs.Float64Var(&d.sigmaAlbedo, "sigma-albedo", d.sigmaAlbedo, "albedo edge stopping")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("denoise needs -in")
+	}
+	if d.iterations < 1 || d.sigmaColor <= 0 || d.sigmaAlbedo <= 0 || d.sigmaNormal < 0 {
+		return fmt.Errorf("denoise needs a positive iteration count and sigmas")
+	}
+	width, height, color, err := readPFM(*in)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(*in, ".pfm")
+	guide := func(path, fallback string) ([]vec3, error) {
+		if path == "" {
+			if _, err := os.Stat(fallback); err != nil {
+				return nil, nil // no guide, filter on color alone
+			}
+			path = fallback
+		}
+		w, h, pix, err := readPFM(path)
+		if err != nil {
+			return nil, err
+		}
+		if w != width || h != height {
+			return nil, fmt.Errorf("%s is %dx%d but %s is %dx%d", path, w, h, *in, width, height)
+		}
+		return pix, nil
+	}
+	normal, err := guide(*normalPath, base+"_normal.pfm")
+	if err != nil {
+		return err
+	}
+	albedo, err := guide(*albedoPath, base+"_albedo.pfm")
+	if err != nil {
+		return err
+	}
+
+	result := d.denoise(width, height, color, normal, albedo)
+	if err := writePFM(*out, width, height, result); err != nil {
+		return err
+	}
+	if *pngPath != "" {
+		f := &film{width: width, height: height, color: result}
+		if err := capture.WritePNG(*pngPath, f.image()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
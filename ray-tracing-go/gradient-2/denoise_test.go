@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+)
+
+// noisyInput is a fixed 32x16 render: the left half a grey wall facing +z,
+// the right half a brighter one facing +x, both with per pixel noise
+func noisyInput() (width, height int, color, normal, albedo []vec3) {
+	width, height = 32, 16
+	rng := rand.New(rand.NewPCG(7, 7))
+	for range height {
+		for i := range width {
+			base, n, a := 0.2, vec3{0, 0, 1}, vec3{0.5, 0.5, 0.5}
+			if i >= width/2 {
+				base, n, a = 0.8, vec3{1, 0, 0}, vec3{0.8, 0.8, 0.8}
+			}
+			noise := (rng.Float64() - 0.5) * 0.2
+			color = append(color, vec3{base + noise, base + noise, base + noise})
+			normal = append(normal, n)
+			albedo = append(albedo, a)
+		}
+	}
+	return width, height, color, normal, albedo
+}
+
+// halfStats is the mean and variance of the red channel over columns [i0, i1)
+func halfStats(width, height int, pix []vec3, i0, i1 int) (mean, variance float64) {
+	n := 0.0
+	for j := range height {
+		for i := i0; i < i1; i++ {
+			mean += pix[j*width+i].x
+			n++
+		}
+	}
+	mean /= n
+	for j := range height {
+		for i := i0; i < i1; i++ {
+			d := pix[j*width+i].x - mean
+			variance += d * d
+		}
+	}
+	return mean, variance / n
+}
+
+func TestDenoiseStable(t *testing.T) {
+	w, h, color, normal, albedo := noisyInput()
+	d := newDenoiser()
+	out := d.denoise(w, h, color, normal, albedo)
+
+	// the same input comes out the same every time, and the input is left alone
+	w2, h2, color2, normal2, albedo2 := noisyInput()
+	again := d.denoise(w2, h2, color2, normal2, albedo2)
+	for k := range out {
+		if out[k] != again[k] {
+			t.Fatalf("pixel %d: %v then %v", k, out[k], again[k])
+		}
+		if color[k] != color2[k] {
+			t.Fatalf("pixel %d of the input changed", k)
+		}
+	}
+
+	// each wall keeps its brightness with much less noise, and the edge
+	// between them doesn't bleed
+	for _, half := range []struct {
+		name   string
+		i0, i1 int
+		want   float64
+	}{
+		{"left", 0, w / 2, 0.2},
+		{"right", w / 2, w, 0.8},
+	} {
+		inMean, inVar := halfStats(w, h, color, half.i0, half.i1)
+		outMean, outVar := halfStats(w, h, out, half.i0, half.i1)
+		if math.Abs(outMean-inMean) > 0.01 {
+			t.Errorf("%s: mean %v, input's %v", half.name, outMean, inMean)
+		}
+		if outVar > inVar/10 {
+			t.Errorf("%s: variance %v, input's %v", half.name, outVar, inVar)
+		}
+		for j := range h {
+			for i := half.i0; i < half.i1; i++ {
+				if v := out[j*w+i].x; math.Abs(v-half.want) > 0.1 {
+					t.Fatalf("%s pixel %d,%d is %v, want about %v", half.name, i, j, v, half.want)
+				}
+			}
+		}
+	}
+}
+
+func TestDenoiseColorOnly(t *testing.T) {
+	// without guides the color term alone still smooths the noise
+	w, h, color, _, _ := noisyInput()
+	out := newDenoiser().denoise(w, h, color, nil, nil)
+	_, inVar := halfStats(w, h, color, 0, w/2)
+	_, outVar := halfStats(w, h, out, 0, w/2)
+	if outVar >= inVar/2 {
+		t.Errorf("variance %v, input's %v", outVar, inVar)
+	}
+}
+
+func TestDenoiseDegenerateNormals(t *testing.T) {
+	// normals averaged over a pixel can cancel to almost nothing, which
+	// zeroes every tap's weight, the center's included
+	w, h, color, normal, albedo := noisyInput()
+	for k := range normal {
+		normal[k] = normal[k].mul(1e-6)
+	}
+	out := newDenoiser().denoise(w, h, color, normal, albedo)
+	for k, c := range out {
+		if math.IsNaN(c.x) || math.IsNaN(c.y) || math.IsNaN(c.z) {
+			t.Fatalf("pixel %d is %v", k, c)
+		}
+		if c != color[k] {
+			t.Fatalf("pixel %d is %v, want it kept at %v", k, c, color[k])
+		}
+	}
+}
+
+func TestPFMRoundTrip(t *testing.T) {
+	w, h, color, _, _ := noisyInput()
+	path := filepath.Join(t.TempDir(), "noisy.pfm")
+	if err := writePFM(path, w, h, color); err != nil {
+		t.Fatal(err)
+	}
+	w2, h2, got, err := readPFM(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w2 != w || h2 != h {
+		t.Fatalf("size %dx%d, want %dx%d", w2, h2, w, h)
+	}
+	for k := range color {
+		// stored as float32
+		if !near(got[k], color[k], 1e-7) {
+			t.Fatalf("pixel %d: %v, want %v", k, got[k], color[k])
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import "math"
+
+type hitRecord struct {
+	t         float64
+	p         vec3
+	normal    vec3 // unit, always facing against the incoming ray
+	frontFace bool // whether the ray hit the outside of the surface
+	u, v      float64
+	tangent   vec3     // direction of increasing u, zero if the object has none
+	mat       material // nil for the default grey
+}
+
+// setFaceNormal flips an outward normal to face the ray and remembers the side
+func (rec *hitRecord) setFaceNormal(r ray, outward vec3) {
+	rec.frontFace = r.dir.dot(outward) < 0
+	if rec.frontFace {
+		rec.normal = outward
+	} else {
+		rec.normal = outward.neg()
+	}
+}
+
+type hittable interface {
+	// hit reports the nearest intersection with t in (tMin, tMax)
+	hit(r ray, tMin, tMax float64, rec *hitRecord) bool
+	boundingBox() aabb
+}
+
+type hittableList []hittable
+
+func (l hittableList) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	hitAnything := false
+	closest := tMax
+	for _, obj := range l {
+		// a fresh record per object, so a plain object doesn't keep the
+		// material or tangent of something the list hit before it
+		var local hitRecord
+		if obj.hit(r, tMin, closest, &local) {
+			hitAnything = true
+			closest = local.t
+			*rec = local
+		}
+	}
+	return hitAnything
+}
+
+func (l hittableList) boundingBox() aabb {
+	box := emptyBox()
+	for _, obj := range l {
+		box = box.union(obj.boundingBox())
+	}
+	return box
+}
+
+type sphere struct {
+	center vec3
+	radius float64
+}
+
+// hitSphere returns the nearest root of the ray/sphere quadratic beyond tMin,
+// or -1 when the ray misses or the sphere is entirely behind the origin. a ray
+// that starts inside the sphere, or on it heading in, gets the far root.
+//
+// with b = 2h the quadratic formula loses its factors of 2 and 4:
+// t = (h -+ sqrt(h*h - a*c)) / a where h = d.(c - o)
+func hitSphere(cx, cy, cz, radius float64, ox, oy, oz, dx, dy, dz, tMin float64) float64 {
+	// c -> center, o -> ray origin, d -> direction
+
+	ocx := cx - ox
+	ocy := cy - oy
+	ocz := cz - oz
+
+	h := dx*ocx + dy*ocy + dz*ocz
+	c := ocx*ocx + ocy*ocy + ocz*ocz - radius*radius
+
+	a := dx*dx + dy*dy + dz*dz
+	discriminant := h*h - a*c
+
+	// misses are the common case, test them first. then reject spheres that
+	// are entirely behind an origin outside them (c > 0, h < 0) before the sqrt
+	if discriminant < 0 || (c > 0 && h < 0) {
+		return -1.0
+	}
+	sq := math.Sqrt(discriminant)
+	t := (h - sq) / a
+	if t <= tMin {
+		t = (h + sq) / a
+	}
+	if t <= tMin {
+		return -1.0
+	}
+	return t
+}
+
+func (s *sphere) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	t := hitSphere(s.center.x, s.center.y, s.center.z, s.radius,
+		r.orig.x, r.orig.y, r.orig.z, r.dir.x, r.dir.y, r.dir.z, tMin)
+	if t <= tMin || t >= tMax {
+		return false
+	}
+
+	rec.t = t
+	rec.p = r.at(t)
+
+	// Normal vector: N = unit_vector(P - center)
+	outward := rec.p.sub(s.center).div(s.radius)
+	rec.setFaceNormal(r, outward)
+	rec.u, rec.v = sphereUV(outward)
+	rec.tangent = sphereTangent(outward)
+	return true
+}
+
+func (s *sphere) boundingBox() aabb {
+	rv := vec3{s.radius, s.radius, s.radius}
+	return aabb{s.center.sub(rv), s.center.add(rv)}
+}
+
+// sphereUV maps a point on the unit sphere to u = longitude around y from -x,
+// v = latitude from the bottom (-y) to the top
+func sphereUV(p vec3) (u, v float64) {
+	theta := math.Acos(clamp(-p.y, -1, 1))
+	phi := math.Atan2(-p.z, p.x) + math.Pi
+	return phi / (2 * math.Pi), theta / math.Pi
+}
+
+// sphereTangent is the direction u grows in, around y. it vanishes at the
+// poles so fall back to any perpendicular there
+func sphereTangent(n vec3) vec3 {
+	t := vec3{n.z, 0, -n.x}
+	if t.lengthSquared() < 1e-12 {
+		t, _ = tangentBasis(n)
+		return t
+	}
+	return t.unit()
+}
+
+// instance places an object with an offset and a uniform scale about a pivot,
+// which is how animation moves objects without touching their definitions
+type instance struct {
+	obj    hittable
+	pivot  vec3
+	offset vec3
+	scale  float64
+}
+
+func (in *instance) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	// move the ray into the object's space instead of the object into world
+	// space. scaling origin and direction together keeps t the same
+	local := ray{
+		in.pivot.add(r.orig.sub(in.pivot).sub(in.offset).div(in.scale)),
+		r.dir.div(in.scale),
+	}
+	if !in.obj.hit(local, tMin, tMax, rec) {
+		return false
+	}
+	// a uniform scale leaves normals alone, only the point moves back
+	rec.p = r.at(rec.t)
+	return true
+}
+
+func (in *instance) boundingBox() aabb {
+	b := in.obj.boundingBox()
+	place := func(p vec3) vec3 {
+		return in.pivot.add(in.offset).add(p.sub(in.pivot).mul(in.scale))
+	}
+	return aabb{place(b.min), place(b.max)}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
-	"math"
-	"unsafe"
+	"os"
+	"runtime"
+	"runtime/pprof"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"gfx/capture"
+	"gfx/framebuffer"
+	"gfx/platform"
 )
 
 const (
@@ -14,190 +20,262 @@ const (
 	winHeight   = int(float64(winWidth) / aspectRatio)
 )
 
-type color struct {
-	r, g, b byte
-}
-
-func setPixel(x, y int, c color, pixels []byte) {
-	if x < 0 || x >= winWidth || y < 0 || y >= winHeight {
-		return
+func main() {
+	if len(os.Args) > 1 {
+		var cmd func([]string) error
+		switch os.Args[1] {
+		case "denoise":
+			cmd = denoiseCommand
+		case "serve":
+			cmd = serveCommand
+		}
+		if cmd != nil {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
-	idx := (y*winWidth + x) * 3
-	if idx+2 >= len(pixels) {
-		return
+	if err := run(); err != nil {
+		log.Fatal(err)
 	}
-	pixels[idx+0] = c.r
-	pixels[idx+1] = c.g
-	pixels[idx+2] = c.b
 }
 
-func hitSphere(cx, cy, cz, radius float64, ox, oy, oz, dx, dy, dz float64) float64 {
-	// c -> center, o -> ray origin, d -> direction
-
-	ocx := ox - cx
-	ocy := oy - cy
-	ocz := oz - cz
+// run is the still and animation renders, returning instead of exiting so
+// the profiles are written out whatever happens
+func run() (err error) {
+	frames := flag.String("frames", "", "render an animation frame range `start:end` to png instead of opening a window")
+	length := flag.Int("length", 48, "frames in one full turntable turn")
+	keysPath := flag.String("keys", "", "animate with the camera and object keyframes in this `file` instead of the turntable")
+	interpName := flag.String("interp", "catmull-rom", "keyframe interpolation: linear or catmull-rom")
+	outDir := flag.String("out", "frames", "directory for numbered png frames")
+	gifPath := flag.String("gif", "", "also assemble the rendered frames into this animated gif")
+	fps := flag.Int("fps", 24, "gif playback rate")
+	projName := flag.String("projection", "perspective", "camera projection: perspective, orthographic, fisheye or equirectangular")
+	fisheyeFOV := flag.Float64("fisheye-fov", 180, "fisheye field of view in degrees")
+	sceneName := flag.String("scene", "default", "scene to render: default, shapes, sdf, csg, bump, spheres, prism, caustics or materials")
+	skyName := flag.String("sky", "gradient", "diffuse: sky, the gradient or the preetham daylight model with a sun")
+	turbidity := flag.Float64("turbidity", 3, "preetham sky: haze, from 2 for very clear to 10")
+	sunElevation := flag.Float64("sun-elevation", 35, "preetham sky: sun's height above the horizon in degrees")
+	sunAzimuth := flag.Float64("sun-azimuth", 160, "preetham sky: sun's compass bearing in degrees, the camera looks north")
+	clock := flag.String("time", "", "preetham sky: place the sun by local solar time `hh:mm` instead of elevation and azimuth")
+	latitude := flag.Float64("latitude", 45, "preetham sky with -time: latitude in degrees")
+	day := flag.Int("day", 172, "preetham sky with -time: day of the year")
+	normalMapPath := flag.String("normal-map", "", "bump scene: tangent space normal map image to use instead of the generated tiles")
+	marchSteps := flag.Int("march-steps", 256, "sdf scenes: ray marching iteration budget per ray")
+	marchEps := flag.Float64("march-eps", 1e-4, "sdf scenes: distance counted as a surface hit")
+	spp := flag.Int("spp", 1, "samples per pixel")
+	adaptive := flag.Bool("adaptive", false, "sample each pixel until its noise drops below -threshold")
+	minSpp := flag.Int("min-spp", 8, "adaptive: samples every pixel takes before testing convergence")
+	maxSpp := flag.Int("max-spp", 256, "adaptive: most samples a pixel can take")
+	threshold := flag.Float64("threshold", 0.005, "adaptive: standard error of pixel luminance counted as converged")
+	heatmapPath := flag.String("heatmap", "", "write a png of samples taken per pixel")
+	workers := flag.Int("workers", runtime.NumCPU(), "render goroutines")
+	seed := flag.Uint64("seed", 1, "random seed for pixel sampling")
+	shadeName := flag.String("shade", "normals", "shading: normals or diffuse")
+	maxDepth := flag.Int("depth", 10, "diffuse: most bounces per path")
+	integName := flag.String("integrator", "path", "diffuse: path, or photon to take caustics from a photon map")
+	photons := flag.Int("photons", 500000, "photon integrator: photons shot from the lights")
+	gather := flag.Int("gather", 64, "photon integrator: nearest photons per caustic estimate")
+	gatherRadius := flag.Float64("gather-radius", 0.05, "photon integrator: farthest a caustic estimate looks")
+	spectral := flag.Bool("spectral", false, "diffuse: trace a wavelength per path, for dispersion in glass")
+	statsPath := flag.String("stats", "", "also write the render statistics as json to this file")
+	cpuProfile := flag.String("cpuprofile", "", "write a pprof cpu profile to this file")
+	memProfile := flag.String("memprofile", "", "write a pprof heap profile to this file when done")
+	denoise := flag.Bool("denoise", false, "run the a-trous denoiser over the finished render")
+	pngPath := flag.String("png", "", "also save the still render as a png")
+	pfmPath := flag.String("pfm", "", "also save the still render's color, normal and albedo as pfm files (for `denoise`)")
+	var window platform.Options
+	window.Register(flag.CommandLine)
+	var shots capture.Options
+	shots.Register(flag.CommandLine)
+	flag.Parse()
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return fmt.Errorf("could not create cpu profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("could not start cpu profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		defer func() {
+			if werr := writeHeapProfile(*memProfile); werr != nil && err == nil {
+				err = werr
+			}
+		}()
+	}
 
-	a := dx*dx + dy*dy + dz*dz
-	b := 2.0 * (ocx*dx + ocy*dy + ocz*dz)
-	c := ocx*ocx + ocy*ocy + ocz*ocz - radius*radius
+	shade, err := parseShadeMode(*shadeName)
+	if err != nil {
+		return err
+	}
+	if *maxDepth < 1 {
+		return errors.New("depth must be at least 1")
+	}
+	if *spectral && shade != shadeDiffuse {
+		return errors.New("spectral needs -shade diffuse")
+	}
+	method, err := parseIntegration(*integName)
+	if err != nil {
+		return err
+	}
+	if method == integratePhoton && (shade != shadeDiffuse || *photons < 1 || *gather < 1 || *gatherRadius <= 0) {
+		return errors.New("photon integrator needs -shade diffuse, and photons, gather and gather-radius above 0")
+	}
+	integ := &integrator{
+		shade:        shade,
+		maxDepth:     *maxDepth,
+		spectral:     *spectral,
+		method:       method,
+		photons:      *photons,
+		gather:       *gather,
+		gatherRadius: *gatherRadius,
+	}
 
-	discriminant := b*b - 4*a*c
+	var dn *denoiser
+	if *denoise {
+		dn = newDenoiser()
+	}
 
-	if discriminant < 0 {
-		return -1.0
-	} else {
-		return (-b - math.Sqrt(discriminant)) / (2.0 * a)
+	smp := &sampler{
+		spp:       *spp,
+		workers:   *workers,
+		seed:      *seed,
+		adaptive:  *adaptive,
+		minSpp:    *minSpp,
+		maxSpp:    *maxSpp,
+		batch:     8,
+		threshold: *threshold,
+	}
+	if err := smp.validate(); err != nil {
+		return err
 	}
-}
 
-func main() {
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		log.Fatalf("could not initialize sdl: %v", err)
-	}
-	defer sdl.Quit()
-
-	win, err := sdl.CreateWindow(
-		"Gradient",
-		sdl.WINDOWPOS_UNDEFINED,
-		sdl.WINDOWPOS_UNDEFINED,
-		int32(winWidth),
-		int32(winHeight),
-		sdl.WINDOW_SHOWN,
-	)
+	s, err := sceneByName(*sceneName, sceneOptions{normalMap: *normalMapPath})
 	if err != nil {
-		log.Fatalf("could not create window: %v", err)
+		return err
+	}
+	sky := daylight{
+		sky:       *skyName,
+		turbidity: *turbidity,
+		elevation: *sunElevation,
+		azimuth:   *sunAzimuth,
+		clock:     *clock,
+		latitude:  *latitude,
+		day:       *day,
+	}
+	if err := sky.apply(s); err != nil {
+		return err
+	}
+	if *marchSteps < 1 || *marchEps <= 0 {
+		return errors.New("march-steps must be positive and march-eps above 0")
+	}
+	for _, obj := range s.objects {
+		if m, ok := obj.(*marcher); ok {
+			m.maxSteps = *marchSteps
+			m.epsilon = *marchEps
+		}
 	}
-	defer win.Destroy()
 
-	renderer, err := sdl.CreateRenderer(win, -1, sdl.RENDERER_ACCELERATED)
+	cam := newCamera(winWidth, winHeight)
+	proj, err := parseProjection(*projName)
 	if err != nil {
-		log.Fatalf("could not create renderer: %v", err)
+		return err
 	}
-	defer renderer.Destroy()
-	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGB24, sdl.TEXTUREACCESS_STREAMING, int32(winWidth), int32(winHeight))
-	if err != nil {
-		log.Fatalf("could not create texture: %v", err)
-	}
-	defer tex.Destroy()
-
-	pixels := make([]byte, winWidth*winHeight*3)
-
-	focalLength := 1.0
-	viewportHeight := 2.0
-	viewportWidth := viewportHeight * (float64(winWidth) / float64(winHeight))
-
-	pixelDeltaUx := viewportWidth / float64(winWidth)
-	pixelDeltaUy := 0.0
-	pixelDeltaUz := 0.0
-
-	pixelDeltaVx := 0.0
-	pixelDeltaVy := -viewportHeight / float64(winHeight)
-	pixelDeltaVz := 0.0
-
-	viewportUpperLeftX := -viewportWidth / 2.0
-	viewportUpperLeftY := viewportHeight / 2.0
-	viewportUpperLeftZ := -focalLength
-
-	pixel00X := viewportUpperLeftX + 0.5*(pixelDeltaUx+pixelDeltaVx)
-	pixel00Y := viewportUpperLeftY + 0.5*(pixelDeltaUy+pixelDeltaVy)
-	pixel00Z := viewportUpperLeftZ + 0.5*(pixelDeltaUz+pixelDeltaVz)
-
-	for j := range winHeight {
-		for i := range winWidth {
-			// incremental pixel position
-			px := pixel00X + float64(i)*pixelDeltaUx + float64(j)*pixelDeltaVx
-			py := pixel00Y + float64(i)*pixelDeltaUy + float64(j)*pixelDeltaVy
-			pz := pixel00Z + float64(i)*pixelDeltaUz + float64(j)*pixelDeltaVz
-
-			// ray direction = pixel_center - camera_center (camera at origin => direction = pixel center)
-			rx := px
-			ry := py
-			rz := pz
-
-			// unit vector of direction
-			len := math.Sqrt(rx*rx + ry*ry + rz*rz) // magnitude of ray direction vector
-
-			// normalization so we get values between -1 and 1, so it becomes a unit vector, i.e. root(ux2 + uy2 + uz2) = 1
-			// with normalization, we don't care about distance, only care about the direction
-
-			ux := rx / len
-			uy := ry / len
-			uz := rz / len
-
-			// sky gradient: a = 0.5*(unit_direction.y + 1.0), only using y because top to bottom
-			a := 0.5 * (uy + 1.0)
-
-			// linear rgb (doing linear interpolation)
-			rr := (1.0-a)*1.0 + a*0.5 // white -> light blue.r
-			gg := (1.0-a)*1.0 + a*0.7 // white -> light blue.g
-			bb := (1.0-a)*1.0 + a*1.0 // white -> light blue.b
-
-			sphereCenterX := 0.0
-			sphereCenterY := 0.0
-			sphereCenterZ := -1.0
-
-			// ray's origin is camera, so 0.0, 0.0, 0.0
-			ox := 0.0
-			oy := 0.0
-			oz := 0.0
-
-			t := hitSphere(sphereCenterX, sphereCenterY, sphereCenterZ, 0.5, ox, oy, oz, ux, uy, uz)
-
-			if t > 0.0 {
-				// Compute hit point: P = O + t*D, parametric equation of ray
-				px := ox + t*ux
-				py := oy + t*uy
-				pz := oz + t*uz
-
-				// Normal vector: N = unit_vector(P - center)
-				nx := px - sphereCenterX
-				ny := py - sphereCenterY
-				nz := pz - sphereCenterZ
-				nlen := math.Sqrt(nx*nx + ny*ny + nz*nz)
-				nx /= nlen
-				ny /= nlen
-				nz /= nlen
-
-				// Map from [-1,1] to [0,1]
-				r := 0.5 * (nx + 1.0)
-				g := 0.5 * (ny + 1.0)
-				b := 0.5 * (nz + 1.0)
-
-				setPixel(i, j, color{
-					byte(255 * r),
-					byte(255 * g),
-					byte(255 * b),
-				}, pixels)
-				continue
-			}
-
-			c := color{byte(int(255 * rr)), byte(int(255 * gg)), byte(int(255 * bb))} // rr, gg, bb is between 0 and 1
+	if *fisheyeFOV <= 0 || *fisheyeFOV > 360 {
+		return errors.New("fisheye-fov must be in (0, 360]")
+	}
+	cam.projection = proj
+	cam.fisheyeFOV = *fisheyeFOV
 
-			setPixel(i, j, c, pixels)
+	if *frames != "" {
+		start, end, err := parseFrameRange(*frames)
+		if err != nil {
+			return err
+		}
+		interp, err := parseInterpolation(*interpName)
+		if err != nil {
+			return err
+		}
+		if *length <= 0 || *fps <= 0 || *fps > 100 {
+			return errors.New("length must be positive and fps between 1 and 100")
 		}
+		anim := turntable(*length, interp)
+		if *keysPath != "" {
+			if anim, err = loadAnimation(*keysPath, interp); err != nil {
+				return fmt.Errorf("could not load keyframes: %w", err)
+			}
+			if err := anim.validate(len(s.objects)); err != nil {
+				return err
+			}
+		}
+		if err := renderAnimation(anim, s, cam, smp, integ, dn, start, end, *outDir, *gifPath, *statsPath, *fps); err != nil {
+			return fmt.Errorf("could not render animation: %w", err)
+		}
+		return nil
 	}
 
-	if err := tex.Update(nil, unsafe.Pointer(&pixels[0]), winWidth*3); err != nil {
-		log.Fatalf("texture update failed: %v", err)
+	win, err := window.Open("Gradient", winWidth, winHeight)
+	if err != nil {
+		return fmt.Errorf("could not open window: %w", err)
 	}
-	if err := renderer.Copy(tex, nil, nil); err != nil {
-		log.Fatalf("renderer copy failed: %v", err)
+	p := capture.Wrap(win, shots, "gradient")
+	defer func() {
+		if err := p.Close(); err != nil {
+			log.Printf("could not close window: %v", err)
+		}
+	}()
+	fb := framebuffer.New(winWidth, winHeight, framebuffer.RGB24)
+
+	f := render(s, cam, smp, integ)
+	// save the raw render before denoising so `denoise` can be rerun on it
+	if *pfmPath != "" {
+		if err := writeFilmPFM(*pfmPath, f); err != nil {
+			return fmt.Errorf("could not write pfm: %w", err)
+		}
+	}
+	if dn != nil {
+		f.color = dn.denoise(f.width, f.height, f.color, f.normal, f.albedo)
+	}
+	f.toPixels(fb)
+	if *pngPath != "" {
+		if err := capture.WritePNG(*pngPath, f.image()); err != nil {
+			return fmt.Errorf("could not write png: %w", err)
+		}
 	}
-	renderer.Present()
 
-	running := true
-	for running {
-		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
-			switch e := ev.(type) {
-			case *sdl.QuitEvent:
-				running = false
-			case *sdl.KeyboardEvent:
-				if e.Type == sdl.KEYDOWN && e.Keysym.Sym == sdl.K_ESCAPE {
-					running = false
-				}
-			}
+	rep := f.report()
+	rep.print(os.Stdout)
+	if *statsPath != "" {
+		if err := rep.writeJSON(*statsPath); err != nil {
+			return fmt.Errorf("could not write stats: %w", err)
 		}
-		sdl.Delay(16)
 	}
+	if *heatmapPath != "" {
+		if err := capture.WritePNG(*heatmapPath, f.heatmap()); err != nil {
+			return fmt.Errorf("could not write heatmap: %w", err)
+		}
+	}
+
+	if err := platform.Show(p, fb); err != nil {
+		return fmt.Errorf("could not show render: %w", err)
+	}
+	return nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create heap profile: %w", err)
+	}
+	defer f.Close()
+	runtime.GC() // up to date allocation statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("could not write heap profile: %w", err)
+	}
+	return nil
 }
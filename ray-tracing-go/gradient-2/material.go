@@ -0,0 +1,206 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// material decides what happens to a path where it meets a surface. lambda
+// is the path's wavelength in spectral mode and 0 for rgb, colors come back
+// with the same value in every channel when it's set
+type material interface {
+	// scatter picks the next ray and the reflectance it is weighted by, ok
+	// is false when the surface absorbs the path
+	scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (next ray, attenuation vec3, ok bool)
+	emitted(rec *hitRecord, lambda float64) vec3
+	// albedo is the surface color for the denoiser's guide buffer
+	albedo() vec3
+}
+
+// defaultMaterial is the grey every surface without a material gets, the
+// original diffuse shading
+var defaultMaterial material = &lambertian{newSpectralColor(vec3{0.5, 0.5, 0.5})}
+
+type lambertian struct {
+	color spectralColor
+}
+
+func (m *lambertian) scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (ray, vec3, bool) {
+	// scatter toward normal + random unit vector, a cosine distribution
+	dir := rec.normal.add(randomUnitVector(rng))
+	if dir.lengthSquared() < 1e-16 {
+		dir = rec.normal
+	}
+	return ray{rec.p, dir.unit()}, m.color.eval(lambda), true
+}
+
+func (m *lambertian) emitted(*hitRecord, float64) vec3 { return vec3{} }
+func (m *lambertian) albedo() vec3                     { return m.color.rgb }
+
+// metal reflects like a mirror, fuzz jitters the reflection in a sphere of
+// that radius for brushed looks
+type metal struct {
+	color spectralColor
+	fuzz  float64
+}
+
+func reflect(v, n vec3) vec3 {
+	return v.sub(n.mul(2 * v.dot(n)))
+}
+
+func (m *metal) scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (ray, vec3, bool) {
+	dir := reflect(r.dir.unit(), rec.normal)
+	if m.fuzz > 0 {
+		dir = dir.unit().add(randomUnitVector(rng).mul(m.fuzz))
+	}
+	if dir.dot(rec.normal) <= 0 {
+		return ray{}, vec3{}, false // fuzzed below the surface
+	}
+	return ray{rec.p, dir.unit()}, m.color.eval(lambda), true
+}
+
+func (m *metal) emitted(*hitRecord, float64) vec3 { return vec3{} }
+func (m *metal) albedo() vec3                     { return m.color.rgb }
+
+// dielectric is clear glass. the index depends on wavelength, so spectral
+// paths split by color where rgb ones all bend at the d line
+type dielectric struct {
+	index ior
+}
+
+func (m *dielectric) scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (ray, vec3, bool) {
+	l := lambda
+	if l == 0 {
+		l = lambdaD
+	}
+	eta := m.index(l)
+	if rec.frontFace {
+		eta = 1 / eta
+	}
+
+	unit := r.dir.unit()
+	cosTheta := min(unit.neg().dot(rec.normal), 1)
+	sinTheta := math.Sqrt(1 - cosTheta*cosTheta)
+
+	var dir vec3
+	if eta*sinTheta > 1 || schlick(cosTheta, eta) > rng.Float64() {
+		dir = reflect(unit, rec.normal)
+	} else {
+		dir = refract(unit, rec.normal, cosTheta, eta)
+	}
+	return ray{rec.p, dir}, vec3{1, 1, 1}, true
+}
+
+// refract bends unit vector v through a surface with normal n, eta is the
+// ratio of indices and cosTheta the cosine between -v and n
+func refract(v, n vec3, cosTheta, eta float64) vec3 {
+	perp := v.add(n.mul(cosTheta)).mul(eta)
+	parallel := n.mul(-math.Sqrt(math.Abs(1 - perp.lengthSquared())))
+	return perp.add(parallel)
+}
+
+// schlick approximates the fresnel reflectance
+func schlick(cosine, eta float64) float64 {
+	r0 := (1 - eta) / (1 + eta)
+	r0 *= r0
+	return r0 + (1-r0)*math.Pow(1-cosine, 5)
+}
+
+func (m *dielectric) emitted(*hitRecord, float64) vec3 { return vec3{} }
+func (m *dielectric) albedo() vec3                     { return vec3{1, 1, 1} }
+
+// light emits on its front face and reflects nothing
+type light struct {
+	color spectralColor
+}
+
+func (m *light) scatter(ray, *hitRecord, float64, *rand.Rand) (ray, vec3, bool) {
+	return ray{}, vec3{}, false
+}
+
+func (m *light) emitted(rec *hitRecord, lambda float64) vec3 {
+	if !rec.frontFace {
+		return vec3{}
+	}
+	return m.color.eval(lambda)
+}
+
+func (m *light) albedo() vec3 { return vec3{1, 1, 1} }
+
+// shaded gives an object a material
+type shaded struct {
+	obj hittable
+	mat material
+}
+
+func (s *shaded) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	if !s.obj.hit(r, tMin, tMax, rec) {
+		return false
+	}
+	rec.mat = s.mat
+	return true
+}
+
+func (s *shaded) boundingBox() aabb {
+	return s.obj.boundingBox()
+}
+
+// quad is a parallelogram from a along edges ab and ad, as two triangles
+// facing ab x ad
+func quad(a, ab, ad vec3) hittableList {
+	b, c, d := a.add(ab), a.add(ab).add(ad), a.add(ad)
+	return hittableList{
+		&triangle{p0: a, p1: b, p2: c, uv0: [2]float64{0, 0}, uv1: [2]float64{1, 0}, uv2: [2]float64{1, 1}},
+		&triangle{p0: a, p1: c, p2: d, uv0: [2]float64{0, 0}, uv1: [2]float64{1, 1}, uv2: [2]float64{0, 1}},
+	}
+}
+
+// prism is an upright triangular prism with its apex toward +z, wound so
+// every face points out. glass needs that to tell entering from leaving
+func prism(center vec3, side, height float64) hittableList {
+	r := side / math.Sqrt(3) // corner distance from the axis
+	var corners [3]vec3
+	for k := range corners {
+		a := math.Pi/2 + 2*math.Pi*float64(k)/3
+		corners[k] = center.add(vec3{r * math.Cos(a), -height / 2, r * math.Sin(a)})
+	}
+	up := vec3{0, height, 0}
+
+	var faces hittableList
+	for k := range corners {
+		p, q := corners[k], corners[(k+1)%3]
+		// walls: corners go counterclockwise seen from above, so up x edge
+		// points away from the axis
+		faces = append(faces, quad(p, up, q.sub(p))...)
+	}
+	faces = append(faces,
+		newTriangle(corners[0], corners[1], corners[2]),                         // bottom, facing down
+		newTriangle(corners[0].add(up), corners[2].add(up), corners[1].add(up)), // top, facing up
+	)
+	return faces
+}
+
+// prismScene looks through a flint glass prism at white stripes on a dark
+// wall. the stripes come through fringed with color in spectral mode
+func prismScene() *scene {
+	wall := &lambertian{newSpectralColor(vec3{0.05, 0.05, 0.05})}
+	stripe := &light{newSpectralColor(vec3{4, 4, 4})}
+	glass := &dielectric{sf11}
+
+	objs := hittableList{
+		&shaded{&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}}, &lambertian{newSpectralColor(vec3{0.4, 0.35, 0.3})}},
+		&shaded{&plane{point: vec3{0, 0, -3}, normal: vec3{0, 0, 1}}, wall},
+		&shaded{&sphere{center: vec3{1.1, -0.2, -1.4}, radius: 0.3}, &dielectric{cauchyIOR(1.5, 0.05)}},
+		&shaded{&sphere{center: vec3{-1.1, -0.2, -1.4}, radius: 0.3}, &metal{newSpectralColor(vec3{0.9, 0.6, 0.3}), 0.1}},
+	}
+	for _, tri := range prism(vec3{0, 0, -1.3}, 0.8, 0.9) {
+		objs = append(objs, &shaded{tri, glass})
+	}
+	for k := -12; k <= 12; k++ {
+		x := float64(k) * 0.4
+		for _, tri := range quad(vec3{x, -0.5, -2.99}, vec3{0.1, 0, 0}, vec3{0, 3, 0}) {
+			objs = append(objs, &shaded{tri, stripe})
+		}
+	}
+	return &scene{objects: objs}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// ggx is the trowbridge-reitz microfacet distribution with smith masking.
+// ax and ay are the roughness along the surface tangent and bitangent, equal
+// for an isotropic surface. everything works in the local frame where the
+// normal is +z
+type ggx struct {
+	ax, ay float64
+}
+
+// newGGX maps a perceptual roughness in 0..1 to alpha = roughness², and
+// stretches it along the tangent by anisotropy in 0..1 for brushed looks
+func newGGX(roughness, anisotropy float64) ggx {
+	alpha := max(roughness*roughness, 1e-3)
+	aspect := math.Sqrt(1 - 0.9*anisotropy)
+	return ggx{max(alpha/aspect, 1e-3), max(alpha*aspect, 1e-3)}
+}
+
+// d is the density of microfacet normals h
+func (g ggx) d(h vec3) float64 {
+	if h.z <= 0 {
+		return 0
+	}
+	e := h.x*h.x/(g.ax*g.ax) + h.y*h.y/(g.ay*g.ay) + h.z*h.z
+	return 1 / (math.Pi * g.ax * g.ay * e * e)
+}
+
+// lambda is smith's auxiliary function, how much of the surface seen from v
+// hides behind other microfacets
+func (g ggx) lambda(v vec3) float64 {
+	if v.z <= 0 {
+		return math.Inf(1)
+	}
+	a2 := (g.ax*g.ax*v.x*v.x + g.ay*g.ay*v.y*v.y) / (v.z * v.z)
+	return (math.Sqrt(1+a2) - 1) / 2
+}
+
+// g1 is the fraction of microfacets visible from v
+func (g ggx) g1(v vec3) float64 {
+	return 1 / (1 + g.lambda(v))
+}
+
+// g2 is the height correlated fraction visible from both v and l
+func (g ggx) g2(v, l vec3) float64 {
+	return 1 / (1 + g.lambda(v) + g.lambda(l))
+}
+
+// sampleVisible picks a microfacet normal in proportion to how much of it v
+// sees (heitz 2018), so the weight of the reflection is only g2/g1 times the
+// fresnel term and stays at or below 1
+func (g ggx) sampleVisible(v vec3, rng *rand.Rand) vec3 {
+	// stretch to the hemisphere configuration
+	vh := vec3{g.ax * v.x, g.ay * v.y, v.z}.unit()
+	t1 := vec3{1, 0, 0}
+	if l := vh.x*vh.x + vh.y*vh.y; l > 0 {
+		t1 = vec3{-vh.y, vh.x, 0}.div(math.Sqrt(l))
+	}
+	t2 := vh.cross(t1)
+
+	// a point on the disc, squeezed onto the part of it v can see
+	r := math.Sqrt(rng.Float64())
+	phi := 2 * math.Pi * rng.Float64()
+	p1, p2 := r*math.Cos(phi), r*math.Sin(phi)
+	s := 0.5 * (1 + vh.z)
+	p2 = (1-s)*math.Sqrt(1-p1*p1) + s*p2
+
+	nh := t1.mul(p1).add(t2.mul(p2)).add(vh.mul(math.Sqrt(max(0, 1-p1*p1-p2*p2))))
+	// unstretch
+	return vec3{g.ax * nh.x, g.ay * nh.y, max(1e-6, nh.z)}.unit()
+}
+
+// shadingFrame is the tangent, bitangent and normal around the side of the
+// surface the ray came from, the tangent following u where the object has one
+func shadingFrame(rec *hitRecord) (t, b, n vec3) {
+	n = rec.normal
+	t = rec.tangent.sub(n.mul(rec.tangent.dot(n)))
+	if t.lengthSquared() < 1e-12 {
+		t, _ = tangentBasis(n)
+	} else {
+		t = t.unit()
+	}
+	return t, n.cross(t), n
+}
+
+func toLocal(v, t, b, n vec3) vec3 {
+	return vec3{v.dot(t), v.dot(b), v.dot(n)}
+}
+
+func fromLocal(v, t, b, n vec3) vec3 {
+	return t.mul(v.x).add(b.mul(v.y)).add(n.mul(v.z))
+}
+
+// fresnelDielectric is the exact unpolarized reflectance going from index 1
+// into eta at cosine cosI
+func fresnelDielectric(cosI, eta float64) float64 {
+	sin2T := (1 - cosI*cosI) / (eta * eta)
+	if sin2T >= 1 {
+		return 1
+	}
+	cosT := math.Sqrt(1 - sin2T)
+	rs := (cosI - eta*cosT) / (cosI + eta*cosT)
+	rp := (eta*cosI - cosT) / (eta*cosI + cosT)
+	return (rs*rs + rp*rp) / 2
+}
+
+// fresnelSchlick tints the reflectance from f0 at normal incidence toward
+// white at grazing, the usual approximation for conductors
+func fresnelSchlick(cosI float64, f0 vec3) vec3 {
+	w := math.Pow(1-cosI, 5)
+	return f0.add(vec3{1, 1, 1}.sub(f0).mul(w))
+}
+
+// roughMetal is a conductor with ggx microfacets: polished at roughness 0,
+// satin toward 1, brushed along the tangent with anisotropy
+type roughMetal struct {
+	color spectralColor // reflectance at normal incidence
+	dist  ggx
+}
+
+func newRoughMetal(color vec3, roughness, anisotropy float64) *roughMetal {
+	return &roughMetal{newSpectralColor(color), newGGX(roughness, anisotropy)}
+}
+
+func (m *roughMetal) scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (ray, vec3, bool) {
+	t, b, n := shadingFrame(rec)
+	v := toLocal(r.dir.unit().neg(), t, b, n)
+	if v.z <= 0 {
+		return ray{}, vec3{}, false
+	}
+	h := m.dist.sampleVisible(v, rng)
+	l := reflect(v.neg(), h)
+	if l.z <= 0 {
+		return ray{}, vec3{}, false // bounced into the neighbouring microfacets
+	}
+	f := fresnelSchlick(v.dot(h), m.color.eval(lambda))
+	return ray{rec.p, fromLocal(l, t, b, n)}, f.mul(m.dist.g2(v, l) / m.dist.g1(v)), true
+}
+
+func (m *roughMetal) emitted(*hitRecord, float64) vec3 { return vec3{} }
+func (m *roughMetal) albedo() vec3                     { return m.color.rgb }
+
+// coated is a diffuse base under a clear varnish, plastic or lacquered
+// wood. light either reflects off the coat's microfacets by fresnel, or
+// enters, bounces around the base and leaves again. the bouncing inside is
+// summed in closed form rather than traced
+type coated struct {
+	base spectralColor
+	dist ggx
+	ior  float64
+	// fdrInside is the share of diffuse light inside the coat that the
+	// surface reflects back down
+	fdrInside float64
+}
+
+func newCoated(base vec3, roughness, ior float64) *coated {
+	return &coated{
+		base:      newSpectralColor(base),
+		dist:      newGGX(roughness, 0),
+		ior:       ior,
+		fdrInside: 1 - (1-diffuseFresnel(ior))/(ior*ior),
+	}
+}
+
+// diffuseFresnel is the reflectance from outside averaged over cosine
+// distributed directions
+func diffuseFresnel(eta float64) float64 {
+	const steps = 256
+	var sum float64
+	for k := range steps {
+		c := (float64(k) + 0.5) / steps
+		sum += fresnelDielectric(c, eta) * 2 * c
+	}
+	return sum / steps
+}
+
+func (m *coated) scatter(r ray, rec *hitRecord, lambda float64, rng *rand.Rand) (ray, vec3, bool) {
+	t, b, n := shadingFrame(rec)
+	v := toLocal(r.dir.unit().neg(), t, b, n)
+	if v.z <= 0 {
+		return ray{}, vec3{}, false
+	}
+
+	h := m.dist.sampleVisible(v, rng)
+	if rng.Float64() < fresnelDielectric(v.dot(h), m.ior) {
+		// off the coat, which is clear so only masking weights it
+		l := reflect(v.neg(), h)
+		if l.z <= 0 {
+			return ray{}, vec3{}, false
+		}
+		return ray{rec.p, fromLocal(l, t, b, n)}, vec3{1, 1, 1}.mul(m.dist.g2(v, l) / m.dist.g1(v)), true
+	}
+
+	// through the coat. light leaves a lambertian base with a cosine
+	// distribution, goes out through the coat with 1 - fresnel and is
+	// reflected back down otherwise, a geometric series with ratio
+	// base * fdrInside. the ior² undoes the radiance squeezing in and out
+	dir := n.add(randomUnitVector(rng))
+	if dir.lengthSquared() < 1e-16 {
+		dir = n
+	}
+	dir = dir.unit()
+	base := m.base.eval(lambda)
+	out := (1 - fresnelDielectric(dir.dot(n), m.ior)) / (m.ior * m.ior)
+	w := vec3{
+		base.x * out / (1 - base.x*m.fdrInside),
+		base.y * out / (1 - base.y*m.fdrInside),
+		base.z * out / (1 - base.z*m.fdrInside),
+	}
+	return ray{rec.p, dir}, w, true
+}
+
+func (m *coated) emitted(*hitRecord, float64) vec3 { return vec3{} }
+func (m *coated) albedo() vec3                     { return m.base.rgb }
+
+// materialsScene lines up plastic of rising roughness in front and metals
+// behind: polished, satin, and two brushed ones to show the anisotropy
+// streaking along the spheres' tangents
+func materialsScene() *scene {
+	objs := hittableList{
+		&shaded{&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}}, &lambertian{newSpectralColor(vec3{0.6, 0.6, 0.6})}},
+	}
+	red := vec3{0.7, 0.08, 0.05}
+	for k, rough := range []float64{0, 0.15, 0.35, 0.7} {
+		x := -0.9 + 0.6*float64(k)
+		objs = append(objs, &shaded{&sphere{center: vec3{x, -0.3, -1.2}, radius: 0.2}, newCoated(red, rough, 1.5)})
+	}
+	gold := vec3{1.0, 0.77, 0.34}
+	steel := vec3{0.56, 0.57, 0.58}
+	metals := []material{
+		newRoughMetal(gold, 0.05, 0),
+		newRoughMetal(gold, 0.4, 0),
+		newRoughMetal(steel, 0.35, 0.9),
+		newRoughMetal(steel, 0.6, 0.9),
+	}
+	for k, mat := range metals {
+		x := -1.2 + 0.8*float64(k)
+		objs = append(objs, &shaded{&sphere{center: vec3{x, -0.15, -2.1}, radius: 0.35}, mat})
+	}
+	return &scene{objects: objs}
+}
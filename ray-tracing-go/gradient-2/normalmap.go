@@ -0,0 +1,205 @@
+package main
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+)
+
+// surfaceDetail bends the shading normal of a hit without changing geometry
+type surfaceDetail interface {
+	perturb(rec *hitRecord)
+}
+
+// detailed wraps an object with a normal or bump map
+type detailed struct {
+	obj    hittable
+	detail surfaceDetail
+}
+
+func (d *detailed) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	// hit into a fresh record, objects without tangents must not inherit one
+	// from whatever the list hit before
+	var local hitRecord
+	if !d.obj.hit(r, tMin, tMax, &local) {
+		return false
+	}
+	d.detail.perturb(&local)
+	*rec = local
+	return true
+}
+
+func (d *detailed) boundingBox() aabb {
+	return d.obj.boundingBox()
+}
+
+// tangentFrame builds an orthonormal tangent, bitangent, normal frame around
+// the outward normal, with the tangent following u and the bitangent v
+func tangentFrame(rec *hitRecord) (t, b, n vec3) {
+	n = rec.normal
+	if !rec.frontFace {
+		n = n.neg()
+	}
+	t = rec.tangent
+	// gram-schmidt: drop whatever part of the tangent leans into the normal
+	t = t.sub(n.mul(t.dot(n)))
+	if t.lengthSquared() < 1e-12 {
+		t, _ = tangentBasis(n)
+	} else {
+		t = t.unit()
+	}
+	return t, n.cross(t), n
+}
+
+// setShadingNormal stores an outward normal, facing it against the ray again
+func setShadingNormal(rec *hitRecord, outward vec3) {
+	if rec.frontFace {
+		rec.normal = outward
+	} else {
+		rec.normal = outward.neg()
+	}
+}
+
+// normalMap is a tangent space normal map: rgb encodes a normal in the
+// surface's own frame, with (0.5, 0.5, 1) meaning "unchanged"
+type normalMap struct {
+	img      image.Image
+	strength float64 // 0 ignores the map, 1 uses it as is
+	tiles    float64 // repeats across the 0..1 uv range
+}
+
+func loadNormalMap(path string) (*normalMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return &normalMap{img: img, strength: 1, tiles: 1}, nil
+}
+
+// texel returns pixel (x, y) wrapped around the image edges, as a [-1, 1] vector
+func (nm *normalMap) texel(x, y int) vec3 {
+	b := nm.img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	x = ((x % w) + w) % w
+	y = ((y % h) + h) % h
+	r, g, bl, _ := nm.img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+	return vec3{float64(r), float64(g), float64(bl)}.div(0xffff).mul(2).sub(vec3{1, 1, 1})
+}
+
+// sample bilinearly filters the map at (u, v), v = 0 being the image bottom
+func (nm *normalMap) sample(u, v float64) vec3 {
+	b := nm.img.Bounds()
+	x := u*nm.tiles*float64(b.Dx()) - 0.5
+	y := (1-v*nm.tiles)*float64(b.Dy()) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+
+	top := nm.texel(ix, iy).mul(1 - fx).add(nm.texel(ix+1, iy).mul(fx))
+	bottom := nm.texel(ix, iy+1).mul(1 - fx).add(nm.texel(ix+1, iy+1).mul(fx))
+	return top.mul(1 - fy).add(bottom.mul(fy))
+}
+
+func (nm *normalMap) perturb(rec *hitRecord) {
+	t, b, n := tangentFrame(rec)
+	m := nm.sample(rec.u, rec.v)
+
+	// flatten the map toward (0, 0, 1) by strength
+	m = vec3{m.x * nm.strength, m.y * nm.strength, m.z}
+	outward := t.mul(m.x).add(b.mul(m.y)).add(n.mul(m.z)).unit()
+	setShadingNormal(rec, outward)
+}
+
+// normalMapFromHeight bakes a height function over uv into a normal map
+// image, so generated patterns can go through the same path as loaded ones
+func normalMapFromHeight(height func(u, v float64) float64, w, h int, depth float64) *normalMap {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	du, dv := 1/float64(w), 1/float64(h)
+	for y := range h {
+		for x := range w {
+			u := (float64(x) + 0.5) * du
+			v := 1 - (float64(y)+0.5)*dv
+
+			// slope of the height field along u and v, in texels
+			sx := (height(u+du, v) - height(u-du, v)) / 2 * depth
+			sy := (height(u, v+dv) - height(u, v-dv)) / 2 * depth
+			n := vec3{-sx, -sy, 1}.unit()
+
+			k := img.PixOffset(x, y)
+			img.Pix[k+0] = byte(255 * (n.x*0.5 + 0.5))
+			img.Pix[k+1] = byte(255 * (n.y*0.5 + 0.5))
+			img.Pix[k+2] = byte(255 * (n.z*0.5 + 0.5))
+			img.Pix[k+3] = 255
+		}
+	}
+	return &normalMap{img: img, strength: 1, tiles: 1}
+}
+
+// tileHeight is a grid of raised tiles with sunken grout lines between them
+func tileHeight(tilesU, tilesV int) func(u, v float64) float64 {
+	return func(u, v float64) float64 {
+		fu := u*float64(tilesU) - math.Floor(u*float64(tilesU))
+		fv := v*float64(tilesV) - math.Floor(v*float64(tilesV))
+		edge := math.Min(math.Min(fu, 1-fu), math.Min(fv, 1-fv))
+		return math.Min(edge/0.08, 1) // ramps down over the outer 8% of a tile
+	}
+}
+
+// bumpMap perturbs the normal by the slope of a perlin noise height field
+// sampled in world space, so it needs no uvs at all
+type bumpMap struct {
+	noise    *perlin
+	scale    float64 // noise frequency, features per world unit
+	strength float64
+}
+
+func (bm *bumpMap) height(p vec3) float64 {
+	return bm.noise.turbulence(p.mul(bm.scale), 4)
+}
+
+func (bm *bumpMap) perturb(rec *hitRecord) {
+	t, b, n := tangentFrame(rec)
+
+	// finite differences of height along the tangent plane
+	const h = 1e-3
+	h0 := bm.height(rec.p)
+	dhdt := (bm.height(rec.p.add(t.mul(h))) - h0) / h
+	dhdb := (bm.height(rec.p.add(b.mul(h))) - h0) / h
+
+	outward := n.sub(t.mul(dhdt * bm.strength)).sub(b.mul(dhdb * bm.strength)).unit()
+	setShadingNormal(rec, outward)
+}
+
+// bumpScene: a perlin bumped floor, a tiled sphere and a tiled quad made of
+// two triangles. nm replaces the generated tiles when set
+func bumpScene(nm *normalMap) *scene {
+	if nm == nil {
+		nm = normalMapFromHeight(tileHeight(8, 4), 512, 256, 40)
+	}
+	quadMap := *nm
+	quadMap.tiles = 2
+
+	q0, q1, q2, q3 := vec3{0.6, -0.5, -1.8}, vec3{1.6, -0.5, -1.4}, vec3{1.6, 0.5, -1.4}, vec3{0.6, 0.5, -1.8}
+	lower := &triangle{p0: q0, p1: q1, p2: q2, uv0: [2]float64{0, 0}, uv1: [2]float64{1, 0}, uv2: [2]float64{1, 1}}
+	upper := &triangle{p0: q0, p1: q2, p2: q3, uv0: [2]float64{0, 0}, uv1: [2]float64{1, 1}, uv2: [2]float64{0, 1}}
+
+	return &scene{
+		objects: hittableList{
+			&detailed{&sphere{center: vec3{0, 0, -1}, radius: 0.5}, nm},
+			&detailed{
+				&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}},
+				&bumpMap{noise: newPerlin(7), scale: 6, strength: 0.05},
+			},
+			&detailed{lower, &quadMap},
+			&detailed{upper, &quadMap},
+		},
+	}
+}
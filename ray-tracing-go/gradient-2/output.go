@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"gfx/capture"
+	"gfx/framebuffer"
+)
+
+// image quantizes the film directly, for films that aren't window sized
+func (f *film) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	for k, c := range f.color {
+		img.Pix[k*4+0] = byte(255 * clamp(c.x, 0, 1))
+		img.Pix[k*4+1] = byte(255 * clamp(c.y, 0, 1))
+		img.Pix[k*4+2] = byte(255 * clamp(c.z, 0, 1))
+		img.Pix[k*4+3] = 255
+	}
+	return img
+}
+
+func framePath(dir string, frame int) string {
+	return filepath.Join(dir, fmt.Sprintf("frame_%04d.png", frame))
+}
+
+// renderAnimation renders frames start..end (inclusive) to numbered pngs in
+// dir, and to a gif as well when gifPath is set. each frame's statistics are
+// printed, and written together as json when statsPath is set
+func renderAnimation(anim *animation, rest *scene, cam *camera, smp *sampler, integ *integrator, dn *denoiser, start, end int, dir, gifPath, statsPath string, fps int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	posed := &scene{}
+	fb := framebuffer.New(winWidth, winHeight, framebuffer.RGB24)
+	var frames []image.Image
+	var reports []frameReport
+
+	for frame := start; frame <= end; frame++ {
+		anim.apply(float64(frame), cam, rest, posed)
+		f := render(posed, cam, smp, integ)
+		if dn != nil {
+			f.color = dn.denoise(f.width, f.height, f.color, f.normal, f.albedo)
+		}
+		f.toPixels(fb)
+
+		rep := f.report()
+		fmt.Printf("frame %d: ", frame)
+		rep.print(os.Stdout)
+		reports = append(reports, frameReport{frame, rep})
+
+		img := fb.Image()
+		path := framePath(dir, frame)
+		if err := capture.WritePNG(path, img); err != nil {
+			return err
+		}
+		fmt.Println("wrote", path)
+
+		if gifPath != "" {
+			frames = append(frames, img)
+		}
+	}
+
+	if gifPath != "" {
+		if err := capture.WriteGIF(gifPath, frames, fps); err != nil {
+			return err
+		}
+		fmt.Println("wrote", gifPath)
+	}
+	if statsPath != "" {
+		if err := writeJSONFile(statsPath, reports); err != nil {
+			return err
+		}
+		fmt.Println("wrote", statsPath)
+	}
+	return nil
+}
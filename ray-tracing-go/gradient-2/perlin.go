@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// perlin is Ken Perlin's improved noise: smooth pseudo random values in
+// about [-1, 1] that vary over a scale of one unit
+type perlin struct {
+	perm [512]int
+}
+
+func newPerlin(seed uint64) *perlin {
+	p := &perlin{}
+	rng := rand.New(rand.NewPCG(seed, seed))
+	base := rng.Perm(256)
+	for k := range 512 {
+		p.perm[k] = base[k%256]
+	}
+	return p
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// grad picks one of 12 edge directions of a cube from the hash and dots it
+// with the offset
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+func (p *perlin) noise(pt vec3) float64 {
+	fx, fy, fz := math.Floor(pt.x), math.Floor(pt.y), math.Floor(pt.z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z := pt.x-fx, pt.y-fy, pt.z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	pm := &p.perm
+	A := pm[X] + Y
+	AA := pm[A] + Z
+	AB := pm[A+1] + Z
+	B := pm[X+1] + Y
+	BA := pm[B] + Z
+	BB := pm[B+1] + Z
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(pm[AA], x, y, z), grad(pm[BA], x-1, y, z)),
+			lerp(u, grad(pm[AB], x, y-1, z), grad(pm[BB], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(pm[AA+1], x, y, z-1), grad(pm[BA+1], x-1, y, z-1)),
+			lerp(u, grad(pm[AB+1], x, y-1, z-1), grad(pm[BB+1], x-1, y-1, z-1))))
+}
+
+// turbulence sums octaves of noise at doubling frequency and halving weight
+func (p *perlin) turbulence(pt vec3, octaves int) float64 {
+	sum := 0.0
+	weight := 1.0
+	for range octaves {
+		sum += weight * math.Abs(p.noise(pt))
+		weight *= 0.5
+		pt = pt.mul(2)
+	}
+	return sum
+}
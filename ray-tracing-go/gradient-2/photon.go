@@ -0,0 +1,364 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// integration is how the diffuse shading finds its light
+type integration int
+
+const (
+	integratePath   integration = iota // plain path tracing from the camera
+	integratePhoton                    // path tracing, with caustics from a photon map
+)
+
+func parseIntegration(s string) (integration, error) {
+	switch s {
+	case "path":
+		return integratePath, nil
+	case "photon":
+		return integratePhoton, nil
+	}
+	return integratePath, fmt.Errorf("unknown integrator %q (want path or photon)", s)
+}
+
+// areaLight is a shape photons can be emitted from
+type areaLight interface {
+	// samplePoint picks a point uniformly over the surface and its outward normal
+	samplePoint(rng *rand.Rand) (p, n vec3)
+	area() float64
+}
+
+func (s *sphere) samplePoint(rng *rand.Rand) (vec3, vec3) {
+	n := randomUnitVector(rng)
+	return s.center.add(n.mul(s.radius)), n
+}
+
+func (s *sphere) area() float64 {
+	return 4 * math.Pi * s.radius * s.radius
+}
+
+func (tr *triangle) samplePoint(rng *rand.Rand) (vec3, vec3) {
+	// fold the unit square onto the triangle
+	b1, b2 := rng.Float64(), rng.Float64()
+	if b1+b2 > 1 {
+		b1, b2 = 1-b1, 1-b2
+	}
+	e1 := tr.p1.sub(tr.p0)
+	e2 := tr.p2.sub(tr.p0)
+	return tr.p0.add(e1.mul(b1)).add(e2.mul(b2)), e1.cross(e2).unit()
+}
+
+func (tr *triangle) area() float64 {
+	return 0.5 * tr.p1.sub(tr.p0).cross(tr.p2.sub(tr.p0)).length()
+}
+
+// emitter is one light in the scene photons start from
+type emitter struct {
+	shape areaLight
+	light *light
+	power float64 // rough share of the scene's light, for picking between them
+}
+
+// findEmitters collects the top level objects that are lights with a shape
+// photons can start on
+func findEmitters(s *scene) []emitter {
+	var out []emitter
+	for _, obj := range s.objects {
+		sh, ok := obj.(*shaded)
+		if !ok {
+			continue
+		}
+		l, ok := sh.mat.(*light)
+		if !ok {
+			continue
+		}
+		shape, ok := sh.obj.(areaLight)
+		if !ok {
+			continue
+		}
+		out = append(out, emitter{shape, l, luminance(l.color.rgb) * shape.area()})
+	}
+	return out
+}
+
+func totalPower(emitters []emitter) float64 {
+	var total float64
+	for _, e := range emitters {
+		total += e.power
+	}
+	return total
+}
+
+// pickEmitter chooses a light with probability power/total
+func pickEmitter(emitters []emitter, total float64, rng *rand.Rand) emitter {
+	pick := rng.Float64() * total
+	for _, e := range emitters {
+		if pick < e.power {
+			return e
+		}
+		pick -= e.power
+	}
+	return emitters[len(emitters)-1]
+}
+
+// directLight samples one point on one light and returns the light it sends
+// off a diffuse hit with reflectance albedo, if nothing is in the way
+func (tr *tracer) directLight(rec *hitRecord, albedo vec3, lambda float64) vec3 {
+	lights := tr.caustics.lights
+	if len(lights) == 0 {
+		return vec3{}
+	}
+	total := totalPower(lights)
+	e := pickEmitter(lights, total, tr.rng)
+	lp, ln := e.shape.samplePoint(tr.rng)
+
+	toLight := lp.sub(rec.p)
+	dist := toLight.length()
+	w := toLight.div(dist)
+	cosSurface := w.dot(rec.normal)
+	cosLight := -w.dot(ln) // lights only shine from their front
+	if cosSurface <= 0 || cosLight <= 0 {
+		return vec3{}
+	}
+
+	tr.stats.secondaryRays++
+	tr.stats.objectVisits += int64(len(tr.scene.objects))
+	var shadow hitRecord
+	if tr.scene.objects.hit(ray{rec.p, w}, 0.001, dist-0.001, &shadow) {
+		return vec3{}
+	}
+
+	// lambertian brdf albedo/pi, and the area pdf turned into solid angle
+	weight := cosSurface * cosLight / (dist * dist) * e.shape.area() * total / e.power / math.Pi
+	return e.light.color.eval(lambda).mulv(albedo).mul(weight)
+}
+
+type photon struct {
+	p     vec3
+	dir   vec3 // direction it was travelling
+	power vec3 // rgb flux
+}
+
+// photonMap is a balanced kd tree stored in place: each range's median is
+// its node, split on the axis in axis[] at the same index
+type photonMap struct {
+	photons []photon
+	axis    []uint8
+	lights  []emitter // where the photons came from, for direct lighting
+}
+
+func (pm *photonMap) build(lo, hi int) {
+	if hi-lo <= 1 {
+		return
+	}
+	box := emptyBox()
+	for _, ph := range pm.photons[lo:hi] {
+		box = box.union(aabb{ph.p, ph.p})
+	}
+	ext := box.max.sub(box.min)
+	axis := 0
+	if ext.y > ext.x {
+		axis = 1
+	}
+	if ext.z > component(ext, axis) {
+		axis = 2
+	}
+
+	slices.SortFunc(pm.photons[lo:hi], func(a, b photon) int {
+		ca, cb := component(a.p, axis), component(b.p, axis)
+		switch {
+		case ca < cb:
+			return -1
+		case ca > cb:
+			return 1
+		}
+		return 0
+	})
+	mid := (lo + hi) / 2
+	pm.axis[mid] = uint8(axis)
+	pm.build(lo, mid)
+	pm.build(mid+1, hi)
+}
+
+// neighbor is a photon found by a lookup and its squared distance
+type neighbor struct {
+	index int
+	dist2 float64
+}
+
+// nearest is a max heap on distance, so the farthest of the k kept so far is
+// the one to drop
+type nearest []neighbor
+
+func (h nearest) Len() int           { return len(h) }
+func (h nearest) Less(i, j int) bool { return h[i].dist2 > h[j].dist2 }
+func (h nearest) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nearest) Push(x any)        { *h = append(*h, x.(neighbor)) }
+func (h *nearest) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// knn fills found with up to k photons within sqrt(maxDist2) of p, and
+// returns the squared radius the search shrank to
+func (pm *photonMap) knn(p vec3, k int, maxDist2 float64, found *nearest) float64 {
+	*found = (*found)[:0]
+	var search func(lo, hi int)
+	search = func(lo, hi int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		ph := &pm.photons[mid]
+		axis := int(pm.axis[mid])
+		d := component(p, axis) - component(ph.p, axis)
+
+		// near side first, the far side only if the splitting plane is
+		// closer than the worst photon kept
+		near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+		if d > 0 {
+			near, far = far, near
+		}
+		search(near[0], near[1])
+
+		if dist2 := p.sub(ph.p).lengthSquared(); dist2 < maxDist2 {
+			heap.Push(found, neighbor{mid, dist2})
+			if found.Len() > k {
+				heap.Pop(found)
+			}
+			if found.Len() == k {
+				maxDist2 = (*found)[0].dist2
+			}
+		}
+		if d*d < maxDist2 {
+			search(far[0], far[1])
+		}
+	}
+	search(0, len(pm.photons))
+	return maxDist2
+}
+
+// photonBatch is how many photons are shot between checks for a cancelled
+// render
+const photonBatch = 4096
+
+// traceCaustics shoots photons from the scene's lights and keeps those that
+// land on a diffuse surface after at least one specular bounce. those light
+// paths are the ones camera paths almost never find. it gives up between
+// batches of photons once ctx is done
+func traceCaustics(ctx context.Context, s *scene, integ *integrator, seed uint64) (*photonMap, error) {
+	emitters := findEmitters(s)
+	pm := &photonMap{lights: emitters}
+	if len(emitters) == 0 {
+		return pm, nil
+	}
+	total := totalPower(emitters)
+
+	rng := rand.New(rand.NewPCG(seed, math.MaxUint64))
+	stats := newWorkerStats(integ.maxDepth)
+	tr := &tracer{scene: s, integ: integ, rng: rng, stats: stats}
+
+	for k := range integ.photons {
+		if k%photonBatch == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		// pick a light by power, then a point on it and a cosine direction
+		e := pickEmitter(emitters, total, rng)
+		p, n := e.shape.samplePoint(rng)
+		dir := n.add(randomUnitVector(rng))
+		if dir.lengthSquared() < 1e-16 {
+			dir = n
+		}
+
+		lambda := 0.0
+		if integ.spectral {
+			lambda = sampleWavelength(rng)
+		}
+		// flux = radiance * area * pi over the chance this light was picked,
+		// shared between all the photons
+		flux := e.light.color.eval(lambda).mul(e.shape.area() * math.Pi * total / e.power / float64(integ.photons))
+
+		r := ray{p, dir.unit()}
+		specular := false
+		for range integ.maxDepth {
+			var rec hitRecord
+			if !tr.hit(r, &rec) {
+				break
+			}
+			mat := rec.mat
+			if mat == nil {
+				mat = defaultMaterial
+			}
+			// diffuse is lambertian, the surfaces rayColor gathers on.
+			// everything else counts as specular
+			if _, ok := mat.(*lambertian); ok {
+				if specular {
+					power := flux
+					if lambda != 0 {
+						power = spectralToRGB(lambda, flux.x)
+					}
+					pm.photons = append(pm.photons, photon{rec.p, r.dir, power})
+				}
+				break
+			}
+			next, attenuation, ok := mat.scatter(r, &rec, lambda, rng)
+			if !ok {
+				break
+			}
+			r = next
+			flux = flux.mulv(attenuation)
+			specular = true
+		}
+	}
+
+	pm.axis = make([]uint8, len(pm.photons))
+	pm.build(0, len(pm.photons))
+	return pm, nil
+}
+
+// estimate is the caustic radiance leaving a diffuse hit toward the camera:
+// flux of the nearest photons over the disc they cover, times the brdf
+func (pm *photonMap) estimate(rec *hitRecord, albedo vec3, k int, radius float64, found *nearest) vec3 {
+	if len(pm.photons) == 0 {
+		return vec3{}
+	}
+	r2 := pm.knn(rec.p, k, radius*radius, found)
+	var flux vec3
+	for _, nb := range *found {
+		ph := &pm.photons[nb.index]
+		// only photons arriving on the side we're looking at
+		if ph.dir.dot(rec.normal) < 0 {
+			flux = flux.add(ph.power)
+		}
+	}
+	return albedo.mulv(flux).div(math.Pi * math.Pi * r2)
+}
+
+// causticsScene is a glass ball and a metal ring under a small ceiling light,
+// in a dark room so the caustics they focus on the floor stand out
+func causticsScene() *scene {
+	floor := &lambertian{newSpectralColor(vec3{0.75, 0.75, 0.75})}
+	lamp := &light{newSpectralColor(vec3{40, 38, 34})}
+	objs := hittableList{
+		&shaded{&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}}, floor},
+		&shaded{&plane{point: vec3{0, 0, -2.5}, normal: vec3{0, 0, 1}}, floor},
+		&shaded{&sphere{center: vec3{-0.45, -0.15, -1.1}, radius: 0.35}, &dielectric{bk7}},
+		&shaded{&cylinder{base: vec3{0.5, -0.5, -1.2}, axis: vec3{0, 1, 0}, radius: 0.3, height: 0.25}, &metal{newSpectralColor(vec3{0.95, 0.85, 0.6}), 0}},
+	}
+	// one triangle per object, photons start on top level lights
+	for _, tri := range quad(vec3{-0.15, 1.2, -0.85}, vec3{0, 0, -0.3}, vec3{0.3, 0, 0}) {
+		objs = append(objs, &shaded{tri, lamp})
+	}
+	return &scene{objects: objs, background: func(ray, float64) vec3 { return vec3{} }}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rerender the reference images in testdata, which takes a while")
+
+// the caustics test scene at a size that renders in a second or two
+const causticsW, causticsH = 48, 27
+
+var causticsRef = filepath.Join("testdata", "caustics.pfm")
+
+func renderCaustics(method integration, spp, photons int) *film {
+	// low from the side, where the ball's caustic shows on the floor
+	cam := newCamera(causticsW, causticsH)
+	cam.lookFrom = vec3{-1.3, 0.1, -0.3}
+	cam.lookAt = vec3{-0.5, -0.45, -1.1}
+	smp := &sampler{spp: spp, workers: 4, seed: 1}
+	integ := &integrator{
+		shade:        shadeDiffuse,
+		maxDepth:     10,
+		method:       method,
+		photons:      photons,
+		gather:       64,
+		gatherRadius: 0.05,
+	}
+	return render(causticsScene(), cam, smp, integ)
+}
+
+// rmse compares a render against a reference of the same size
+func rmse(a, b []vec3) float64 {
+	var sum float64
+	for k := range a {
+		d := a[k].sub(b[k])
+		sum += d.lengthSquared() / 3
+	}
+	return math.Sqrt(sum / float64(len(a)))
+}
+
+func TestCausticsAgainstReference(t *testing.T) {
+	if *update {
+		// plain path tracing is unbiased, so with enough samples it's the
+		// answer the photon map has to get close to
+		ref := renderCaustics(integratePath, 1<<16, 0)
+		if err := writePFM(causticsRef, ref.width, ref.height, ref.color); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if testing.Short() {
+		t.Skip("renders the caustics scene")
+	}
+	w, h, ref, err := readPFM(causticsRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != causticsW || h != causticsH {
+		t.Fatalf("reference is %dx%d, want %dx%d", w, h, causticsW, causticsH)
+	}
+
+	photon := renderCaustics(integratePhoton, 64, 200000)
+	path := renderCaustics(integratePath, 64, 0)
+	photonErr, pathErr := rmse(photon.color, ref), rmse(path.color, ref)
+	t.Logf("rmse at 64 spp: photon %.4f, path %.4f", photonErr, pathErr)
+	if photon.photons == 0 {
+		t.Fatal("no caustic photons were stored")
+	}
+	// the photon map's bias from its gather radius is far smaller than the
+	// noise path tracing leaves in the caustics at the same sample count.
+	// light that can't leave the ball through its back face puts the error
+	// near 0.018, the limit leaves a little room over that
+	if photonErr > 0.023 {
+		t.Errorf("photon render rmse %.4f against the reference, want at most 0.023", photonErr)
+	}
+	if photonErr > pathErr/2 {
+		t.Errorf("photon render rmse %.4f isn't well under path tracing's %.4f", photonErr, pathErr)
+	}
+}
+
+func TestTraceCausticsCancelled(t *testing.T) {
+	integ := &integrator{shade: shadeDiffuse, maxDepth: 10, method: integratePhoton, photons: 1 << 30, gather: 64, gatherRadius: 0.05}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// a billion photons would take minutes, a cancelled render stops at the
+	// first batch
+	if pm, err := traceCaustics(ctx, causticsScene(), integ, 1); err != context.Canceled || pm != nil {
+		t.Errorf("got %v photon map and error %v, want context.Canceled", pm, err)
+	}
+
+	f := newFilm(causticsW, causticsH)
+	if err := renderInto(ctx, f, causticsScene(), newCamera(causticsW, causticsH), &sampler{spp: 1, workers: 2}, integ); err != context.Canceled {
+		t.Errorf("render error %v, want context.Canceled", err)
+	}
+	if f.rowsDone != 0 || f.photons != 0 {
+		t.Errorf("cancelled render did %d rows and stored %d photons", f.rowsDone, f.photons)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// polynomial root finders after Jochen Schwarze's "Cubic and Quartic Roots"
+// in Graphics Gems I. coefficients are highest power first and every solver
+// returns the real roots in ascending order
+
+const polyEps = 1e-12
+
+func isZero(x float64) bool {
+	return x > -polyEps && x < polyEps
+}
+
+// a*x^2 + b*x + c = 0
+func solveQuadratic(a, b, c float64) []float64 {
+	if isZero(a) {
+		if isZero(b) {
+			return nil
+		}
+		return []float64{-c / b}
+	}
+
+	// x^2 + 2px + q = 0
+	p := b / (2 * a)
+	q := c / a
+	d := p*p - q
+
+	switch {
+	case isZero(d):
+		return []float64{-p}
+	case d < 0:
+		return nil
+	}
+	s := math.Sqrt(d)
+	return []float64{-s - p, s - p}
+}
+
+// a*x^3 + b*x^2 + c*x + d = 0
+func solveCubic(a, b, c, d float64) []float64 {
+	if isZero(a) {
+		return solveQuadratic(b, c, d)
+	}
+
+	// normal form x^3 + Ax^2 + Bx + C = 0
+	A := b / a
+	B := c / a
+	C := d / a
+
+	// substitute x = y - A/3 to eliminate the quadric term: y^3 + 3py + 2q = 0
+	sqA := A * A
+	p := (-sqA/3 + B) / 3
+	q := (2*A*sqA/27 - A*B/3 + C) / 2
+
+	cbP := p * p * p
+	D := q*q + cbP
+
+	var roots []float64
+	switch {
+	case isZero(D):
+		if isZero(q) { // one triple root
+			roots = []float64{0}
+		} else { // one single and one double root
+			u := math.Cbrt(-q)
+			roots = []float64{2 * u, -u}
+		}
+	case D < 0: // three real roots, trigonometric solution
+		phi := math.Acos(-q/math.Sqrt(-cbP)) / 3
+		t := 2 * math.Sqrt(-p)
+		roots = []float64{
+			t * math.Cos(phi),
+			-t * math.Cos(phi+math.Pi/3),
+			-t * math.Cos(phi-math.Pi/3),
+		}
+	default: // one real root
+		s := math.Sqrt(D)
+		roots = []float64{math.Cbrt(s-q) - math.Cbrt(s+q)}
+	}
+
+	for k := range roots {
+		roots[k] -= A / 3
+	}
+	sort.Float64s(roots)
+	return roots
+}
+
+// a*x^4 + b*x^3 + c*x^2 + d*x + e = 0
+func solveQuartic(a, b, c, d, e float64) []float64 {
+	if isZero(a) {
+		return solveCubic(b, c, d, e)
+	}
+
+	// normal form x^4 + Ax^3 + Bx^2 + Cx + D = 0
+	A := b / a
+	B := c / a
+	C := d / a
+	D := e / a
+
+	// substitute x = y - A/4 to eliminate the cubic term: y^4 + py^2 + qy + r = 0
+	sqA := A * A
+	p := -3*sqA/8 + B
+	q := sqA*A/8 - A*B/2 + C
+	r := -3*sqA*sqA/256 + sqA*B/16 - A*C/4 + D
+
+	var roots []float64
+	if isZero(r) {
+		// no absolute term: y(y^3 + py + q) = 0
+		roots = append(solveCubic(1, 0, p, q), 0)
+	} else {
+		// solve the resolvent cubic and take its largest real root, which
+		// keeps u and v below from going negative through rounding
+		zs := solveCubic(1, -p/2, -r, r*p/2-q*q/8)
+		z := zs[len(zs)-1]
+
+		// to build two quadratic equations
+		u := z*z - r
+		v := 2*z - p
+
+		switch {
+		case isZero(u):
+			u = 0
+		case u > 0:
+			u = math.Sqrt(u)
+		default:
+			return nil
+		}
+		switch {
+		case isZero(v):
+			v = 0
+		case v > 0:
+			v = math.Sqrt(v)
+		default:
+			return nil
+		}
+
+		if q < 0 {
+			v = -v
+		}
+		roots = append(solveQuadratic(1, v, z-u), solveQuadratic(1, -v, z+u)...)
+	}
+
+	for k := range roots {
+		roots[k] -= A / 4
+		// a couple of newton steps on the original polynomial clean up the
+		// precision lost in the resolvent
+		for range 2 {
+			x := roots[k]
+			f := (((a*x+b)*x+c)*x+d)*x + e
+			df := ((4*a*x+3*b)*x+2*c)*x + d
+			if df != 0 {
+				roots[k] = x - f/df
+			}
+		}
+	}
+	sort.Float64s(roots)
+	return roots
+}
@@ -0,0 +1,293 @@
+package main
+
+import "math"
+
+// tangentBasis returns two unit vectors perpendicular to unit n and each other
+func tangentBasis(n vec3) (vec3, vec3) {
+	helper := vec3{1, 0, 0}
+	if math.Abs(n.x) > 0.9 {
+		helper = vec3{0, 1, 0}
+	}
+	t := helper.cross(n).unit()
+	return t, n.cross(t)
+}
+
+// polarUV maps a point on a disk of the given radius around center to
+// u = angle around the normal, v = distance from center
+func polarUV(p, center, normal vec3, radius float64) (u, v float64) {
+	t, b := tangentBasis(normal)
+	d := p.sub(center)
+	phi := math.Atan2(d.dot(b), d.dot(t))
+	if phi < 0 {
+		phi += 2 * math.Pi
+	}
+	return phi / (2 * math.Pi), d.length() / radius
+}
+
+// hitPlaneT returns where a ray meets the plane through point with normal, and
+// false if the ray runs parallel to it
+func hitPlaneT(r ray, point, normal vec3) (float64, bool) {
+	denom := r.dir.dot(normal)
+	if math.Abs(denom) < 1e-12 {
+		return 0, false
+	}
+	return point.sub(r.orig).dot(normal) / denom, true
+}
+
+// infinite plane through point, u and v are world distances along the plane's
+// tangent basis so textures repeat every unit
+type plane struct {
+	point  vec3
+	normal vec3 // unit
+}
+
+func (pl *plane) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	t, ok := hitPlaneT(r, pl.point, pl.normal)
+	if !ok || t <= tMin || t >= tMax {
+		return false
+	}
+
+	rec.t = t
+	rec.p = r.at(t)
+	rec.setFaceNormal(r, pl.normal)
+
+	tu, tv := tangentBasis(pl.normal)
+	d := rec.p.sub(pl.point)
+	rec.u, rec.v = d.dot(tu), d.dot(tv)
+	rec.tangent = tu
+	return true
+}
+
+// boundingBox is infinite except along an axis the plane is perpendicular to
+func (pl *plane) boundingBox() aabb {
+	inf := math.Inf(1)
+	b := aabb{vec3{-inf, -inf, -inf}, vec3{inf, inf, inf}}
+	const pad = 1e-4
+	switch {
+	case math.Abs(pl.normal.x) == 1:
+		b.min.x, b.max.x = pl.point.x-pad, pl.point.x+pad
+	case math.Abs(pl.normal.y) == 1:
+		b.min.y, b.max.y = pl.point.y-pad, pl.point.y+pad
+	case math.Abs(pl.normal.z) == 1:
+		b.min.z, b.max.z = pl.point.z-pad, pl.point.z+pad
+	}
+	return b
+}
+
+type disk struct {
+	center vec3
+	normal vec3 // unit
+	radius float64
+}
+
+func (dk *disk) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	t, ok := hitPlaneT(r, dk.center, dk.normal)
+	if !ok || t <= tMin || t >= tMax {
+		return false
+	}
+	p := r.at(t)
+	if p.sub(dk.center).lengthSquared() > dk.radius*dk.radius {
+		return false
+	}
+
+	rec.t = t
+	rec.p = p
+	rec.setFaceNormal(r, dk.normal)
+	rec.u, rec.v = polarUV(p, dk.center, dk.normal, dk.radius)
+	return true
+}
+
+func (dk *disk) boundingBox() aabb {
+	e := diskExtent(dk.normal, dk.radius)
+	return aabb{dk.center.sub(e), dk.center.add(e)}
+}
+
+// capped cylinder from base along axis for height
+type cylinder struct {
+	base   vec3
+	axis   vec3 // unit
+	radius float64
+	height float64
+}
+
+func (cy *cylinder) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	found := false
+
+	// side: drop the axial part of origin and direction, what's left is a
+	// circle intersection in the plane across the axis
+	oc := r.orig.sub(cy.base)
+	dPerp := r.dir.sub(cy.axis.mul(r.dir.dot(cy.axis)))
+	ocPerp := oc.sub(cy.axis.mul(oc.dot(cy.axis)))
+
+	a := dPerp.dot(dPerp)
+	b := 2 * dPerp.dot(ocPerp)
+	c := ocPerp.dot(ocPerp) - cy.radius*cy.radius
+
+	for _, t := range solveQuadratic(a, b, c) {
+		if t <= tMin || t >= tMax {
+			continue
+		}
+		p := r.at(t)
+		y := p.sub(cy.base).dot(cy.axis)
+		if y < 0 || y > cy.height {
+			continue
+		}
+
+		tMax = t
+		found = true
+		rec.t = t
+		rec.p = p
+		outward := p.sub(cy.base).sub(cy.axis.mul(y)).div(cy.radius)
+		rec.setFaceNormal(r, outward)
+		u, _ := polarUV(p, cy.base.add(cy.axis.mul(y)), cy.axis, cy.radius)
+		rec.u, rec.v = u, y/cy.height
+		break // roots are ascending, the first valid one is nearest
+	}
+
+	// caps
+	for _, c := range []struct {
+		center vec3
+		normal vec3
+	}{
+		{cy.base, cy.axis.neg()},
+		{cy.base.add(cy.axis.mul(cy.height)), cy.axis},
+	} {
+		lid := disk{c.center, c.normal, cy.radius}
+		if lid.hit(r, tMin, tMax, rec) {
+			tMax = rec.t
+			found = true
+		}
+	}
+	return found
+}
+
+func (cy *cylinder) boundingBox() aabb {
+	e := diskExtent(cy.axis, cy.radius)
+	top := cy.base.add(cy.axis.mul(cy.height))
+	return aabb{cy.base.sub(e), cy.base.add(e)}.union(aabb{top.sub(e), top.add(e)})
+}
+
+// capped cone with its base disk at base and its apex height along axis
+type cone struct {
+	base   vec3
+	axis   vec3 // unit
+	radius float64
+	height float64
+}
+
+func (cn *cone) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	found := false
+
+	// with q = p - base and y = q.axis, the side is where the distance from
+	// the axis equals k*(height - y):
+	//   |q|^2 - y^2 - k^2 (height - y)^2 = 0
+	k := cn.radius / cn.height
+	k2 := k * k
+	oc := r.orig.sub(cn.base)
+	dy := r.dir.dot(cn.axis)
+	oy := oc.dot(cn.axis)
+	h := cn.height - oy
+
+	a := r.dir.dot(r.dir) - dy*dy - k2*dy*dy
+	b := 2 * (oc.dot(r.dir) - oy*dy + k2*h*dy)
+	c := oc.dot(oc) - oy*oy - k2*h*h
+
+	for _, t := range solveQuadratic(a, b, c) {
+		if t <= tMin || t >= tMax {
+			continue
+		}
+		p := r.at(t)
+		y := p.sub(cn.base).dot(cn.axis)
+		if y < 0 || y > cn.height { // the equation also holds on the mirrored cone
+			continue
+		}
+
+		tMax = t
+		found = true
+		rec.t = t
+		rec.p = p
+
+		// gradient of the implicit equation: radial part plus k^2 (height - y) along the axis
+		radial := p.sub(cn.base).sub(cn.axis.mul(y))
+		g := radial.add(cn.axis.mul(k2 * (cn.height - y)))
+		outward := cn.axis // the apex itself has no gradient
+		if g.lengthSquared() > 1e-18 {
+			outward = g.unit()
+		}
+		rec.setFaceNormal(r, outward)
+		u, _ := polarUV(p, cn.base.add(cn.axis.mul(y)), cn.axis, cn.radius)
+		rec.u, rec.v = u, y/cn.height
+		break
+	}
+
+	bottom := disk{cn.base, cn.axis.neg(), cn.radius}
+	if bottom.hit(r, tMin, tMax, rec) {
+		found = true
+	}
+	return found
+}
+
+func (cn *cone) boundingBox() aabb {
+	e := diskExtent(cn.axis, cn.radius)
+	apex := cn.base.add(cn.axis.mul(cn.height))
+	return aabb{cn.base.sub(e), cn.base.add(e)}.union(aabb{apex, apex})
+}
+
+// torus around center with the ring in the plane perpendicular to axis. major
+// is the ring radius, minor the tube radius
+type torus struct {
+	center vec3
+	axis   vec3 // unit
+	major  float64
+	minor  float64
+}
+
+func (to *torus) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	// work in the torus frame, axis along z
+	e1, e2 := tangentBasis(to.axis)
+	toLocal := func(v vec3) vec3 {
+		return vec3{v.dot(e1), v.dot(e2), v.dot(to.axis)}
+	}
+	o := toLocal(r.orig.sub(to.center))
+	d := toLocal(r.dir)
+
+	// (|p|^2 + R^2 - r^2)^2 = 4R^2 (x^2 + y^2) with p = o + t*d expands to a quartic
+	R2 := to.major * to.major
+	m := d.dot(d)
+	n := o.dot(d)
+	k := o.dot(o) + R2 - to.minor*to.minor
+
+	a := m * m
+	b := 4 * m * n
+	c := 4*n*n + 2*m*k - 4*R2*(d.x*d.x+d.y*d.y)
+	dd := 4*n*k - 8*R2*(o.x*d.x+o.y*d.y)
+	e := k*k - 4*R2*(o.x*o.x+o.y*o.y)
+
+	for _, t := range solveQuartic(a, b, c, dd, e) {
+		if t <= tMin || t >= tMax {
+			continue
+		}
+		p := o.add(d.mul(t))
+
+		// gradient: 4p(|p|^2 + R^2 - r^2) - 8R^2 (x, y, 0)
+		g := p.mul(p.dot(p) + R2 - to.minor*to.minor).sub(vec3{p.x, p.y, 0}.mul(2 * R2))
+		outward := e1.mul(g.x).add(e2.mul(g.y)).add(to.axis.mul(g.z)).unit()
+
+		rec.t = t
+		rec.p = r.at(t)
+		rec.setFaceNormal(r, outward)
+
+		// u around the ring, v around the tube
+		u := math.Atan2(p.y, p.x)
+		v := math.Atan2(p.z, math.Hypot(p.x, p.y)-to.major)
+		rec.u = (u + math.Pi) / (2 * math.Pi)
+		rec.v = (v + math.Pi) / (2 * math.Pi)
+		return true
+	}
+	return false
+}
+
+func (to *torus) boundingBox() aabb {
+	e := diskExtent(to.axis, to.major).add(vec3{to.minor, to.minor, to.minor})
+	return aabb{to.center.sub(e), to.center.add(e)}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+type hitCase struct {
+	name       string
+	ray        ray
+	tMin, tMax float64 // 0, 0 for 1e-3 and infinity
+	hit        bool
+	t          float64
+	front      bool
+	normal     vec3 // zero to skip the check
+}
+
+// checkHits runs the cases against obj and checks what every hit has to
+// satisfy: the normal is unit and faces the ray, the point is on the ray and
+// inside the bounding box
+func checkHits(t *testing.T, obj hittable, cases []hitCase) {
+	t.Helper()
+	box := obj.boundingBox()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tMin, tMax := c.tMin, c.tMax
+			if tMin == 0 && tMax == 0 {
+				tMin, tMax = 1e-3, math.Inf(1)
+			}
+			var rec hitRecord
+			got := obj.hit(c.ray, tMin, tMax, &rec)
+			if got != c.hit {
+				t.Fatalf("hit %v, want %v (t %v)", got, c.hit, rec.t)
+			}
+			if !got {
+				return
+			}
+			if math.Abs(rec.t-c.t) > 1e-6 {
+				t.Errorf("t %v, want %v", rec.t, c.t)
+			}
+			if rec.t <= tMin || rec.t >= tMax {
+				t.Errorf("t %v outside (%v, %v)", rec.t, tMin, tMax)
+			}
+			if rec.frontFace != c.front {
+				t.Errorf("front face %v, want %v", rec.frontFace, c.front)
+			}
+			if c.normal != (vec3{}) && !near(rec.normal, c.normal, 1e-6) {
+				t.Errorf("normal %v, want %v", rec.normal, c.normal)
+			}
+			if math.Abs(rec.normal.length()-1) > 1e-9 {
+				t.Errorf("normal %v is not unit", rec.normal)
+			}
+			if rec.normal.dot(c.ray.dir) > 1e-9 {
+				t.Errorf("normal %v faces along the ray", rec.normal)
+			}
+			if !near(rec.p, c.ray.at(rec.t), 1e-9) {
+				t.Errorf("point %v is not on the ray at t %v", rec.p, rec.t)
+			}
+			pad := vec3{1e-6, 1e-6, 1e-6}
+			if rec.p.x < box.min.x-pad.x || rec.p.y < box.min.y-pad.y || rec.p.z < box.min.z-pad.z ||
+				rec.p.x > box.max.x+pad.x || rec.p.y > box.max.y+pad.y || rec.p.z > box.max.z+pad.z {
+				t.Errorf("point %v outside bounding box %v", rec.p, box)
+			}
+		})
+	}
+}
+
+func TestSphereHit(t *testing.T) {
+	s := &sphere{center: vec3{0, 0, -2}, radius: 1}
+	fwd := vec3{0, 0, -1}
+	checkHits(t, s, []hitCase{
+		{name: "straight", ray: ray{vec3{}, fwd}, hit: true, t: 1, front: true, normal: vec3{0, 0, 1}},
+		{name: "unnormalized direction", ray: ray{vec3{}, fwd.mul(2)}, hit: true, t: 0.5, front: true},
+		{name: "behind", ray: ray{vec3{}, fwd.neg()}},
+		{name: "inside origin", ray: ray{vec3{0, 0, -2}, fwd}, hit: true, t: 1, normal: vec3{0, 0, 1}},
+		{name: "inside origin sideways", ray: ray{vec3{0, 0, -2}, vec3{1, 0, 0}}, hit: true, t: 1, normal: vec3{-1, 0, 0}},
+		{name: "on the surface heading in", ray: ray{vec3{0, 0, -1}, fwd}, hit: true, t: 2, normal: vec3{0, 0, 1}},
+		{name: "on the surface heading out", ray: ray{vec3{0, 0, -1}, fwd.neg()}},
+		{name: "grazing", ray: ray{vec3{1 - 1e-6, 0, 0}, fwd}, hit: true, t: 2 - math.Sqrt(1-(1-1e-6)*(1-1e-6)), front: true},
+		{name: "just missing", ray: ray{vec3{1 + 1e-6, 0, 0}, fwd}},
+		{name: "tMax before it", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 0.5},
+		{name: "tMax between the roots", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 2, hit: true, t: 1, front: true},
+		{name: "tMin past the near root", ray: ray{vec3{}, fwd}, tMin: 1.5, tMax: math.Inf(1), hit: true, t: 3, normal: vec3{0, 0, 1}},
+		{name: "tMin past both roots", ray: ray{vec3{}, fwd}, tMin: 3.5, tMax: math.Inf(1)},
+	})
+}
+
+func TestHitSphereMatchesQuadratic(t *testing.T) {
+	// the half-b form and the full quadratic agree on near and far roots
+	rays := []ray{
+		{vec3{}, vec3{0, 0, -1}},
+		{vec3{0.3, -0.2, 0}, vec3{-0.1, 0.05, -1}},
+		{vec3{0, 0, -2}, vec3{0.6, 0.8, 0}},
+		{vec3{0, 0, -1}, vec3{0, 0, -1}},
+		{vec3{0.9, 0.4, 0}, vec3{0, 0, -3}},
+	}
+	for _, r := range rays {
+		for _, tMin := range []float64{1e-3, 1.5} {
+			a := hitSphere(0, 0, -2, 1, r.orig.x, r.orig.y, r.orig.z, r.dir.x, r.dir.y, r.dir.z, tMin)
+			b := hitSphereQuadratic(0, 0, -2, 1, r.orig.x, r.orig.y, r.orig.z, r.dir.x, r.dir.y, r.dir.z, tMin)
+			if math.Abs(a-b) > 1e-9 {
+				t.Errorf("ray %v tMin %v: half-b %v, quadratic %v", r, tMin, a, b)
+			}
+		}
+	}
+}
+
+func TestPlaneHit(t *testing.T) {
+	pl := &plane{point: vec3{0, -1, 0}, normal: vec3{0, 1, 0}}
+	down := vec3{0, -1, 0}
+	checkHits(t, pl, []hitCase{
+		{name: "from above", ray: ray{vec3{}, down}, hit: true, t: 1, front: true, normal: vec3{0, 1, 0}},
+		{name: "from below", ray: ray{vec3{0, -2, 0}, down.neg()}, hit: true, t: 1, normal: vec3{0, -1, 0}},
+		{name: "away", ray: ray{vec3{}, down.neg()}},
+		{name: "parallel", ray: ray{vec3{}, vec3{1, 0, 0}}},
+		{name: "grazing", ray: ray{vec3{}, vec3{1, -1e-3, 0}}, hit: true, t: 1000, front: true},
+		{name: "origin on the plane", ray: ray{vec3{0, -1, 0}, down}},
+		{name: "tMax before it", ray: ray{vec3{}, down}, tMin: 1e-3, tMax: 0.5},
+		{name: "tMin past it", ray: ray{vec3{}, down}, tMin: 1.5, tMax: math.Inf(1)},
+	})
+}
+
+func TestDiskHit(t *testing.T) {
+	dk := &disk{center: vec3{0, 0, -1}, normal: vec3{0, 0, 1}, radius: 1}
+	fwd := vec3{0, 0, -1}
+	checkHits(t, dk, []hitCase{
+		{name: "center", ray: ray{vec3{}, fwd}, hit: true, t: 1, front: true, normal: vec3{0, 0, 1}},
+		{name: "from behind", ray: ray{vec3{0, 0, -2}, fwd.neg()}, hit: true, t: 1, normal: vec3{0, 0, -1}},
+		{name: "grazing the rim", ray: ray{vec3{1 - 1e-9, 0, 0}, fwd}, hit: true, t: 1, front: true},
+		{name: "just outside the rim", ray: ray{vec3{1 + 1e-9, 0, 0}, fwd}},
+		{name: "edge on", ray: ray{vec3{-2, 0, -1}, vec3{1, 0, 0}}},
+		{name: "origin on the disk", ray: ray{vec3{0, 0, -1}, fwd}},
+		{name: "tMax before it", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 0.5},
+		{name: "tMin past it", ray: ray{vec3{}, fwd}, tMin: 1.5, tMax: math.Inf(1)},
+	})
+}
+
+func TestCylinderHit(t *testing.T) {
+	// y from -1 to 1 around x = 0, z = -3
+	cy := &cylinder{base: vec3{0, -1, -3}, axis: vec3{0, 1, 0}, radius: 1, height: 2}
+	fwd := vec3{0, 0, -1}
+	checkHits(t, cy, []hitCase{
+		{name: "side", ray: ray{vec3{}, fwd}, hit: true, t: 2, front: true, normal: vec3{0, 0, 1}},
+		{name: "top cap", ray: ray{vec3{0, 5, -3}, vec3{0, -1, 0}}, hit: true, t: 4, front: true, normal: vec3{0, 1, 0}},
+		{name: "bottom cap", ray: ray{vec3{0.5, -5, -3}, vec3{0, 1, 0}}, hit: true, t: 4, front: true, normal: vec3{0, -1, 0}},
+		{name: "inside origin to the side", ray: ray{vec3{0, 0, -3}, fwd}, hit: true, t: 1, normal: vec3{0, 0, 1}},
+		{name: "inside origin to a cap", ray: ray{vec3{0, 0, -3}, vec3{0, 1, 0}}, hit: true, t: 1, normal: vec3{0, -1, 0}},
+		{name: "over the top", ray: ray{vec3{0, 1.5, 0}, fwd}},
+		{name: "grazing the side", ray: ray{vec3{1 - 1e-6, 0, 0}, fwd}, hit: true, t: 3 - math.Sqrt(1-(1-1e-6)*(1-1e-6)), front: true},
+		{name: "just missing the side", ray: ray{vec3{1 + 1e-6, 0, 0}, fwd}},
+		{name: "along the axis outside", ray: ray{vec3{1.5, 5, -3}, vec3{0, -1, 0}}},
+		{name: "tMax before it", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 1.5},
+		{name: "tMin past the near side", ray: ray{vec3{}, fwd}, tMin: 2.5, tMax: math.Inf(1), hit: true, t: 4, normal: vec3{0, 0, 1}},
+	})
+}
+
+func TestConeHit(t *testing.T) {
+	// base disk at y = -1 of radius 1, apex at y = 1, around x = 0, z = -3
+	cn := &cone{base: vec3{0, -1, -3}, axis: vec3{0, 1, 0}, radius: 1, height: 2}
+	fwd := vec3{0, 0, -1}
+	slope := vec3{0, 0.5, 1}.unit()
+	checkHits(t, cn, []hitCase{
+		{name: "side", ray: ray{vec3{}, fwd}, hit: true, t: 2.5, front: true, normal: slope},
+		{name: "base", ray: ray{vec3{0, -2, -3}, vec3{0, 1, 0}}, hit: true, t: 1, front: true, normal: vec3{0, -1, 0}},
+		{name: "inside origin to the side", ray: ray{vec3{0, -0.5, -3}, fwd}, hit: true, t: 0.75},
+		{name: "inside origin to the base", ray: ray{vec3{0, 0, -3}, vec3{0, -1, 0}}, hit: true, t: 1, normal: vec3{0, 1, 0}},
+		{name: "grazing the side", ray: ray{vec3{0.5 - 1e-6, 0, 0}, fwd}, hit: true, t: 3 - math.Sqrt(0.25-(0.5-1e-6)*(0.5-1e-6)), front: true},
+		{name: "just missing the side", ray: ray{vec3{0.5 + 1e-6, 0, 0}, fwd}},
+		{name: "through the mirrored cone", ray: ray{vec3{0, 2, 0}, fwd}},
+		{name: "tMax before it", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 2},
+		{name: "tMin past the near side", ray: ray{vec3{}, fwd}, tMin: 3, tMax: math.Inf(1), hit: true, t: 3.5},
+	})
+}
+
+func TestTorusHit(t *testing.T) {
+	// ring of radius 1 in the xz plane around z = -3, tube radius 0.25
+	to := &torus{center: vec3{0, 0, -3}, axis: vec3{0, 1, 0}, major: 1, minor: 0.25}
+	fwd := vec3{0, 0, -1}
+	const y = 0.25 - 1e-4 // just under the top of the tube
+	half := math.Sqrt(0.25*0.25 - y*y)
+	checkHits(t, to, []hitCase{
+		{name: "tube", ray: ray{vec3{}, fwd}, hit: true, t: 1.75, front: true, normal: vec3{0, 0, 1}},
+		{name: "inside the tube", ray: ray{vec3{0, 0, -2}, fwd}, hit: true, t: 0.25, normal: vec3{0, 0, 1}},
+		{name: "from the hole", ray: ray{vec3{0, 0, -3}, fwd.neg()}, hit: true, t: 0.75, front: true, normal: vec3{0, 0, -1}},
+		{name: "down the hole", ray: ray{vec3{0, 5, -3}, vec3{0, -1, 0}}},
+		{name: "over the top", ray: ray{vec3{0, 0.3, 0}, fwd}},
+		{name: "grazing the top", ray: ray{vec3{-5, y, -3}, vec3{1, 0, 0}}, hit: true, t: 4 - half, front: true},
+		{name: "just over the top", ray: ray{vec3{-5, 0.25 + 1e-4, -3}, vec3{1, 0, 0}}},
+		{name: "tMax before it", ray: ray{vec3{}, fwd}, tMin: 1e-3, tMax: 1.5},
+		{name: "tMin inside the tube", ray: ray{vec3{}, fwd}, tMin: 2, tMax: math.Inf(1), hit: true, t: 2.25, normal: vec3{0, 0, 1}},
+		{name: "tMin past the hole", ray: ray{vec3{}, fwd}, tMin: 3, tMax: math.Inf(1), hit: true, t: 3.75, front: true, normal: vec3{0, 0, 1}},
+	})
+}
+
+func TestPrimitiveUV(t *testing.T) {
+	// everything but the plane maps into [0, 1]
+	objs := map[string]hittable{
+		"sphere":   &sphere{center: vec3{0, 0, -2}, radius: 1},
+		"disk":     &disk{center: vec3{0, 0, -1}, normal: vec3{0, 0, 1}, radius: 1},
+		"cylinder": &cylinder{base: vec3{0, -1, -3}, axis: vec3{0, 1, 0}, radius: 1, height: 2},
+		"cone":     &cone{base: vec3{0, -1, -3}, axis: vec3{0, 1, 0}, radius: 1, height: 2},
+		"torus":    &torus{center: vec3{0, 0, -3}, axis: vec3{0, 1, 0}, major: 1, minor: 0.25},
+	}
+	for name, obj := range objs {
+		for y := -0.9; y <= 0.9; y += 0.15 {
+			for x := -0.9; x <= 0.9; x += 0.15 {
+				var rec hitRecord
+				if !obj.hit(ray{vec3{x, y, 0}, vec3{0, 0, -1}}, 1e-3, math.Inf(1), &rec) {
+					continue
+				}
+				if rec.u < 0 || rec.u > 1 || rec.v < -1e-9 || rec.v > 1+1e-9 {
+					t.Errorf("%s at %v, %v: uv %v, %v", name, x, y, rec.u, rec.v)
+				}
+			}
+		}
+	}
+}
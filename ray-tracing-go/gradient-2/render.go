@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"gfx/framebuffer"
+)
+
+type shadeMode int
+
+const (
+	shadeNormals shadeMode = iota // color surfaces by their normal
+	shadeDiffuse                  // path traced materials lit by the sky and lights
+)
+
+func parseShadeMode(s string) (shadeMode, error) {
+	switch s {
+	case "normals":
+		return shadeNormals, nil
+	case "diffuse":
+		return shadeDiffuse, nil
+	}
+	return shadeNormals, fmt.Errorf("unknown shading %q (want normals or diffuse)", s)
+}
+
+// integrator is how rays turn into colors, shared by every worker
+type integrator struct {
+	shade    shadeMode
+	maxDepth int  // most bounces a diffuse path can take
+	spectral bool // trace one wavelength per path instead of rgb
+
+	method       integration
+	photons      int     // photons shot for the caustic map
+	gather       int     // photons per caustic estimate
+	gatherRadius float64 // farthest a caustic estimate looks
+}
+
+// tracer is one worker's view of a render: the shared scene and integrator
+// plus its own random numbers and counters
+type tracer struct {
+	scene *scene
+	integ *integrator
+	rng   *rand.Rand
+	stats *workerStats
+
+	caustics *photonMap // nil unless the photon integrator is on
+	found    nearest    // lookup buffer for caustic estimates
+}
+
+func (tr *tracer) hit(r ray, rec *hitRecord) bool {
+	tr.stats.objectVisits += int64(len(tr.scene.objects))
+	return tr.scene.hit(r, rec)
+}
+
+// features are the first surface a camera ray hits, without lighting or
+// noise. the denoiser uses them to find edges the noisy color can't show
+type features struct {
+	normal vec3 // zero for the sky
+	albedo vec3
+}
+
+// rayColor returns a linear rgb color with each component between 0 and 1
+func (tr *tracer) rayColor(r ray) (vec3, features) {
+	tr.stats.primaryRays++
+
+	if tr.integ.shade == shadeNormals {
+		tr.stats.pathLengths.add(1)
+		var rec hitRecord
+		if tr.hit(r, &rec) {
+			// Map normal from [-1,1] to [0,1]
+			n := rec.normal
+			c := vec3{n.x + 1, n.y + 1, n.z + 1}.mul(0.5)
+			return c, features{n, c}
+		}
+		c := sky(r)
+		return c, features{albedo: c}
+	}
+
+	// diffuse: follow the path one bounce at a time, picking up light from
+	// emitters it hits, until it escapes to the sky, is absorbed or runs out
+	// of depth. spectral paths carry one wavelength in every channel
+	lambda := 0.0
+	if tr.integ.spectral {
+		lambda = sampleWavelength(tr.rng)
+	}
+	var first features
+	var radiance vec3
+	throughput := vec3{1, 1, 1}
+
+	// the photon integrator splits the light at diffuse surfaces: direct
+	// from sampling the lights, caustics (light through specular bounces)
+	// from the photons, and only the rest by following the path. once a
+	// diffuse vertex is behind, emitters the path runs into were already
+	// counted one of those ways. caustic is rgb even for spectral paths, as
+	// the photons are
+	var caustic vec3
+	diffuseBehind := false
+	// a sun is sampled from every diffuse hit, so a path that runs straight
+	// from one into the disc has been counted already
+	lastDiffuse := false
+
+	for depth := 0; ; depth++ {
+		if depth == tr.integ.maxDepth {
+			tr.stats.pathLengths.add(depth)
+			break
+		}
+		if depth > 0 {
+			tr.stats.secondaryRays++
+		}
+
+		var rec hitRecord
+		if !tr.hit(r, &rec) {
+			tr.stats.pathLengths.add(depth + 1)
+			if depth == 0 {
+				first.albedo = tr.background(r, 0)
+			}
+			radiance = radiance.add(throughput.mulv(tr.background(r, lambda)))
+			if sun := tr.scene.sun; sun != nil && !lastDiffuse && sun.visible(r.dir) {
+				radiance = radiance.add(throughput.mulv(sun.radiance(lambda)))
+			}
+			break
+		}
+		mat := rec.mat
+		if mat == nil {
+			mat = defaultMaterial
+		}
+		if depth == 0 {
+			first = features{rec.normal, mat.albedo()}
+		}
+
+		if tr.caustics == nil || !diffuseBehind {
+			radiance = radiance.add(throughput.mulv(mat.emitted(&rec, lambda)))
+		}
+		lam, diffuse := mat.(*lambertian)
+		if diffuse && tr.scene.sun != nil {
+			radiance = radiance.add(throughput.mulv(tr.sunLight(&rec, lam.color.eval(lambda), lambda)))
+		}
+		lastDiffuse = diffuse
+		if diffuse && tr.caustics != nil {
+			radiance = radiance.add(throughput.mulv(tr.directLight(&rec, lam.color.eval(lambda), lambda)))
+			est := tr.caustics.estimate(&rec, lam.color.rgb, tr.integ.gather, tr.integ.gatherRadius, &tr.found)
+			if lambda != 0 {
+				caustic = caustic.add(est.mul(throughput.x))
+			} else {
+				caustic = caustic.add(est.mulv(throughput))
+			}
+			diffuseBehind = true
+		}
+
+		next, attenuation, ok := mat.scatter(r, &rec, lambda, tr.rng)
+		if !ok {
+			tr.stats.pathLengths.add(depth + 1)
+			break
+		}
+		r = next
+		throughput = throughput.mulv(attenuation)
+	}
+	if lambda != 0 {
+		return spectralToRGB(lambda, radiance.x).add(caustic), first
+	}
+	return radiance.add(caustic), first
+}
+
+// background is what a diffuse path sees when it leaves the scene
+func (tr *tracer) background(r ray, lambda float64) vec3 {
+	if tr.scene.background != nil {
+		return tr.scene.background(r, lambda)
+	}
+	return skyAt(r, lambda)
+}
+
+func sky(r ray) vec3 {
+	// sky gradient: a = 0.5*(unit_direction.y + 1.0), only using y because top to bottom
+	a := 0.5 * (r.dir.unit().y + 1.0)
+
+	// linear interpolation white -> light blue
+	return vec3{1.0, 1.0, 1.0}.mul(1.0 - a).add(vec3{0.5, 0.7, 1.0}.mul(a))
+}
+
+// skyAt is the sky for an rgb path, or its spectrum at lambda for a spectral
+// one. the gradient is a blend of two colors, so the spectrum is the same
+// blend of theirs
+func skyAt(r ray, lambda float64) vec3 {
+	if lambda == 0 {
+		return sky(r)
+	}
+	a := 0.5 * (r.dir.unit().y + 1.0)
+	return skyWhite.eval(lambda).mul(1.0 - a).add(skyBlue.eval(lambda).mul(a))
+}
+
+var (
+	skyWhite = newSpectralColor(vec3{1.0, 1.0, 1.0})
+	skyBlue  = newSpectralColor(vec3{0.5, 0.7, 1.0})
+)
+
+func randomUnitVector(rng *rand.Rand) vec3 {
+	for {
+		p := vec3{rng.Float64()*2 - 1, rng.Float64()*2 - 1, rng.Float64()*2 - 1}
+		if l := p.lengthSquared(); l > 1e-160 && l <= 1 {
+			return p.div(math.Sqrt(l))
+		}
+	}
+}
+
+// film holds a render before it's quantized to bytes
+type film struct {
+	width, height int
+	color         []vec3 // mean linear color per pixel
+	normal        []vec3 // mean first-hit normal, for the denoiser
+	albedo        []vec3 // mean first-hit albedo, for the denoiser
+	samples       []int  // samples taken per pixel
+
+	elapsed time.Duration
+	stats   []*workerStats // one per render worker
+	photons int            // caustic photons stored, for the photon integrator
+
+	// mu guards the pixels and rowsDone while a render is running, so the
+	// render server can look at a film as it fills in
+	mu       sync.Mutex
+	rowsDone int
+}
+
+func newFilm(width, height int) *film {
+	return &film{
+		width:   width,
+		height:  height,
+		color:   make([]vec3, width*height),
+		normal:  make([]vec3, width*height),
+		albedo:  make([]vec3, width*height),
+		samples: make([]int, width*height),
+	}
+}
+
+func (f *film) toPixels(fb *framebuffer.Buffer) {
+	for j := range f.height {
+		for i := range f.width {
+			c := f.color[j*f.width+i]
+			fb.Set(i, j, framebuffer.RGB(
+				byte(255*clamp(c.x, 0, 1)),
+				byte(255*clamp(c.y, 0, 1)),
+				byte(255*clamp(c.z, 0, 1)),
+			))
+		}
+	}
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return max(lo, min(x, hi))
+}
+
+// render traces every pixel with the sampler, rows are handed out to workers
+func render(s *scene, cam *camera, smp *sampler, integ *integrator) *film {
+	f := newFilm(cam.width, cam.height)
+	renderInto(context.Background(), f, s, cam, smp, integ)
+	return f
+}
+
+// renderInto renders into a film made for the camera's size, stopping between
+// rows once ctx is done. finished rows land under f.mu
+func renderInto(ctx context.Context, f *film, s *scene, cam *camera, smp *sampler, integ *integrator) error {
+	cam.init()
+	start := time.Now()
+	n := max(smp.workers, 1)
+	f.stats = make([]*workerStats, n)
+
+	var caustics *photonMap
+	if integ.shade == shadeDiffuse && integ.method == integratePhoton {
+		var err error
+		if caustics, err = traceCaustics(ctx, s, integ, smp.seed); err != nil {
+			f.elapsed = time.Since(start)
+			return err
+		}
+		f.photons = len(caustics.photons)
+	}
+
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := range n {
+		st := newWorkerStats(integ.maxDepth)
+		f.stats[w] = st
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			color := make([]vec3, f.width)
+			ft := make([]features, f.width)
+			samples := make([]int, f.width)
+			for j := range rows {
+				rowStart := time.Now()
+				// seeding per row keeps renders repeatable whichever worker runs it
+				tr := &tracer{scene: s, integ: integ, rng: rand.New(rand.NewPCG(smp.seed, uint64(j))), stats: st, caustics: caustics}
+				for i := range f.width {
+					color[i], ft[i], samples[i] = smp.pixel(tr, cam, i, j)
+				}
+
+				f.mu.Lock()
+				row := j * f.width
+				copy(f.color[row:], color)
+				copy(f.samples[row:], samples)
+				for i := range f.width {
+					f.normal[row+i], f.albedo[row+i] = ft[i].normal, ft[i].albedo
+				}
+				f.rowsDone++
+				f.mu.Unlock()
+
+				st.rows++
+				st.busy += time.Since(rowStart)
+			}
+		}()
+	}
+feed:
+	for j := range f.height {
+		select {
+		case rows <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(rows)
+	wg.Wait()
+
+	f.elapsed = time.Since(start)
+	return ctx.Err()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+type sampler struct {
+	spp     int // samples per pixel when not adaptive
+	workers int
+	seed    uint64
+
+	// adaptive sampling: every pixel takes minSpp samples, then keeps going in
+	// batches until the standard error of its mean luminance drops under
+	// threshold or it reaches maxSpp. flat sky converges straight away and
+	// the budget goes to edges and noisy surfaces instead
+	adaptive  bool
+	minSpp    int
+	maxSpp    int
+	batch     int
+	threshold float64
+}
+
+func (smp *sampler) validate() error {
+	if smp.adaptive {
+		if smp.minSpp < 2 || smp.maxSpp < smp.minSpp || smp.batch < 1 {
+			return fmt.Errorf("adaptive sampling needs 2 <= min-spp <= max-spp and batch >= 1")
+		}
+		if smp.threshold <= 0 {
+			return fmt.Errorf("adaptive threshold must be positive")
+		}
+		return nil
+	}
+	if smp.spp < 1 {
+		return fmt.Errorf("spp must be at least 1")
+	}
+	return nil
+}
+
+func luminance(c vec3) float64 {
+	return 0.2126*c.x + 0.7152*c.y + 0.0722*c.z
+}
+
+// pixel returns the mean color and features of pixel (i, j) and how many
+// samples it took
+func (smp *sampler) pixel(tr *tracer, cam *camera, i, j int) (vec3, features, int) {
+	// a single sample goes through the pixel center so spp 1 matches the
+	// original one-ray-per-pixel image exactly
+	if !smp.adaptive && smp.spp == 1 {
+		r, ok := cam.getRay(i, j, 0.5, 0.5)
+		if !ok {
+			return vec3{}, features{}, 1
+		}
+		c, ft := tr.rayColor(r)
+		return c, ft, 1
+	}
+
+	var sum vec3
+	var ftSum features
+	n := 0
+
+	// welford's running mean and variance of luminance
+	mean, m2 := 0.0, 0.0
+
+	take := func(count int) {
+		for range count {
+			var c vec3
+			if r, ok := cam.getRay(i, j, tr.rng.Float64(), tr.rng.Float64()); ok {
+				var ft features
+				c, ft = tr.rayColor(r)
+				ftSum.normal = ftSum.normal.add(ft.normal)
+				ftSum.albedo = ftSum.albedo.add(ft.albedo)
+			}
+			sum = sum.add(c)
+			n++
+
+			l := luminance(c)
+			d := l - mean
+			mean += d / float64(n)
+			m2 += d * (l - mean)
+		}
+	}
+
+	if !smp.adaptive {
+		take(smp.spp)
+	} else {
+		take(smp.minSpp)
+		for n < smp.maxSpp {
+			variance := m2 / float64(n-1)
+			if math.Sqrt(variance/float64(n)) <= smp.threshold {
+				break
+			}
+			take(min(smp.batch, smp.maxSpp-n))
+		}
+	}
+
+	ftMean := features{ftSum.normal.div(float64(n)), ftSum.albedo.div(float64(n))}
+	return sum.div(float64(n)), ftMean, n
+}
+
+// heatmap shows samples taken per pixel, blue at the fewest through green to
+// red at the most
+func (f *film) heatmap() *image.RGBA {
+	lo, hi := math.MaxInt, 0
+	for _, n := range f.samples {
+		lo = min(lo, n)
+		hi = max(hi, n)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	for k, n := range f.samples {
+		t := 0.0
+		if hi > lo {
+			t = float64(n-lo) / float64(hi-lo)
+		}
+
+		// two linear ramps: blue -> green for t < 0.5, green -> red above
+		var r, g, b float64
+		if t < 0.5 {
+			g, b = 2*t, 1-2*t
+		} else {
+			r, g = 2*t-1, 2-2*t
+		}
+
+		img.Pix[k*4+0] = byte(255 * r)
+		img.Pix[k*4+1] = byte(255 * g)
+		img.Pix[k*4+2] = byte(255 * b)
+		img.Pix[k*4+3] = 255
+	}
+	return img
+}
+
+// sampleStats summarises the sample budget a render used
+func (f *film) sampleStats() (total int, lo int, hi int) {
+	lo = math.MaxInt
+	for _, n := range f.samples {
+		total += n
+		lo = min(lo, n)
+		hi = max(hi, n)
+	}
+	return total, lo, hi
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// halfNoisy is a background that's flat grey on the left of the view and
+// fine black and white stripes on the right, far narrower than a pixel, so
+// samples there are a coin toss
+func halfNoisy(r ray, lambda float64) vec3 {
+	if r.dir.x < 0 {
+		return vec3{0.5, 0.5, 0.5}
+	}
+	if math.Sin(5000*r.dir.x) > 0 {
+		return vec3{1, 1, 1}
+	}
+	return vec3{}
+}
+
+func renderHalfNoisy(smp *sampler) *film {
+	s := &scene{background: halfNoisy}
+	integ := &integrator{shade: shadeDiffuse, maxDepth: 4}
+	return render(s, newCamera(16, 8), smp, integ)
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	smp := &sampler{workers: 2, seed: 1, adaptive: true, minSpp: 8, maxSpp: 64, batch: 8, threshold: 0.01}
+	f := renderHalfNoisy(smp)
+	for j := range f.height {
+		for i := range f.width {
+			n := f.samples[j*f.width+i]
+			switch {
+			case i < f.width/2-1 && n != smp.minSpp:
+				// every sample is the same, nothing to converge
+				t.Errorf("flat pixel %d,%d took %d samples, want %d", i, j, n, smp.minSpp)
+			case i > f.width/2 && n != smp.maxSpp:
+				// a coin toss has a standard error of 0.5/sqrt(n), never
+				// under the threshold this side of 2500 samples
+				t.Errorf("noisy pixel %d,%d took %d samples, want %d", i, j, n, smp.maxSpp)
+			}
+		}
+	}
+
+	total, lo, hi := f.sampleStats()
+	sum := 0
+	for _, n := range f.samples {
+		sum += n
+	}
+	if total != sum || lo != smp.minSpp || hi != smp.maxSpp {
+		t.Errorf("sample stats %d, %d to %d, want %d, %d to %d", total, lo, hi, sum, smp.minSpp, smp.maxSpp)
+	}
+
+	// the heatmap has the fewest in blue and the most in red
+	img := f.heatmap()
+	for k, n := range f.samples {
+		i, j := k%f.width, k/f.width
+		got := img.RGBAAt(i, j)
+		switch n {
+		case lo:
+			if got != (color.RGBA{0, 0, 255, 255}) {
+				t.Errorf("pixel %d,%d with %d samples is %v, want blue", i, j, n, got)
+			}
+		case hi:
+			if got != (color.RGBA{255, 0, 0, 255}) {
+				t.Errorf("pixel %d,%d with %d samples is %v, want red", i, j, n, got)
+			}
+		}
+	}
+}
+
+func TestFixedSampling(t *testing.T) {
+	// without adaptive sampling every pixel takes spp, noisy or not
+	f := renderHalfNoisy(&sampler{spp: 5, workers: 2, seed: 1})
+	for k, n := range f.samples {
+		if n != 5 {
+			t.Fatalf("pixel %d took %d samples, want 5", k, n)
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int
+		want    []color.RGBA
+	}{
+		{"ramp", []int{8, 12, 16, 20, 24}, []color.RGBA{
+			{0, 0, 255, 255},
+			{0, 127, 127, 255},
+			{0, 255, 0, 255},
+			{127, 127, 0, 255},
+			{255, 0, 0, 255},
+		}},
+		{"out of order", []int{24, 8, 16}, []color.RGBA{
+			{255, 0, 0, 255},
+			{0, 0, 255, 255},
+			{0, 255, 0, 255},
+		}},
+		// nothing to tell apart, all at the bottom of the scale
+		{"all the same", []int{32, 32}, []color.RGBA{
+			{0, 0, 255, 255},
+			{0, 0, 255, 255},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFilm(len(tt.samples), 1)
+			copy(f.samples, tt.samples)
+			img := f.heatmap()
+			for i, want := range tt.want {
+				if got := img.RGBAAt(i, 0); got != want {
+					t.Errorf("pixel %d with %d samples is %v, want %v", i, tt.samples[i], got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type scene struct {
+	objects hittableList
+	// background is what diffuse paths see when they miss everything, nil
+	// for the sky gradient
+	background func(r ray, lambda float64) vec3
+	sun        *sunLight // nil for none, otherwise sampled from diffuse hits
+}
+
+// hit finds the closest object in front of the ray
+func (s *scene) hit(r ray, rec *hitRecord) bool {
+	return s.objects.hit(r, 0.001, math.Inf(1), rec)
+}
+
+func defaultScene() *scene {
+	return &scene{
+		objects: hittableList{
+			&sphere{center: vec3{0, 0, -1}, radius: 0.5},
+		},
+	}
+}
+
+// shapesScene lines up every analytic primitive on a floor plane
+func shapesScene() *scene {
+	up := vec3{0, 1, 0}
+	return &scene{
+		objects: hittableList{
+			&sphere{center: vec3{0, 0, -1}, radius: 0.5},
+			&plane{point: vec3{0, -0.5, 0}, normal: up},
+			&cylinder{base: vec3{-1.3, -0.5, -1.5}, axis: up, radius: 0.35, height: 0.9},
+			&cone{base: vec3{1.3, -0.5, -1.5}, axis: up, radius: 0.4, height: 0.9},
+			&torus{center: vec3{-0.7, -0.35, -0.4}, axis: vec3{0, 1, 0.4}.unit(), major: 0.25, minor: 0.08},
+			&disk{center: vec3{0.7, -0.3, -0.4}, normal: vec3{0, 1, 1}.unit(), radius: 0.2},
+		},
+	}
+}
+
+// sceneOptions carries the flags that only some scenes use
+type sceneOptions struct {
+	normalMap string // image for the bump scene's sphere and quad
+}
+
+func sceneByName(name string, opts sceneOptions) (*scene, error) {
+	switch name {
+	case "default":
+		return defaultScene(), nil
+	case "shapes":
+		return shapesScene(), nil
+	case "sdf":
+		return sdfScene(), nil
+	case "csg":
+		return csgScene(), nil
+	case "bump":
+		var nm *normalMap
+		if opts.normalMap != "" {
+			var err error
+			if nm, err = loadNormalMap(opts.normalMap); err != nil {
+				return nil, fmt.Errorf("could not load normal map: %w", err)
+			}
+		}
+		return bumpScene(nm), nil
+	case "spheres":
+		return spheresScene(), nil
+	case "prism":
+		return prismScene(), nil
+	case "caustics":
+		return causticsScene(), nil
+	case "materials":
+		return materialsScene(), nil
+	}
+	return nil, fmt.Errorf("unknown scene %q (want default, shapes, sdf, csg, bump, spheres, prism, caustics or materials)", name)
+}
@@ -0,0 +1,162 @@
+package main
+
+import "math"
+
+// sdf is a signed distance field: distance from p to the nearest surface,
+// negative inside. the combinators below build shapes out of smaller ones
+type sdf func(p vec3) float64
+
+func sdSphere(center vec3, radius float64) sdf {
+	return func(p vec3) float64 {
+		return p.sub(center).length() - radius
+	}
+}
+
+// sdBox is an axis aligned box with half extents size
+func sdBox(center, size vec3) sdf {
+	return func(p vec3) float64 {
+		d := p.sub(center)
+		q := vec3{math.Abs(d.x) - size.x, math.Abs(d.y) - size.y, math.Abs(d.z) - size.z}
+		outside := vec3{math.Max(q.x, 0), math.Max(q.y, 0), math.Max(q.z, 0)}.length()
+		inside := math.Min(math.Max(q.x, math.Max(q.y, q.z)), 0)
+		return outside + inside
+	}
+}
+
+// sdTorus lies flat in the xz plane
+func sdTorus(center vec3, major, minor float64) sdf {
+	return func(p vec3) float64 {
+		d := p.sub(center)
+		ring := math.Hypot(d.x, d.z) - major
+		return math.Hypot(ring, d.y) - minor
+	}
+}
+
+func sdUnion(a, b sdf) sdf {
+	return func(p vec3) float64 {
+		return math.Min(a(p), b(p))
+	}
+}
+
+// sdSmoothUnion blends the two shapes together over a distance of about k
+func sdSmoothUnion(a, b sdf, k float64) sdf {
+	return func(p vec3) float64 {
+		da, db := a(p), b(p)
+		h := clamp(0.5+0.5*(db-da)/k, 0, 1)
+		return db*(1-h) + da*h - k*h*(1-h)
+	}
+}
+
+// sdSubtract carves b out of a
+func sdSubtract(a, b sdf) sdf {
+	return func(p vec3) float64 {
+		return math.Max(a(p), -b(p))
+	}
+}
+
+// sdRepeat tiles space with period along each axis, 0 leaves that axis alone
+func sdRepeat(f sdf, period vec3) sdf {
+	wrap := func(x, c float64) float64 {
+		if c == 0 {
+			return x
+		}
+		return x - c*math.Round(x/c)
+	}
+	return func(p vec3) float64 {
+		return f(vec3{wrap(p.x, period.x), wrap(p.y, period.y), wrap(p.z, period.z)})
+	}
+}
+
+// marcher sphere traces a distance field so it can sit in a scene next to the
+// analytic objects: the field guarantees nothing is closer than its value, so
+// the ray can safely step that far each iteration
+type marcher struct {
+	field    sdf
+	maxSteps int     // iteration budget per ray
+	epsilon  float64 // distance counted as touching the surface
+	maxDist  float64 // give up past this, so sky rays don't burn every step
+	bounds   aabb
+}
+
+func newMarcher(field sdf, bounds aabb) *marcher {
+	return &marcher{
+		field:    field,
+		maxSteps: 256,
+		epsilon:  1e-4,
+		maxDist:  100,
+		bounds:   bounds,
+	}
+}
+
+func (m *marcher) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	// march in world distance along the unit direction, t is in ray units
+	dirLen := r.dir.length()
+	dir := r.dir.div(dirLen)
+	limit := math.Min(tMax*dirLen, m.maxDist)
+
+	dist := tMin * dirLen
+	for range m.maxSteps {
+		if dist >= limit {
+			return false
+		}
+		p := r.orig.add(dir.mul(dist))
+		d := m.field(p)
+		if math.Abs(d) < m.epsilon {
+			t := dist / dirLen
+			if t <= tMin {
+				// started on the surface, push through it and keep going
+				dist += 2 * m.epsilon
+				continue
+			}
+			rec.t = t
+			rec.p = p
+			rec.setFaceNormal(r, m.normal(p))
+			rec.u, rec.v = 0, 0
+			return true
+		}
+		// abs so rays starting inside a shape still walk out to its surface
+		dist += math.Abs(d)
+	}
+	return false
+}
+
+// normal estimates the field gradient with the tetrahedron technique, four
+// samples instead of six for central differences
+func (m *marcher) normal(p vec3) vec3 {
+	h := m.epsilon
+	k1 := vec3{1, -1, -1}
+	k2 := vec3{-1, -1, 1}
+	k3 := vec3{-1, 1, -1}
+	k4 := vec3{1, 1, 1}
+	n := k1.mul(m.field(p.add(k1.mul(h)))).
+		add(k2.mul(m.field(p.add(k2.mul(h))))).
+		add(k3.mul(m.field(p.add(k3.mul(h))))).
+		add(k4.mul(m.field(p.add(k4.mul(h)))))
+	return n.unit()
+}
+
+func (m *marcher) boundingBox() aabb {
+	return m.bounds
+}
+
+// sdfScene shows off the combinators, on a floor of repeated spheres
+func sdfScene() *scene {
+	floor := sdRepeat(sdSphere(vec3{0, -0.65, 0}, 0.15), vec3{0.4, 0, 0.4})
+
+	blob := sdSmoothUnion(
+		sdSphere(vec3{-0.9, 0, -1.5}, 0.35),
+		sdBox(vec3{-0.45, -0.1, -1.5}, vec3{0.25, 0.25, 0.25}),
+		0.25,
+	)
+	carved := sdSubtract(
+		sdBox(vec3{0.7, -0.1, -1.5}, vec3{0.3, 0.3, 0.3}),
+		sdSphere(vec3{0.7, 0.15, -1.25}, 0.35),
+	)
+	ring := sdTorus(vec3{0, 0.45, -2}, 0.4, 0.1)
+
+	field := sdUnion(floor, sdUnion(blob, sdUnion(carved, ring)))
+
+	inf := math.Inf(1)
+	unbounded := aabb{vec3{-inf, -inf, -inf}, vec3{inf, inf, inf}}
+	return &scene{objects: hittableList{newMarcher(field, unbounded)}}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSDFDistances(t *testing.T) {
+	ball := sdSphere(vec3{1, 0, 0}, 0.5)
+	cube := sdBox(vec3{}, vec3{1, 1, 1})
+	tests := []struct {
+		name string
+		f    sdf
+		p    vec3
+		want float64
+	}{
+		{"sphere outside", ball, vec3{3, 0, 0}, 1.5},
+		{"sphere on", ball, vec3{1, 0.5, 0}, 0},
+		{"sphere inside", ball, vec3{1, 0, 0.25}, -0.25},
+		{"box face", cube, vec3{0, 3, 0}, 2},
+		{"box corner", cube, vec3{2, 2, 2}, math.Sqrt(3)},
+		{"box inside", cube, vec3{0.5, 0, 0.25}, -0.5},
+		{"torus tube", sdTorus(vec3{}, 2, 0.5), vec3{2, 1, 0}, 0.5},
+		{"torus hole", sdTorus(vec3{}, 2, 0.5), vec3{}, 1.5},
+		{"union", sdUnion(ball, cube), vec3{2.5, 0, 0}, 1},
+		{"subtract outside b", sdSubtract(cube, ball), vec3{-0.5, 0, 0}, -0.5},
+		// inside the ball is outside the carved cube, by how deep in the ball
+		{"subtract inside b", sdSubtract(cube, ball), vec3{1, 0, 0}, 0.5},
+		// far apart the smooth union is the plain one
+		{"smooth union far", sdSmoothUnion(ball, sdSphere(vec3{-5, 0, 0}, 0.5), 0.25), vec3{3, 0, 0}, 1.5},
+		// equally far from both it dips by k/4
+		{"smooth union between", sdSmoothUnion(sdSphere(vec3{-1, 0, 0}, 0.5), ball, 0.4), vec3{}, 0.4},
+		{"repeat", sdRepeat(ball, vec3{4, 0, 0}), vec3{9, 0, 0}, -0.5},
+		{"repeat leaves zero axes", sdRepeat(ball, vec3{4, 0, 0}), vec3{1, 6, 0}, 5.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f(tt.p); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("distance at %v is %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarcherSphere(t *testing.T) {
+	// a marched sphere lands where the analytic one does, with the same
+	// normal. it stops within epsilon of the surface, which is a little
+	// short along the ray, more so the more it glances
+	center, radius := vec3{0.3, -0.2, -3}, 0.8
+	ball := &sphere{center: center, radius: radius}
+	m := newMarcher(sdSphere(center, radius), ball.boundingBox())
+	tests := []struct {
+		name string
+		r    ray
+	}{
+		{"head on", ray{vec3{}, vec3{0, 0, -1}}},
+		{"at the center", ray{vec3{1, 1, 1}, center.sub(vec3{1, 1, 1})}},
+		{"glancing", ray{vec3{0.3 + 0.7, -0.2, 2}, vec3{0, 0, -1}}},
+		{"long direction", ray{vec3{}, vec3{0.2, -0.1, -7}}},
+		{"from inside", ray{center, vec3{1, 2, 0.5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, want hitRecord
+			if !ball.hit(tt.r, 0.001, math.Inf(1), &want) {
+				t.Fatal("the analytic sphere missed")
+			}
+			if !m.hit(tt.r, 0.001, math.Inf(1), &got) {
+				t.Fatal("missed")
+			}
+			if d := got.p.sub(center).length() - radius; math.Abs(d) > m.epsilon {
+				t.Errorf("hit %v from the surface", d)
+			}
+			// t is in ray units, epsilon in world units
+			if d := math.Abs(got.t-want.t) * tt.r.dir.length(); d > 10*m.epsilon {
+				t.Errorf("hit at t %v, want %v", got.t, want.t)
+			}
+			if got.normal.sub(want.normal).length() > 1e-3 || got.frontFace != want.frontFace {
+				t.Errorf("normal %v front %v, want %v front %v", got.normal, got.frontFace, want.normal, want.frontFace)
+			}
+		})
+	}
+}
+
+func TestMarcherMiss(t *testing.T) {
+	ball := newMarcher(sdSphere(vec3{0, 0, -3}, 0.8), aabb{})
+	tests := []struct {
+		name string
+		r    ray
+		tMax float64
+	}{
+		{"away", ray{vec3{}, vec3{0, 0, 1}}, math.Inf(1)},
+		{"beside", ray{vec3{0.81, 0, 0}, vec3{0, 0, -1}}, math.Inf(1)},
+		{"short of it", ray{vec3{}, vec3{0, 0, -1}}, 2},
+		// passing twice epsilon from the surface is still a miss
+		{"skimming", ray{vec3{0.8 + 2e-4, 0, 0}, vec3{0, 0, -1}}, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec hitRecord
+			if ball.hit(tt.r, 0.001, tt.tMax, &rec) {
+				t.Errorf("hit at t %v", rec.t)
+			}
+		})
+	}
+}
+
+func TestMarcherNormal(t *testing.T) {
+	tests := []struct {
+		name string
+		f    sdf
+		p    vec3
+		want vec3
+	}{
+		{"sphere", sdSphere(vec3{1, 1, 1}, 1), vec3{1, 1, 1}.add(vec3{1, 2, -2}.unit()), vec3{1, 2, -2}.unit()},
+		{"box top", sdBox(vec3{}, vec3{1, 1, 1}), vec3{0.3, 1, -0.4}, vec3{0, 1, 0}},
+		{"box side", sdBox(vec3{}, vec3{1, 2, 3}), vec3{-1, 0.5, 1}, vec3{-1, 0, 0}},
+		{"torus top", sdTorus(vec3{}, 2, 0.5), vec3{0, 0.5, 2}, vec3{0, 1, 0}},
+		{"torus outer edge", sdTorus(vec3{}, 2, 0.5), vec3{2.5, 0, 0}, vec3{1, 0, 0}},
+		// the floor of the hollow faces up, into the hole
+		{"carved", sdSubtract(sdBox(vec3{}, vec3{1, 1, 1}), sdSphere(vec3{0, 1, 0}, 0.5)), vec3{0, 0.5, 0}, vec3{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMarcher(tt.f, aabb{})
+			if got := m.normal(tt.p); got.sub(tt.want).length() > 1e-3 {
+				t.Errorf("normal %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"image/png"
+	"log"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// jobRequest is a render submitted to the server, the same knobs as the
+// command line flags
+type jobRequest struct {
+	Scene        string  `json:"scene"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Projection   string  `json:"projection"`
+	Spp          int     `json:"spp"`
+	Adaptive     bool    `json:"adaptive"`
+	MinSpp       int     `json:"min_spp"`
+	MaxSpp       int     `json:"max_spp"`
+	Threshold    float64 `json:"threshold"`
+	Seed         uint64  `json:"seed"`
+	Shade        string  `json:"shade"`
+	Depth        int     `json:"depth"`
+	Spectral     bool    `json:"spectral"`
+	Integrator   string  `json:"integrator"`
+	Photons      int     `json:"photons"`
+	Gather       int     `json:"gather"`
+	GatherRadius float64 `json:"gather_radius"`
+	Denoise      bool    `json:"denoise"`
+	Sky          string  `json:"sky"`
+	Turbidity    float64 `json:"turbidity"`
+	SunElevation float64 `json:"sun_elevation"`
+	SunAzimuth   float64 `json:"sun_azimuth"`
+	Time         string  `json:"time"`
+	Latitude     float64 `json:"latitude"`
+	Day          int     `json:"day"`
+}
+
+// defaultJobRequest matches the flag defaults, requests only need to set
+// what they change
+func defaultJobRequest() jobRequest {
+	return jobRequest{
+		Scene:        "default",
+		Width:        800,
+		Height:       450,
+		Projection:   "perspective",
+		Spp:          1,
+		MinSpp:       8,
+		MaxSpp:       256,
+		Threshold:    0.005,
+		Seed:         1,
+		Shade:        "normals",
+		Depth:        10,
+		Integrator:   "path",
+		Photons:      500000,
+		Gather:       64,
+		GatherRadius: 0.05,
+		Sky:          "gradient",
+		Turbidity:    3,
+		SunElevation: 35,
+		SunAzimuth:   160,
+		Latitude:     45,
+		Day:          172,
+	}
+}
+
+// limits on a single job, so one request can't tie up the box for days
+const (
+	maxJobPixels  = 4096 * 4096
+	maxJobSpp     = 1 << 16
+	maxJobDepth   = 64
+	maxJobPhotons = 1 << 22 // about 300MB of photon map
+	maxJobGather  = 1024
+
+	// most pixels waiting in the queue at once, across every job
+	maxQueuedPixels = 4 * maxJobPixels
+	// how long a finished job and its image are kept before being dropped
+	defaultRetain = 10 * time.Minute
+)
+
+// errBusy is a submit turned away for want of room in the queue, not for
+// anything wrong with the request
+var errBusy = errors.New("too many jobs queued, try again later")
+
+// setup turns a request into what render needs
+func (req *jobRequest) setup(workers int) (*scene, *camera, *sampler, *integrator, error) {
+	// each side on its own first, so the product can't overflow
+	if req.Width < 1 || req.Height < 1 || req.Width > maxJobPixels || req.Height > maxJobPixels || req.Width*req.Height > maxJobPixels {
+		return nil, nil, nil, nil, fmt.Errorf("size %dx%d out of range", req.Width, req.Height)
+	}
+	if req.Spp > maxJobSpp || req.MinSpp > maxJobSpp || req.MaxSpp > maxJobSpp {
+		return nil, nil, nil, nil, fmt.Errorf("at most %d samples per pixel", maxJobSpp)
+	}
+	if req.Depth > maxJobDepth {
+		return nil, nil, nil, nil, fmt.Errorf("depth at most %d", maxJobDepth)
+	}
+	if req.Photons > maxJobPhotons || req.Gather > maxJobGather {
+		return nil, nil, nil, nil, fmt.Errorf("at most %d photons and %d gathered", maxJobPhotons, maxJobGather)
+	}
+	s, err := sceneByName(req.Scene, sceneOptions{})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	sky := daylight{
+		sky:       req.Sky,
+		turbidity: req.Turbidity,
+		elevation: req.SunElevation,
+		azimuth:   req.SunAzimuth,
+		clock:     req.Time,
+		latitude:  req.Latitude,
+		day:       req.Day,
+	}
+	if err := sky.apply(s); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	proj, err := parseProjection(req.Projection)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	shade, err := parseShadeMode(req.Shade)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	method, err := parseIntegration(req.Integrator)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if req.Depth < 1 {
+		return nil, nil, nil, nil, errors.New("depth must be at least 1")
+	}
+	if req.Spectral && shade != shadeDiffuse {
+		return nil, nil, nil, nil, errors.New("spectral needs diffuse shading")
+	}
+	if method == integratePhoton && (shade != shadeDiffuse || req.Photons < 1 || req.Gather < 1 || req.GatherRadius <= 0) {
+		return nil, nil, nil, nil, errors.New("photon integrator needs diffuse shading, and photons, gather and gather_radius above 0")
+	}
+
+	cam := newCamera(req.Width, req.Height)
+	cam.projection = proj
+	smp := &sampler{
+		spp:       req.Spp,
+		workers:   workers,
+		seed:      req.Seed,
+		adaptive:  req.Adaptive,
+		minSpp:    req.MinSpp,
+		maxSpp:    req.MaxSpp,
+		batch:     8,
+		threshold: req.Threshold,
+	}
+	if err := smp.validate(); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	integ := &integrator{
+		shade:        shade,
+		maxDepth:     req.Depth,
+		spectral:     req.Spectral,
+		method:       method,
+		photons:      req.Photons,
+		gather:       req.Gather,
+		gatherRadius: req.GatherRadius,
+	}
+	return s, cam, smp, integ, nil
+}
+
+type jobState string
+
+const (
+	jobQueued    jobState = "queued"
+	jobRunning   jobState = "running"
+	jobDone      jobState = "done"
+	jobCancelled jobState = "cancelled"
+)
+
+// job is one submitted render. the server's lock guards everything but the
+// film's pixels, which have their own. the film is only made once the job
+// starts, so queued jobs cost next to nothing
+type job struct {
+	id        int
+	req       jobRequest
+	state     jobState
+	film      *film // nil until the job runs
+	report    *renderReport
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// what the job needs to run, checked at submit time and dropped once
+	// it's over
+	scene *scene
+	cam   *camera
+	smp   *sampler
+	integ *integrator
+}
+
+// jobStatus is what the server reports about a job
+type jobStatus struct {
+	ID        int           `json:"id"`
+	State     jobState      `json:"state"`
+	Request   jobRequest    `json:"request"`
+	Progress  float64       `json:"progress"` // rows rendered over the height
+	Rows      int           `json:"rows"`
+	Height    int           `json:"height"`
+	Submitted time.Time     `json:"submitted"`
+	Seconds   float64       `json:"seconds"` // running time so far
+	Report    *renderReport `json:"report,omitempty"`
+}
+
+// renderServer runs submitted renders one at a time, each with every worker.
+// finished jobs are forgotten, image and all, retain after they end
+type renderServer struct {
+	mu      sync.Mutex
+	jobs    map[int]*job
+	order   []int // ids in submission order, for listing
+	nextID  int
+	queue   chan *job
+	workers int
+	retain  time.Duration
+
+	queuedPixels    int // pixels of the jobs waiting to start
+	maxQueuedPixels int
+}
+
+func newRenderServer(workers int, retain time.Duration) *renderServer {
+	rs := &renderServer{
+		jobs:            map[int]*job{},
+		nextID:          1,
+		queue:           make(chan *job, 256),
+		workers:         workers,
+		retain:          retain,
+		maxQueuedPixels: maxQueuedPixels,
+	}
+	go rs.run()
+	return rs
+}
+
+func (j *job) pixels() int {
+	return j.req.Width * j.req.Height
+}
+
+// finish ends a job and schedules it to be dropped, call with rs.mu held
+func (rs *renderServer) finish(j *job, state jobState) {
+	j.state = state
+	j.finished = time.Now()
+	j.scene, j.cam, j.smp, j.integ = nil, nil, nil, nil
+	j.cancel()
+	time.AfterFunc(rs.retain, func() {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		delete(rs.jobs, j.id)
+		if k := slices.Index(rs.order, j.id); k >= 0 {
+			rs.order = slices.Delete(rs.order, k, k+1)
+		}
+	})
+}
+
+func (rs *renderServer) run() {
+	for j := range rs.queue {
+		rs.mu.Lock()
+		if j.state != jobQueued { // cancelled while it waited
+			rs.mu.Unlock()
+			continue
+		}
+		j.state = jobRunning
+		j.started = time.Now()
+		rs.queuedPixels -= j.pixels()
+		j.film = newFilm(j.cam.width, j.cam.height)
+		rs.mu.Unlock()
+
+		err := renderInto(j.ctx, j.film, j.scene, j.cam, j.smp, j.integ)
+		if err == nil && j.req.Denoise {
+			dn := newDenoiser()
+			j.film.mu.Lock()
+			j.film.color = dn.denoise(j.film.width, j.film.height, j.film.color, j.film.normal, j.film.albedo)
+			j.film.mu.Unlock()
+		}
+
+		rs.mu.Lock()
+		if err != nil {
+			rs.finish(j, jobCancelled)
+		} else {
+			j.report = j.film.report()
+			rs.finish(j, jobDone)
+		}
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *renderServer) submit(req jobRequest) (*job, error) {
+	s, cam, smp, integ, err := req.setup(rs.workers)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		req:       req,
+		state:     jobQueued,
+		submitted: time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		scene:     s,
+		cam:       cam,
+		smp:       smp,
+		integ:     integ,
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.queuedPixels+j.pixels() > rs.maxQueuedPixels {
+		cancel()
+		return nil, errBusy
+	}
+	select {
+	case rs.queue <- j:
+	default:
+		cancel()
+		return nil, errBusy
+	}
+	rs.queuedPixels += j.pixels()
+	j.id = rs.nextID
+	rs.nextID++
+	rs.jobs[j.id] = j
+	rs.order = append(rs.order, j.id)
+	return j, nil
+}
+
+// status snapshots a job, call with rs.mu held
+func (rs *renderServer) status(j *job) jobStatus {
+	rows := 0
+	if j.film != nil {
+		j.film.mu.Lock()
+		rows = j.film.rowsDone
+		j.film.mu.Unlock()
+	}
+
+	st := jobStatus{
+		ID:        j.id,
+		State:     j.state,
+		Request:   j.req,
+		Progress:  float64(rows) / float64(j.req.Height),
+		Rows:      rows,
+		Height:    j.req.Height,
+		Submitted: j.submitted,
+		Report:    j.report,
+	}
+	switch {
+	case !j.finished.IsZero() && !j.started.IsZero():
+		st.Seconds = j.finished.Sub(j.started).Seconds()
+	case !j.started.IsZero():
+		st.Seconds = time.Since(j.started).Seconds()
+	}
+	return st
+}
+
+// lookup finds the job named by the request's {id}
+func (rs *renderServer) lookup(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		rs.mu.Lock()
+		j, ok := rs.jobs[id]
+		rs.mu.Unlock()
+		if ok {
+			return j, true
+		}
+	}
+	http.Error(w, "no such job", http.StatusNotFound)
+	return nil, false
+}
+
+func writeJSONResponse(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+func (rs *renderServer) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		var jobs []jobStatus
+		for k := len(rs.order) - 1; k >= 0; k-- {
+			jobs = append(jobs, rs.status(rs.jobs[rs.order[k]]))
+		}
+		rs.mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := indexPage.Execute(w, jobs); err != nil {
+			log.Printf("could not write index: %v", err)
+		}
+	})
+
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		req := defaultJobRequest()
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, "bad job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		j, err := rs.submit(req)
+		if errors.Is(err, errBusy) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rs.mu.Lock()
+		st := rs.status(j)
+		rs.mu.Unlock()
+		w.Header().Set("Location", fmt.Sprintf("/jobs/%d", j.id))
+		writeJSONResponse(w, http.StatusCreated, st)
+	})
+
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		jobs := []jobStatus{}
+		for _, id := range rs.order {
+			jobs = append(jobs, rs.status(rs.jobs[id]))
+		}
+		rs.mu.Unlock()
+		writeJSONResponse(w, http.StatusOK, jobs)
+	})
+
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := rs.lookup(w, r)
+		if !ok {
+			return
+		}
+		rs.mu.Lock()
+		st := rs.status(j)
+		rs.mu.Unlock()
+		writeJSONResponse(w, http.StatusOK, st)
+	})
+
+	cancel := func(w http.ResponseWriter, r *http.Request) {
+		j, ok := rs.lookup(w, r)
+		if !ok {
+			return
+		}
+		rs.mu.Lock()
+		switch j.state {
+		case jobQueued:
+			// never started, the runner skips it when it comes up
+			rs.queuedPixels -= j.pixels()
+			rs.finish(j, jobCancelled)
+		case jobRunning:
+			// the runner marks it cancelled once the workers stop
+			j.cancel()
+		}
+		st := rs.status(j)
+		rs.mu.Unlock()
+		writeJSONResponse(w, http.StatusOK, st)
+	}
+	mux.HandleFunc("POST /jobs/{id}/cancel", cancel)
+	mux.HandleFunc("DELETE /jobs/{id}", cancel)
+
+	mux.HandleFunc("GET /jobs/{id}/image.png", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := rs.lookup(w, r)
+		if !ok {
+			return
+		}
+		rs.mu.Lock()
+		f := j.film
+		rs.mu.Unlock()
+		if f == nil {
+			http.Error(w, "job hasn't started", http.StatusNotFound)
+			return
+		}
+		// rows not rendered yet are black
+		f.mu.Lock()
+		img := f.image()
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := png.Encode(w, img); err != nil {
+			log.Printf("could not write png: %v", err)
+		}
+	})
+
+	mux.HandleFunc("GET /jobs/{id}/view", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := rs.lookup(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := viewPage.Execute(w, j.id); err != nil {
+			log.Printf("could not write view: %v", err)
+		}
+	})
+
+	return mux
+}
+
+var indexPage = template.Must(template.New("index").Funcs(template.FuncMap{
+	"percent": func(p float64) float64 { return p * 100 },
+}).Parse(`<!doctype html>
+<title>render server</title>
+<h1>render server</h1>
+<form id="submit">
+<textarea name="job" rows="6" cols="60">{"scene": "shapes", "shade": "diffuse", "spp": 64}</textarea><br>
+<button>render</button> <span id="err"></span>
+</form>
+<ul>
+{{range .}}<li><a href="/jobs/{{.ID}}/view">job {{.ID}}</a>: {{.Request.Scene}}, {{.State}}, {{printf "%.0f" (.Progress | percent)}}%</li>
+{{end}}</ul>
+<script>
+document.getElementById("submit").onsubmit = async (e) => {
+	e.preventDefault();
+	const res = await fetch("/jobs", {method: "POST", body: e.target.job.value});
+	if (!res.ok) {
+		document.getElementById("err").textContent = await res.text();
+		return;
+	}
+	location = "/jobs/" + (await res.json()).id + "/view";
+};
+</script>
+`))
+
+// viewPage reloads the image and status every second until the job ends
+var viewPage = template.Must(template.New("view").Parse(`<!doctype html>
+<title>job {{.}}</title>
+<p><a href="/">all jobs</a> · job {{.}}: <span id="status">…</span> <button id="cancel">cancel</button></p>
+<img id="img" src="/jobs/{{.}}/image.png">
+<script>
+const id = {{.}};
+document.getElementById("cancel").onclick = () => fetch("/jobs/" + id + "/cancel", {method: "POST"});
+async function poll() {
+	const st = await (await fetch("/jobs/" + id)).json();
+	document.getElementById("status").textContent =
+		st.state + ", " + Math.round(st.progress * 100) + "%, " + st.seconds.toFixed(1) + "s";
+	document.getElementById("img").src = "/jobs/" + id + "/image.png?" + Date.now();
+	if (st.state === "queued" || st.state === "running") {
+		setTimeout(poll, 1000);
+	}
+}
+poll();
+</script>
+`))
+
+// serveCommand is `gradient-2 serve`: a render server for a shared machine,
+// with a web page to submit jobs and watch them fill in
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	workers := fs.Int("workers", runtime.NumCPU(), "render goroutines per job")
+	retain := fs.Duration("retain", defaultRetain, "how long finished jobs and their images are kept")
+	fs.Parse(args)
+
+	rs := newRenderServer(max(*workers, 1), *retain)
+	log.Printf("render server listening on http://%s", *addr)
+	return http.ListenAndServe(*addr, rs.handler())
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, retain time.Duration) (*renderServer, *httptest.Server) {
+	t.Helper()
+	rs := newRenderServer(2, retain)
+	srv := httptest.NewServer(rs.handler())
+	t.Cleanup(srv.Close)
+	return rs, srv
+}
+
+// post sends body to path and decodes a json reply into out, if any
+func post(t *testing.T, srv *httptest.Server, path, body string, out any) *http.Response {
+	t.Helper()
+	res, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+func getStatus(t *testing.T, srv *httptest.Server, id int) (jobStatus, int) {
+	t.Helper()
+	res, err := http.Get(fmt.Sprintf("%s/jobs/%d", srv.URL, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var st jobStatus
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st, res.StatusCode
+}
+
+// waitFor polls a job until it's in one of states
+func waitFor(t *testing.T, srv *httptest.Server, id int, states ...jobState) jobStatus {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		st, code := getStatus(t, srv, id)
+		if code != http.StatusOK {
+			t.Fatalf("job %d: status %d", id, code)
+		}
+		for _, s := range states {
+			if st.State == s {
+				return st
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d never got to %v", id, states)
+	return jobStatus{}
+}
+
+// slowJob renders long enough to be caught running, in rows that finish
+// quickly so a cancel lands soon
+const slowJob = `{"width": 4, "height": 400, "shade": "diffuse", "spp": 4096}`
+
+func TestServerSubmit(t *testing.T) {
+	_, srv := newTestServer(t, time.Minute)
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"defaults", `{"width": 8, "height": 4}`, http.StatusCreated},
+		{"diffuse", `{"scene": "shapes", "width": 8, "height": 4, "shade": "diffuse", "spp": 2}`, http.StatusCreated},
+		{"not json", `{"width": `, http.StatusBadRequest},
+		{"unknown field", `{"widht": 8}`, http.StatusBadRequest},
+		{"unknown scene", `{"scene": "nope"}`, http.StatusBadRequest},
+		{"too big", `{"width": 8192, "height": 8192}`, http.StatusBadRequest},
+		{"too many samples", `{"spp": 1000000}`, http.StatusBadRequest},
+		{"too many adaptive samples", `{"adaptive": true, "min_spp": 1000000, "max_spp": 2000000}`, http.StatusBadRequest},
+		{"most samples", `{"width": 8, "height": 4, "spp": 65536, "depth": 64}`, http.StatusCreated},
+		{"overflowing size", `{"width": 4294967296, "height": 4294967296}`, http.StatusBadRequest},
+		{"too deep", `{"shade": "diffuse", "depth": 100000}`, http.StatusBadRequest},
+		{"no depth", `{"shade": "diffuse", "depth": 0}`, http.StatusBadRequest},
+		{"too many photons", `{"shade": "diffuse", "integrator": "photon", "photons": 1000000000}`, http.StatusBadRequest},
+		{"too many gathered", `{"shade": "diffuse", "integrator": "photon", "gather": 100000}`, http.StatusBadRequest},
+		{"spectral normals", `{"spectral": true}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var st jobStatus
+			res := post(t, srv, "/jobs", tt.body, &st)
+			if res.StatusCode != tt.code {
+				t.Fatalf("status %d, want %d", res.StatusCode, tt.code)
+			}
+			if tt.code != http.StatusCreated {
+				return
+			}
+			if want := fmt.Sprintf("/jobs/%d", st.ID); res.Header.Get("Location") != want {
+				t.Errorf("location %q, want %q", res.Header.Get("Location"), want)
+			}
+			if st.Height != 4 || st.Request.Width != 8 {
+				t.Errorf("status %+v doesn't match the request", st)
+			}
+		})
+	}
+}
+
+func TestServerProgress(t *testing.T) {
+	_, srv := newTestServer(t, time.Minute)
+	var st jobStatus
+	if res := post(t, srv, "/jobs", `{"width": 16, "height": 9, "spp": 4}`, &st); res.StatusCode != http.StatusCreated {
+		t.Fatalf("submit: status %d", res.StatusCode)
+	}
+	if st.State != jobQueued && st.State != jobRunning {
+		t.Errorf("new job is %s", st.State)
+	}
+	st = waitFor(t, srv, st.ID, jobDone)
+	if st.Progress != 1 || st.Rows != 9 || st.Height != 9 {
+		t.Errorf("finished job at %v, %d of %d rows", st.Progress, st.Rows, st.Height)
+	}
+	if st.Report == nil {
+		t.Error("finished job has no report")
+	}
+
+	res, err := http.Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var all []jobStatus
+	if err := json.NewDecoder(res.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != st.ID {
+		t.Errorf("job list %+v", all)
+	}
+
+	if _, code := getStatus(t, srv, 99); code != http.StatusNotFound {
+		t.Errorf("unknown job: status %d", code)
+	}
+}
+
+func TestServerImage(t *testing.T) {
+	_, srv := newTestServer(t, time.Minute)
+	var st jobStatus
+	post(t, srv, "/jobs", `{"width": 16, "height": 9}`, &st)
+	waitFor(t, srv, st.ID, jobDone)
+
+	res, err := http.Get(fmt.Sprintf("%s/jobs/%d/image.png", srv.URL, st.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("status %d, content type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	img, err := png.Decode(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 9 {
+		t.Errorf("image is %v", b)
+	}
+	// normal shading, the sphere in the middle isn't black
+	if r, g, b, _ := img.At(8, 4).RGBA(); r+g+b == 0 {
+		t.Error("image is black")
+	}
+}
+
+func TestServerCancel(t *testing.T) {
+	_, srv := newTestServer(t, time.Minute)
+	var running, queued jobStatus
+	post(t, srv, "/jobs", slowJob, &running)
+	post(t, srv, "/jobs", slowJob, &queued)
+	waitFor(t, srv, running.ID, jobRunning)
+
+	// a queued job is cancelled on the spot, and has no image to show
+	var st jobStatus
+	if res := post(t, srv, fmt.Sprintf("/jobs/%d/cancel", queued.ID), "", &st); res.StatusCode != http.StatusOK {
+		t.Fatalf("cancel queued: status %d", res.StatusCode)
+	}
+	if st.State != jobCancelled || st.Rows != 0 {
+		t.Errorf("cancelled queued job is %s with %d rows", st.State, st.Rows)
+	}
+	res, err := http.Get(fmt.Sprintf("%s/jobs/%d/image.png", srv.URL, queued.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("image of a job that never ran: status %d", res.StatusCode)
+	}
+
+	// a running one stops between rows, keeping what it rendered
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/jobs/%d", srv.URL, running.ID), nil)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	st = waitFor(t, srv, running.ID, jobCancelled, jobDone)
+	if st.State != jobCancelled || st.Rows == st.Height {
+		t.Errorf("cancelled running job is %s with %d of %d rows", st.State, st.Rows, st.Height)
+	}
+	res, err = http.Get(fmt.Sprintf("%s/jobs/%d/image.png", srv.URL, running.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("image of a cancelled job: status %d", res.StatusCode)
+	}
+
+	// cancelling again changes nothing
+	if res := post(t, srv, fmt.Sprintf("/jobs/%d/cancel", queued.ID), "", &st); res.StatusCode != http.StatusOK || st.State != jobCancelled {
+		t.Errorf("second cancel: status %d, %s", res.StatusCode, st.State)
+	}
+}
+
+func TestServerQueueLimit(t *testing.T) {
+	rs, srv := newTestServer(t, time.Minute)
+	rs.maxQueuedPixels = 2 * 4 * 400
+
+	var first jobStatus
+	post(t, srv, "/jobs", slowJob, &first)
+	waitFor(t, srv, first.ID, jobRunning)
+
+	// the running job doesn't count, two more fit in the queue
+	var ids []int
+	for range 2 {
+		var st jobStatus
+		if res := post(t, srv, "/jobs", slowJob, &st); res.StatusCode != http.StatusCreated {
+			t.Fatalf("submit: status %d", res.StatusCode)
+		}
+		ids = append(ids, st.ID)
+	}
+	if res := post(t, srv, "/jobs", slowJob, nil); res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("submit over the limit: status %d", res.StatusCode)
+	}
+	// cancelling a queued job gives its room back
+	post(t, srv, fmt.Sprintf("/jobs/%d/cancel", ids[0]), "", nil)
+	if res := post(t, srv, "/jobs", slowJob, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("submit after a cancel: status %d", res.StatusCode)
+	}
+	rs.mu.Lock()
+	queuedPixels := rs.queuedPixels
+	rs.mu.Unlock()
+	if queuedPixels != 2*4*400 {
+		t.Errorf("%d pixels queued, want %d", queuedPixels, 2*4*400)
+	}
+
+	// clean up so the runner isn't left rendering
+	for id := range 10 {
+		post(t, srv, fmt.Sprintf("/jobs/%d/cancel", id+1), "", nil)
+	}
+}
+
+func TestServerRetain(t *testing.T) {
+	rs, srv := newTestServer(t, 50*time.Millisecond)
+	var st jobStatus
+	post(t, srv, "/jobs", `{"width": 8, "height": 4}`, &st)
+	waitFor(t, srv, st.ID, jobDone)
+
+	// finished jobs go, image and all, once their time is up
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, code := getStatus(t, srv, st.ID); code == http.StatusNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("finished job was never dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.jobs) != 0 || len(rs.order) != 0 {
+		t.Errorf("%d jobs and %d ids left", len(rs.jobs), len(rs.order))
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// skyScale brings the sky's kcd/m^2 down to the 0..1 the film displays
+const skyScale = 0.04
+
+// perez is the perez et al. sky luminance distribution
+// F(theta, gamma) = (1 + A e^(B/cos theta)) (1 + C e^(D gamma) + E cos^2 gamma)
+// with theta the view's angle from the zenith and gamma its angle to the sun
+type perez [5]float64
+
+func (p perez) at(cosTheta, gamma, cosGamma float64) float64 {
+	return (1 + p[0]*math.Exp(p[1]/cosTheta)) * (1 + p[2]*math.Exp(p[3]*gamma) + p[4]*cosGamma*cosGamma)
+}
+
+// preethamSky is the analytic daylight model of preetham, shirley and smits
+// 1999: the perez distribution for luminance and both chromaticities, fitted
+// against turbidity, scaled so the zenith matches their zenith formulas
+type preethamSky struct {
+	sunDir     vec3
+	thetaS     float64 // sun's angle from the zenith
+	turbidity  float64 // haze: 2 is very clear, 10 is thick
+	lumY, x, y perez
+	zenith     vec3 // luminance Y and chromaticity x, y straight up
+	norm       vec3 // F(0, thetaS) for each, the zenith's own distribution value
+}
+
+func newPreethamSky(sunDir vec3, turbidity float64) *preethamSky {
+	t := turbidity
+	s := &preethamSky{sunDir: sunDir.unit(), turbidity: t}
+	s.thetaS = math.Acos(clamp(s.sunDir.y, -1, 1))
+	// the fits don't go below the horizon, hold the sun on it
+	th := min(s.thetaS, math.Pi/2)
+
+	s.lumY = perez{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703}
+	s.x = perez{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452}
+	s.y = perez{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529}
+
+	chi := (4.0/9.0 - t/120) * (math.Pi - 2*th)
+	th2, th3 := th*th, th*th*th
+	s.zenith = vec3{
+		(4.0453*t-4.9710)*math.Tan(chi) - 0.2155*t + 2.4192,
+		t*t*(0.00166*th3-0.00375*th2+0.00209*th) + t*(-0.02903*th3+0.06377*th2-0.03202*th+0.00394) + (0.11693*th3 - 0.21196*th2 + 0.06052*th + 0.25886),
+		t*t*(0.00275*th3-0.00610*th2+0.00317*th) + t*(-0.04214*th3+0.08970*th2-0.04153*th+0.00516) + (0.15346*th3 - 0.26756*th2 + 0.06670*th + 0.26688),
+	}
+	cosS := math.Cos(th)
+	s.norm = vec3{s.lumY.at(1, th, cosS), s.x.at(1, th, cosS), s.y.at(1, th, cosS)}
+	return s
+}
+
+// radiance is the sky's linear rgb looking along dir, without the sun's disc
+func (s *preethamSky) radiance(dir vec3) vec3 {
+	dir = dir.unit()
+	// below the horizon repeat the horizon, the model has nothing there
+	cosTheta := max(dir.y, 0.01)
+	cosGamma := clamp(dir.dot(s.sunDir), -1, 1)
+	gamma := math.Acos(cosGamma)
+
+	lum := s.zenith.x * s.lumY.at(cosTheta, gamma, cosGamma) / s.norm.x
+	x := s.zenith.y * s.x.at(cosTheta, gamma, cosGamma) / s.norm.y
+	y := s.zenith.z * s.y.at(cosTheta, gamma, cosGamma) / s.norm.z
+
+	// Yxy to XYZ
+	xyz := vec3{x / y * lum, lum, (1 - x - y) / y * lum}
+	rgb := xyzToRGB(xyz).mul(skyScale)
+	return vec3{max(rgb.x, 0), max(rgb.y, 0), max(rgb.z, 0)}
+}
+
+// background is the sky as a scene background
+func (s *preethamSky) background(r ray, lambda float64) vec3 {
+	c := s.radiance(r.dir)
+	if lambda == 0 {
+		return c
+	}
+	return spectrumOfRGB(c, lambda)
+}
+
+// primary spectra. any rgb is a mix of them with its own components as the
+// weights, which is linear and cheap enough to do for every sky ray, where
+// fitting each color isn't
+var primaries = [3]spectralColor{
+	newSpectralColor(vec3{1, 0, 0}),
+	newSpectralColor(vec3{0, 1, 0}),
+	newSpectralColor(vec3{0, 0, 1}),
+}
+
+func spectrumOfRGB(c vec3, lambda float64) vec3 {
+	v := c.x*primaries[0].eval(lambda).x + c.y*primaries[1].eval(lambda).x + c.z*primaries[2].eval(lambda).x
+	return vec3{v, v, v}
+}
+
+// sunLight is the sun as a small disc of light in the sky, which diffuse
+// surfaces sample directly rather than waiting for paths to find it
+type sunLight struct {
+	dir    vec3
+	cosMax float64 // cosine of the disc's angular radius
+	thetaS float64
+	tau    func(lambda float64) float64 // optical depth of the air above
+	rgb    vec3                         // radiance through the air, for rgb paths
+}
+
+// sun's angular radius and its radiance above the atmosphere, in the sky's
+// kcd/m^2 before skyScale, taken flat across the visible range
+const (
+	sunRadius   = 0.00465
+	sunRadiance = 2e6
+)
+
+func newSunLight(dir vec3, turbidity float64) *sunLight {
+	sun := &sunLight{dir: dir.unit(), cosMax: math.Cos(sunRadius)}
+	sun.thetaS = math.Acos(clamp(sun.dir.y, -1, 1))
+
+	// rayleigh scattering by the air and angstrom's aerosol haze, over the
+	// kasten and young air mass. preetham et al. leave out ozone and water too
+	deg := sun.thetaS * 180 / math.Pi
+	mass := 1 / (math.Cos(sun.thetaS) + 0.15*math.Pow(93.885-deg, -1.253))
+	beta := 0.04608*turbidity - 0.04586
+	sun.tau = func(lambda float64) float64 {
+		um := lambda / 1000
+		return mass * (0.008735*math.Pow(um, -4.08) + beta*math.Pow(um, -1.3))
+	}
+
+	if deg < 93.885 {
+		const step = 5.0
+		var xyz vec3
+		for l := lambdaMin + step/2; l < lambdaMax; l += step {
+			xyz = xyz.add(cieXYZ(l).mul(sun.spectral(l) * step))
+		}
+		rgb := xyzToRGB(xyz)
+		sun.rgb = vec3{rgb.x / spectralWhite.x, rgb.y / spectralWhite.y, rgb.z / spectralWhite.z}
+		sun.rgb = vec3{max(sun.rgb.x, 0), max(sun.rgb.y, 0), max(sun.rgb.z, 0)}
+	}
+	return sun
+}
+
+func (sun *sunLight) spectral(lambda float64) float64 {
+	if sun.thetaS*180/math.Pi >= 93.885 {
+		return 0 // set, the air mass formula blows up past here
+	}
+	return sunRadiance * skyScale * math.Exp(-sun.tau(lambda))
+}
+
+// radiance is the disc's radiance for an rgb or spectral path
+func (sun *sunLight) radiance(lambda float64) vec3 {
+	if lambda == 0 {
+		return sun.rgb
+	}
+	v := sun.spectral(lambda)
+	return vec3{v, v, v}
+}
+
+// visible is whether a direction looks into the disc
+func (sun *sunLight) visible(dir vec3) bool {
+	return dir.unit().dot(sun.dir) >= sun.cosMax
+}
+
+// solidAngle is the disc's size as seen from the ground
+func (sun *sunLight) solidAngle() float64 {
+	return 2 * math.Pi * (1 - sun.cosMax)
+}
+
+// sample picks a direction uniformly over the disc
+func (sun *sunLight) sample(rng *rand.Rand) vec3 {
+	cosT := 1 - rng.Float64()*(1-sun.cosMax)
+	sinT := math.Sqrt(1 - cosT*cosT)
+	phi := 2 * math.Pi * rng.Float64()
+	t, b := tangentBasis(sun.dir)
+	return t.mul(sinT * math.Cos(phi)).add(b.mul(sinT * math.Sin(phi))).add(sun.dir.mul(cosT))
+}
+
+// sunDirection turns a compass bearing and elevation in degrees into a
+// direction. -z is north and +x east, so scenes look north by default
+func sunDirection(elevation, azimuth float64) vec3 {
+	el := elevation * math.Pi / 180
+	az := azimuth * math.Pi / 180
+	return vec3{math.Sin(az) * math.Cos(el), math.Sin(el), -math.Cos(az) * math.Cos(el)}
+}
+
+// solarPosition is the sun's elevation and bearing in degrees at a local
+// solar time in hours, a latitude in degrees and a day of the year. the
+// declination is the usual cosine approximation, good to about a degree
+func solarPosition(hour, latitude float64, day int) (elevation, azimuth float64) {
+	rad := math.Pi / 180
+	decl := -23.44 * rad * math.Cos(2*math.Pi/365*float64(day+10))
+	lat := latitude * rad
+	hourAngle := 15 * rad * (hour - 12)
+
+	sinEl := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(hourAngle)
+	el := math.Asin(clamp(sinEl, -1, 1))
+	cosAz := (math.Sin(decl) - math.Sin(el)*math.Sin(lat)) / (math.Cos(el) * math.Cos(lat))
+	az := math.Acos(clamp(cosAz, -1, 1))
+	if hourAngle > 0 {
+		az = 2*math.Pi - az // afternoon, the sun has crossed to the west
+	}
+	return el / rad, az / rad
+}
+
+// parseClock reads a local solar time like 15:30 as hours
+func parseClock(s string) (float64, error) {
+	hs, ms, _ := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hs)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("bad time %q (want hh:mm)", s)
+	}
+	m := 0
+	if ms != "" {
+		if m, err = strconv.Atoi(ms); err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("bad time %q (want hh:mm)", s)
+		}
+	}
+	return float64(h) + float64(m)/60, nil
+}
+
+// daylight is the sky settings shared by the flags and render server jobs
+type daylight struct {
+	sky       string // gradient or preetham
+	turbidity float64
+	elevation float64 // degrees, used when clock is empty
+	azimuth   float64 // compass bearing in degrees
+	clock     string  // local solar time as hh:mm, placing the sun by date
+	latitude  float64
+	day       int // of the year, 1 to 365
+}
+
+// apply sets the scene's sky, leaving the gradient alone
+func (d daylight) apply(s *scene) error {
+	switch d.sky {
+	case "gradient":
+		return nil
+	case "preetham":
+	default:
+		return fmt.Errorf("unknown sky %q (want gradient or preetham)", d.sky)
+	}
+	if d.turbidity < 2 || d.turbidity > 10 {
+		return fmt.Errorf("turbidity %g outside the model's 2 to 10", d.turbidity)
+	}
+	el, az := d.elevation, d.azimuth
+	if d.clock != "" {
+		hour, err := parseClock(d.clock)
+		if err != nil {
+			return err
+		}
+		if d.day < 1 || d.day > 365 {
+			return fmt.Errorf("day %d outside 1 to 365", d.day)
+		}
+		el, az = solarPosition(hour, d.latitude, d.day)
+	}
+	s.useDaylight(sunDirection(el, az), d.turbidity)
+	return nil
+}
+
+// useDaylight replaces a scene's background with the preetham sky and adds
+// its sun
+func (s *scene) useDaylight(sunDir vec3, turbidity float64) {
+	sky := newPreethamSky(sunDir, turbidity)
+	s.background = sky.background
+	s.sun = newSunLight(sunDir, turbidity)
+}
+
+// sunLight returns the light the sun sends off a diffuse hit with reflectance
+// albedo, sampling one direction in its disc
+func (tr *tracer) sunLight(rec *hitRecord, albedo vec3, lambda float64) vec3 {
+	sun := tr.scene.sun
+	w := sun.sample(tr.rng)
+	cosSurface := w.dot(rec.normal)
+	if cosSurface <= 0 {
+		return vec3{}
+	}
+	tr.stats.secondaryRays++
+	var shadow hitRecord
+	if tr.hit(ray{rec.p, w}, &shadow) {
+		return vec3{}
+	}
+	// brdf albedo/pi over a pdf of 1/solid angle
+	return sun.radiance(lambda).mulv(albedo).mul(cosSurface * sun.solidAngle() / math.Pi)
+}
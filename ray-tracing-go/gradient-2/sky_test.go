@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSolarPosition(t *testing.T) {
+	tests := []struct {
+		name          string
+		hour, lat     float64
+		day           int
+		el, az, slack float64
+	}{
+		// at noon the sun is 90 - latitude + declination up, due south
+		{"midsummer noon at 45n", 12, 45, 172, 68.44, 180, 0.05},
+		{"midwinter noon at 45n", 12, 45, 355, 21.56, 180, 0.05},
+		{"midsummer noon in london", 12, 51.5, 172, 61.94, 180, 0.05},
+		// and due north south of the tropics
+		{"midsummer noon in sydney", 12, -33.9, 172, 32.66, 0, 0.05},
+		// the declination approximation is only good to about a degree
+		{"equinox noon on the equator", 12, 0, 80, 90, 0, 1},
+		{"equinox sunrise", 6, 45, 80, 0, 90, 1},
+		{"equinox sunset", 18, 45, 80, 0, 270, 1},
+		// midnight sun, as high as it gets above the northern horizon
+		{"midsummer midnight at 80n", 0, 80, 172, 13.44, 0, 0.05},
+		{"midsummer midnight at 45n", 0, 45, 172, -21.56, 0, 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			el, az := solarPosition(tt.hour, tt.lat, tt.day)
+			if math.Abs(el-tt.el) > tt.slack {
+				t.Errorf("elevation %.3f, want %.2f", el, tt.el)
+			}
+			// the equator at noon has the sun straight up, no bearing to it
+			if tt.el < 89 && math.Abs(math.Remainder(az-tt.az, 360)) > tt.slack {
+				t.Errorf("azimuth %.3f, want %.2f", az, tt.az)
+			}
+		})
+	}
+
+	// morning and afternoon mirror each other about noon
+	for _, h := range []float64{7, 9.5, 11} {
+		elAM, azAM := solarPosition(h, 40, 100)
+		elPM, azPM := solarPosition(24-h, 40, 100)
+		if math.Abs(elAM-elPM) > 1e-9 || math.Abs(azAM+azPM-360) > 1e-9 {
+			t.Errorf("%vh: %v,%v against %v,%v", h, elAM, azAM, elPM, azPM)
+		}
+	}
+}
+
+func TestSunDirection(t *testing.T) {
+	tests := []struct {
+		el, az float64
+		want   vec3
+	}{
+		{90, 0, vec3{0, 1, 0}},
+		{0, 0, vec3{0, 0, -1}},  // north
+		{0, 90, vec3{1, 0, 0}},  // east
+		{0, 180, vec3{0, 0, 1}}, // south, behind the camera
+		{30, 270, vec3{-math.Sqrt(3) / 2, 0.5, 0}},
+	}
+	for _, tt := range tests {
+		if got := sunDirection(tt.el, tt.az); got.sub(tt.want).length() > 1e-12 {
+			t.Errorf("elevation %v azimuth %v: %v, want %v", tt.el, tt.az, got, tt.want)
+		}
+	}
+}
+
+func TestPreethamZenith(t *testing.T) {
+	// the zenith's luminance grows as the sun climbs and as the haze thickens,
+	// and stays within the few to few tens of kcd/m^2 of a real sky
+	for _, turbidity := range []float64{2, 3, 6} {
+		last := 0.0
+		for el := 5.0; el <= 85; el += 10 {
+			sky := newPreethamSky(sunDirection(el, 180), turbidity)
+			lum := sky.zenith.x
+			if lum <= last {
+				t.Errorf("turbidity %v: zenith %.3f kcd/m^2 at %v degrees, %.3f lower down", turbidity, lum, el, last)
+			}
+			if lum < 0.1 || lum > 50 {
+				t.Errorf("turbidity %v: zenith %.3f kcd/m^2 at %v degrees", turbidity, lum, el)
+			}
+			last = lum
+		}
+	}
+	clear := newPreethamSky(sunDirection(40, 180), 2)
+	hazy := newPreethamSky(sunDirection(40, 180), 6)
+	if hazy.zenith.x <= clear.zenith.x {
+		t.Errorf("zenith %.3f in haze, %.3f when clear", hazy.zenith.x, clear.zenith.x)
+	}
+
+	// looking straight up gives back the zenith formula, scaled for the film
+	for _, el := range []float64{10, 35, 70} {
+		sky := newPreethamSky(sunDirection(el, 160), 3)
+		up := sky.radiance(vec3{0, 1, 0})
+		if got, want := luminance(up)/skyScale, sky.zenith.x; math.Abs(got-want) > 0.01*want {
+			t.Errorf("sun at %v: straight up is %.4f kcd/m^2, zenith formula %.4f", el, got, want)
+		}
+		// a clear sky is blue overhead, its chromaticity near white's
+		if up.z <= up.x {
+			t.Errorf("sun at %v: zenith %v isn't blue", el, up)
+		}
+		if x, y := sky.zenith.y, sky.zenith.z; x < 0.2 || x > 0.35 || y < 0.2 || y > 0.35 {
+			t.Errorf("sun at %v: zenith chromaticity %.3f, %.3f", el, x, y)
+		}
+	}
+}
+
+func TestPreethamAroundTheSun(t *testing.T) {
+	// at the same height the sky is brighter toward the sun than away from
+	// it, and brightest of all next to it
+	sun := sunDirection(30, 90)
+	sky := newPreethamSky(sun, 3)
+	toward := luminance(sky.radiance(sunDirection(30, 100)))
+	away := luminance(sky.radiance(sunDirection(30, 270)))
+	if toward <= away {
+		t.Errorf("%.4f toward the sun, %.4f away", toward, away)
+	}
+	for az := 0.0; az < 360; az += 15 {
+		for el := 5.0; el < 90; el += 10 {
+			if l := luminance(sky.radiance(sunDirection(el, az))); l > luminance(sky.radiance(sun)) || l <= 0 {
+				t.Errorf("elevation %v azimuth %v: %.4f, the sun's own direction %.4f", el, az, l, luminance(sky.radiance(sun)))
+			}
+		}
+	}
+	// below the horizon is held at it, rather than running off to infinity
+	// as cos theta goes through 0
+	for _, dir := range []vec3{{0.3, -0.01, -1}, {0, -1, 0}, {-1, -0.5, 0.2}} {
+		c := sky.radiance(dir)
+		if l := luminance(c); math.IsNaN(l) || l <= 0 || l > luminance(sky.radiance(sun)) {
+			t.Errorf("below the horizon along %v: %v", dir, c)
+		}
+	}
+}
+
+func TestDaylightApply(t *testing.T) {
+	// placing the sun by the clock puts it where solarPosition says
+	var s scene
+	if err := (daylight{sky: "preetham", turbidity: 3, clock: "15:30", latitude: 45, day: 172}).apply(&s); err != nil {
+		t.Fatal(err)
+	}
+	el, az := solarPosition(15.5, 45, 172)
+	if s.sun == nil || s.background == nil || s.sun.dir.sub(sunDirection(el, az)).length() > 1e-12 {
+		t.Fatalf("sun %+v, want along %v", s.sun, sunDirection(el, az))
+	}
+
+	var plain scene
+	if err := (daylight{sky: "gradient"}).apply(&plain); err != nil || plain.sun != nil || plain.background != nil {
+		t.Errorf("the gradient sky changed the scene, error %v", err)
+	}
+
+	tests := []struct {
+		name string
+		d    daylight
+		err  string
+	}{
+		{"unknown sky", daylight{sky: "hosek"}, "unknown sky"},
+		{"too clear", daylight{sky: "preetham", turbidity: 1}, "turbidity"},
+		{"too hazy", daylight{sky: "preetham", turbidity: 11}, "turbidity"},
+		{"bad hour", daylight{sky: "preetham", turbidity: 3, clock: "24:00", day: 1}, "bad time"},
+		{"bad minute", daylight{sky: "preetham", turbidity: 3, clock: "12:60", day: 1}, "bad time"},
+		{"not a time", daylight{sky: "preetham", turbidity: 3, clock: "noon", day: 1}, "bad time"},
+		{"no day", daylight{sky: "preetham", turbidity: 3, clock: "12:00"}, "day 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.d.apply(&scene{}); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
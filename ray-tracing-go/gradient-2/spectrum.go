@@ -0,0 +1,209 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// visible range the spectral mode samples, in nanometers
+const (
+	lambdaMin = 380.0
+	lambdaMax = 730.0
+	// sodium d line, where glasses quote their index. rgb renders use it
+	lambdaD = 587.6
+)
+
+// sampleWavelength picks a wavelength uniformly over the visible range
+func sampleWavelength(rng *rand.Rand) float64 {
+	return lambdaMin + rng.Float64()*(lambdaMax-lambdaMin)
+}
+
+// cieXYZ is the cie 1931 2 degree color matching functions, as the piecewise
+// gaussian fit from wyman, sloan and shirley 2013. within a percent or so of
+// the tables, without shipping the tables
+func cieXYZ(lambda float64) vec3 {
+	g := func(mu, s1, s2 float64) float64 {
+		s := s1
+		if lambda >= mu {
+			s = s2
+		}
+		d := (lambda - mu) / s
+		return math.Exp(-0.5 * d * d)
+	}
+	return vec3{
+		1.056*g(599.8, 37.9, 31.0) + 0.362*g(442.0, 16.0, 26.7) - 0.065*g(501.1, 20.4, 26.2),
+		0.821*g(568.8, 46.9, 40.5) + 0.286*g(530.9, 16.3, 31.1),
+		1.217*g(437.0, 11.8, 36.0) + 0.681*g(459.0, 26.0, 13.8),
+	}
+}
+
+// xyzToRGB converts to linear srgb primaries
+func xyzToRGB(c vec3) vec3 {
+	return vec3{
+		3.2404542*c.x - 1.5371385*c.y - 0.4985314*c.z,
+		-0.9692660*c.x + 1.8760108*c.y + 0.0415560*c.z,
+		0.0556434*c.x - 0.2040259*c.y + 1.0572252*c.z,
+	}
+}
+
+// spectralWhite is the rgb of a flat spectrum of 1. dividing by it balances
+// the equal energy white to rgb white, so a grey reflectance stays grey
+var spectralWhite = func() vec3 {
+	var xyz vec3
+	for l := lambdaMin; l < lambdaMax; l++ {
+		xyz = xyz.add(cieXYZ(l + 0.5))
+	}
+	return xyzToRGB(xyz)
+}()
+
+// spectralToRGB turns radiance v carried at one sampled wavelength into the
+// rgb it contributes: a monte carlo estimate of the xyz integral, divided by
+// the uniform pdf 1/(lambdaMax-lambdaMin)
+func spectralToRGB(lambda, v float64) vec3 {
+	xyz := cieXYZ(lambda).mul(v * (lambdaMax - lambdaMin))
+	rgb := xyzToRGB(xyz)
+	return vec3{rgb.x / spectralWhite.x, rgb.y / spectralWhite.y, rgb.z / spectralWhite.z}
+}
+
+// sigmoidSpectrum is a smooth spectrum s(l) = S(c0 l^2 + c1 l + c2), with l
+// the wavelength mapped to [0,1] and S a sigmoid squashing into (0,1). jakob
+// and hanika 2019 show three coefficients reach almost every srgb color
+type sigmoidSpectrum [3]float64
+
+func (s sigmoidSpectrum) at(lambda float64) float64 {
+	l := (lambda - lambdaMin) / (lambdaMax - lambdaMin)
+	x := (s[0]*l+s[1])*l + s[2]
+	return 0.5 + x/(2*math.Sqrt(1+x*x))
+}
+
+// rgb integrates the spectrum against the matching functions as a reflectance
+func (s sigmoidSpectrum) rgb() vec3 {
+	const step = 5.0
+	var xyz vec3
+	for l := lambdaMin + step/2; l < lambdaMax; l += step {
+		xyz = xyz.add(cieXYZ(l).mul(s.at(l) * step))
+	}
+	rgb := xyzToRGB(xyz)
+	return vec3{rgb.x / spectralWhite.x, rgb.y / spectralWhite.y, rgb.z / spectralWhite.z}
+}
+
+// fitSigmoid finds the coefficients whose spectrum has the given rgb, with
+// gauss-newton steps. it walks the target out from grey, where all zero
+// coefficients are exact, so each stage starts close to its answer
+func fitSigmoid(target vec3) sigmoidSpectrum {
+	// 0 and 1 are only reached at infinite coefficients
+	target = vec3{clamp(target.x, 1e-3, 1-1e-3), clamp(target.y, 1e-3, 1-1e-3), clamp(target.z, 1e-3, 1-1e-3)}
+	grey := vec3{0.5, 0.5, 0.5}
+
+	var s sigmoidSpectrum
+	const stages = 8
+	for stage := 1; stage <= stages; stage++ {
+		goal := grey.add(target.sub(grey).mul(float64(stage) / stages))
+		for range 15 {
+			res := s.rgb().sub(goal)
+			if res.lengthSquared() < 1e-10 {
+				break
+			}
+			// jacobian by forward differences, one column per coefficient
+			var jac [3]vec3
+			for k := range 3 {
+				d := s
+				d[k] += 1e-4
+				jac[k] = d.rgb().sub(goal).sub(res).div(1e-4)
+			}
+			step, ok := solve3(jac, res)
+			if !ok {
+				break
+			}
+			for k := range 3 {
+				s[k] -= step[k]
+			}
+		}
+	}
+	return s
+}
+
+// solve3 solves for x in sum_k cols[k]*x[k] = b by cramer's rule
+func solve3(cols [3]vec3, b vec3) ([3]float64, bool) {
+	det := cols[0].dot(cols[1].cross(cols[2]))
+	if math.Abs(det) < 1e-14 {
+		return [3]float64{}, false
+	}
+	return [3]float64{
+		b.dot(cols[1].cross(cols[2])) / det,
+		cols[0].dot(b.cross(cols[2])) / det,
+		cols[0].dot(cols[1].cross(b)) / det,
+	}, true
+}
+
+// spectralColor is an rgb color that can also be evaluated at a single
+// wavelength, through its upsampled spectrum. colors brighter than 1, for
+// emitters, are a scaled down fit times the scale
+type spectralColor struct {
+	rgb   vec3
+	sig   sigmoidSpectrum
+	scale float64
+	grey  bool // r == g == b, the spectrum is flat and needs no fit
+}
+
+func newSpectralColor(rgb vec3) spectralColor {
+	c := spectralColor{rgb: rgb, scale: 1}
+	if rgb.x == rgb.y && rgb.y == rgb.z {
+		c.grey = true
+		return c
+	}
+	if m := max(rgb.x, rgb.y, rgb.z); m > 1 {
+		c.scale = 2 * m
+	}
+	c.sig = fitSigmoid(rgb.div(c.scale))
+	return c
+}
+
+// eval returns the color for an rgb path (lambda 0), or the spectrum's value
+// at lambda in all three channels for a spectral one
+func (c spectralColor) eval(lambda float64) vec3 {
+	if lambda == 0 {
+		return c.rgb
+	}
+	v := c.rgb.x
+	if !c.grey {
+		v = c.scale * c.sig.at(lambda)
+	}
+	return vec3{v, v, v}
+}
+
+// ior is a refractive index as a function of wavelength in nanometers
+type ior func(lambda float64) float64
+
+func constantIOR(n float64) ior {
+	return func(float64) float64 { return n }
+}
+
+// cauchyIOR is n = a + b/l^2 with l in micrometers. good enough for glass in
+// the visible range, b sets how strongly it disperses
+func cauchyIOR(a, b float64) ior {
+	return func(lambda float64) float64 {
+		um := lambda / 1000
+		return a + b/(um*um)
+	}
+}
+
+// sellmeierIOR is n^2 = 1 + sum b_i l^2 / (l^2 - c_i), l in micrometers,
+// which is how glass catalogues publish their dispersion
+func sellmeierIOR(b, c [3]float64) ior {
+	return func(lambda float64) float64 {
+		um2 := (lambda / 1000) * (lambda / 1000)
+		n2 := 1.0
+		for k := range 3 {
+			n2 += b[k] * um2 / (um2 - c[k])
+		}
+		return math.Sqrt(n2)
+	}
+}
+
+// catalogue glasses for sellmeierIOR. bk7 is ordinary crown glass, sf11 a
+// dense flint that spreads colors about three times as far
+var (
+	bk7  = sellmeierIOR([3]float64{1.03961212, 0.231792344, 1.01046945}, [3]float64{0.00600069867, 0.0200179144, 103.560653})
+	sf11 = sellmeierIOR([3]float64{1.73759695, 0.313747346, 1.89878101}, [3]float64{0.013188707, 0.0623068142, 155.23629})
+)
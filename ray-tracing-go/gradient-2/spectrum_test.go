@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// fraunhofer lines glass catalogues quote indices at
+const (
+	lambdaF = 486.1 // hydrogen, blue
+	lambdaC = 656.3 // hydrogen, red
+)
+
+func TestIOR(t *testing.T) {
+	tests := []struct {
+		name   string
+		index  ior
+		lambda float64
+		want   float64
+		tol    float64
+	}{
+		{"constant", constantIOR(1.5), 450, 1.5, 0},
+		{"cauchy at 500nm", cauchyIOR(1.5, 0.05), 500, 1.7, 1e-12},
+		{"cauchy at 1000nm", cauchyIOR(1.5, 0.05), 1000, 1.55, 1e-12},
+		// schott's catalogue values
+		{"bk7 nd", bk7, lambdaD, 1.5168, 1e-4},
+		{"bk7 nF", bk7, lambdaF, 1.5224, 1e-4},
+		{"bk7 nC", bk7, lambdaC, 1.5143, 1e-4},
+		{"sf11 nd", sf11, lambdaD, 1.7847, 1e-4},
+		{"sf11 nF", sf11, lambdaF, 1.8065, 1e-4},
+		{"sf11 nC", sf11, lambdaC, 1.7760, 1e-4},
+	}
+	for _, tt := range tests {
+		if got := tt.index(tt.lambda); math.Abs(got-tt.want) > tt.tol {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIORDispersion(t *testing.T) {
+	// normal dispersion: the index falls from blue to red everywhere visible
+	for name, index := range map[string]ior{"cauchy": cauchyIOR(1.5, 0.05), "bk7": bk7, "sf11": sf11} {
+		for l := lambdaMin; l < lambdaMax; l += 10 {
+			if index(l+10) >= index(l) {
+				t.Errorf("%s: n(%v) = %v is not below n(%v) = %v", name, l+10, index(l+10), l, index(l))
+			}
+		}
+	}
+	// abbe numbers, (nd - 1) / (nF - nC): sf11 spreads colors more than twice
+	// as far as bk7
+	abbe := func(n ior) float64 { return (n(lambdaD) - 1) / (n(lambdaF) - n(lambdaC)) }
+	if v := abbe(bk7); math.Abs(v-64.17) > 0.5 {
+		t.Errorf("bk7 abbe number %v, want 64.17", v)
+	}
+	if v := abbe(sf11); math.Abs(v-25.68) > 0.5 {
+		t.Errorf("sf11 abbe number %v, want 25.68", v)
+	}
+}
+
+func TestSpectralToRGBFlatIsWhite(t *testing.T) {
+	// averaged over the range, a flat spectrum comes out white
+	var sum vec3
+	n := 0
+	for l := lambdaMin; l < lambdaMax; l++ {
+		sum = sum.add(spectralToRGB(l+0.5, 1))
+		n++
+	}
+	if got := sum.div(float64(n)); !near(got, vec3{1, 1, 1}, 1e-9) {
+		t.Errorf("flat spectrum integrates to %v, want white", got)
+	}
+
+	// and so does the monte carlo estimate the renderer makes, in the limit
+	rng := rand.New(rand.NewPCG(1, 1))
+	sum = vec3{}
+	const samples = 200000
+	for range samples {
+		sum = sum.add(spectralToRGB(sampleWavelength(rng), 0.5))
+	}
+	if got := sum.div(samples); !near(got, vec3{0.5, 0.5, 0.5}, 0.01) {
+		t.Errorf("sampled flat spectrum of 0.5 is %v, want grey 0.5", got)
+	}
+}
+
+func TestSpectralGreyReflectance(t *testing.T) {
+	// a grey spectral color reflects every wavelength the same, so its rgb
+	// round trips
+	c := newSpectralColor(vec3{0.4, 0.4, 0.4})
+	for l := lambdaMin; l < lambdaMax; l += 50 {
+		if got := c.eval(l); got != (vec3{0.4, 0.4, 0.4}) {
+			t.Errorf("grey at %v: %v", l, got)
+		}
+	}
+}
+
+// throughSphere refracts a ray into a glass ball and back out at lambda,
+// returning where it leaves and its direction
+func throughSphere(t *testing.T, s *sphere, index ior, r ray, lambda float64) ray {
+	t.Helper()
+	for k, wantFront := range []bool{true, false} {
+		var rec hitRecord
+		if !s.hit(r, 1e-6, math.Inf(1), &rec) {
+			t.Fatalf("interface %d missed", k)
+		}
+		if rec.frontFace != wantFront {
+			t.Fatalf("interface %d: front face %v", k, rec.frontFace)
+		}
+		eta := index(lambda)
+		if rec.frontFace {
+			eta = 1 / eta
+		}
+		unit := r.dir.unit()
+		cosTheta := min(unit.neg().dot(rec.normal), 1)
+		if eta*math.Sqrt(1-cosTheta*cosTheta) > 1 {
+			t.Fatalf("interface %d: total internal reflection", k)
+		}
+		r = ray{rec.p, refract(unit, rec.normal, cosTheta, eta)}
+	}
+	return r
+}
+
+func TestDispersionThroughSphere(t *testing.T) {
+	// light enters the ball and leaves through its back, blue bent further
+	// towards the axis than red
+	s := &sphere{center: vec3{0, 0, -3}, radius: 1}
+	in := ray{vec3{0, 0.5, 0}, vec3{0, 0, -1}}
+	red := throughSphere(t, s, sf11, in, lambdaC)
+	blue := throughSphere(t, s, sf11, in, lambdaF)
+	for _, out := range []ray{red, blue} {
+		if out.orig.z > -3 {
+			t.Fatalf("left through the front at %v", out.orig)
+		}
+		if out.dir.y >= 0 {
+			t.Fatalf("exit direction %v isn't bent towards the axis", out.dir)
+		}
+	}
+	if blue.dir.y >= red.dir.y {
+		t.Errorf("blue leaves along %v, red along %v: blue should bend more", blue.dir, red.dir)
+	}
+
+	// an rgb ray bends at the d line, between the two
+	d := throughSphere(t, s, sf11, in, lambdaD)
+	if d.dir.y <= blue.dir.y || d.dir.y >= red.dir.y {
+		t.Errorf("d line leaves along %v, not between %v and %v", d.dir, blue.dir, red.dir)
+	}
+}
+
+func TestDielectricScatterRGBUsesDLine(t *testing.T) {
+	// lambda 0 is an rgb path, which refracts as the d line would
+	s := &sphere{center: vec3{0, 0, -3}, radius: 1}
+	in := ray{vec3{0, 0.5, 0}, vec3{0, 0, -1}}
+	var rec hitRecord
+	s.hit(in, 1e-6, math.Inf(1), &rec)
+	m := &dielectric{sf11}
+	rng := rand.New(rand.NewPCG(3, 3))
+	cosTheta := -in.dir.dot(rec.normal)
+	want := refract(in.dir, rec.normal, cosTheta, 1/sf11(lambdaD))
+	refracted := 0
+	for range 100 {
+		out, atten, ok := m.scatter(in, &rec, 0, rng)
+		if !ok || atten != (vec3{1, 1, 1}) {
+			t.Fatalf("scatter %v %v", atten, ok)
+		}
+		if out.dir.z > 0 {
+			continue // fresnel reflection
+		}
+		refracted++
+		if !near(out.dir, want, 1e-12) {
+			t.Fatalf("refracted along %v, want %v", out.dir, want)
+		}
+		var back hitRecord
+		if !s.hit(out, 1e-6, math.Inf(1), &back) || back.frontFace {
+			t.Fatal("refracted ray doesn't reach the back of the ball")
+		}
+	}
+	if refracted < 80 {
+		t.Errorf("only %d of 100 refracted", refracted)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// sphereSet stores many spheres as a structure of arrays, so intersecting
+// one ray against all of them walks contiguous memory. it's a hittable, so a
+// scene full of spheres can hold one set instead of a sphere per object
+type sphereSet struct {
+	cx, cy, cz []float64
+	r2         []float64 // radius squared, all the intersection needs
+	radius     []float64
+}
+
+func newSphereSet(spheres []*sphere) *sphereSet {
+	ss := &sphereSet{}
+	for _, s := range spheres {
+		ss.cx = append(ss.cx, s.center.x)
+		ss.cy = append(ss.cy, s.center.y)
+		ss.cz = append(ss.cz, s.center.z)
+		ss.r2 = append(ss.r2, s.radius*s.radius)
+		ss.radius = append(ss.radius, s.radius)
+	}
+	return ss
+}
+
+// nearest returns the closest root in (tMin, tMax) and which sphere it's on,
+// or -1. same half-b test as hitSphere, with tMax doing extra rejection
+func (ss *sphereSet) nearest(o, d vec3, tMin, tMax float64) (float64, int) {
+	a := d.dot(d)
+	idx := -1
+	for k := range ss.cx {
+		ocx := ss.cx[k] - o.x
+		ocy := ss.cy[k] - o.y
+		ocz := ss.cz[k] - o.z
+		h := d.x*ocx + d.y*ocy + d.z*ocz
+		c := ocx*ocx + ocy*ocy + ocz*ocz - ss.r2[k]
+		disc := h*h - a*c
+		// misses first, then spheres behind the origin, as in hitSphere
+		if disc < 0 || (c > 0 && h < 0) {
+			continue
+		}
+		sq := math.Sqrt(disc)
+		t := (h - sq) / a
+		if t <= tMin {
+			t = (h + sq) / a // inside the sphere, take the far root
+		}
+		if t > tMin && t < tMax {
+			tMax = t
+			idx = k
+		}
+	}
+	return tMax, idx
+}
+
+func (ss *sphereSet) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	t, k := ss.nearest(r.orig, r.dir, tMin, tMax)
+	if k < 0 {
+		return false
+	}
+	center := vec3{ss.cx[k], ss.cy[k], ss.cz[k]}
+	rec.t = t
+	rec.p = r.at(t)
+	outward := rec.p.sub(center).div(ss.radius[k])
+	rec.setFaceNormal(r, outward)
+	rec.u, rec.v = sphereUV(outward)
+	rec.tangent = sphereTangent(outward)
+	return true
+}
+
+func (ss *sphereSet) boundingBox() aabb {
+	b := emptyBox()
+	for k := range ss.cx {
+		c := vec3{ss.cx[k], ss.cy[k], ss.cz[k]}
+		rv := vec3{ss.radius[k], ss.radius[k], ss.radius[k]}
+		b = b.union(aabb{c.sub(rv), c.add(rv)})
+	}
+	return b
+}
+
+// spheresScene scatters a field of small spheres around the original one
+func spheresScene() *scene {
+	rng := rand.New(rand.NewPCG(42, 42))
+	var field []*sphere
+	for a := -8; a < 8; a++ {
+		for b := -12; b < 2; b++ {
+			r := 0.05 + 0.07*rng.Float64()
+			c := vec3{float64(a)*0.3 + 0.2*rng.Float64(), -0.5 + r, float64(b)*0.3 + 0.2*rng.Float64()}
+			if c.sub(vec3{0, 0, -1}).length() < 0.5+r {
+				continue
+			}
+			field = append(field, &sphere{center: c, radius: r})
+		}
+	}
+	// the field goes in one set after the original sphere, which stays
+	// object 0 for the turntable to animate on its own
+	return &scene{objects: hittableList{
+		&sphere{center: vec3{0, 0, -1}, radius: 0.5},
+		&plane{point: vec3{0, -0.5, 0}, normal: vec3{0, 1, 0}},
+		newSphereSet(field),
+	}}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+const batchTMin = 0.001
+
+// hitSphereQuadratic is the original full quadratic version, kept as the
+// reference the tests check hitSphere against
+func hitSphereQuadratic(cx, cy, cz, radius float64, ox, oy, oz, dx, dy, dz, tMin float64) float64 {
+	ocx := ox - cx
+	ocy := oy - cy
+	ocz := oz - cz
+
+	a := dx*dx + dy*dy + dz*dz
+	b := 2.0 * (ocx*dx + ocy*dy + ocz*dz)
+	c := ocx*ocx + ocy*ocy + ocz*ocz - radius*radius
+
+	discriminant := b*b - 4*a*c
+
+	if discriminant < 0 {
+		return -1.0
+	}
+	t := (-b - math.Sqrt(discriminant)) / (2.0 * a)
+	if t <= tMin {
+		t = (-b + math.Sqrt(discriminant)) / (2.0 * a)
+	}
+	if t <= tMin {
+		return -1.0
+	}
+	return t
+}
+
+// randomSpheres scatters n spheres in front of the origin
+func randomSpheres(rng *rand.Rand, n int) []*sphere {
+	var spheres []*sphere
+	for range n {
+		spheres = append(spheres, &sphere{
+			center: vec3{rng.Float64()*8 - 4, rng.Float64()*8 - 4, -rng.Float64()*8 - 1},
+			radius: 0.1 + rng.Float64()*0.5,
+		})
+	}
+	return spheres
+}
+
+// randomRays shoots n rays forwards from near the origin. every fourth one
+// starts inside a sphere instead, in a random direction
+func randomRays(rng *rand.Rand, spheres []*sphere, n int) []ray {
+	var rays []ray
+	for k := range n {
+		r := ray{vec3{rng.Float64() - 0.5, rng.Float64() - 0.5, 0}, randomUnitVector(rng)}
+		r.dir.z = -math.Abs(r.dir.z)
+		if k%4 == 3 {
+			s := spheres[rng.IntN(len(spheres))]
+			r.orig = s.center.add(randomUnitVector(rng).mul(s.radius * 0.9 * rng.Float64()))
+			r.dir = randomUnitVector(rng)
+		}
+		rays = append(rays, r)
+	}
+	return rays
+}
+
+// scalarNearest is the nearest hit over every sphere one at a time, the way
+// sphere.hit and hittableList do it
+func scalarNearest(hit func(cx, cy, cz, radius, ox, oy, oz, dx, dy, dz, tMin float64) float64,
+	spheres []*sphere, rays []ray, tHit []float64, idx []int32) {
+	for i, r := range rays {
+		best, bestK := math.Inf(1), int32(-1)
+		for k, s := range spheres {
+			t := hit(s.center.x, s.center.y, s.center.z, s.radius,
+				r.orig.x, r.orig.y, r.orig.z, r.dir.x, r.dir.y, r.dir.z, batchTMin)
+			if t > batchTMin && t < best {
+				best, bestK = t, int32(k)
+			}
+		}
+		tHit[i], idx[i] = best, bestK
+	}
+}
+
+func TestSphereVariantsMatch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	spheres := randomSpheres(rng, 64)
+	rays := randomRays(rng, spheres, 20000)
+	ss := newSphereSet(spheres)
+
+	refT := make([]float64, len(rays))
+	refIdx := make([]int32, len(rays))
+	scalarNearest(hitSphereQuadratic, spheres, rays, refT, refIdx)
+
+	variants := []struct {
+		name string
+		run  func(tHit []float64, idx []int32)
+	}{
+		{name: "half-b", run: func(tHit []float64, idx []int32) {
+			scalarNearest(hitSphere, spheres, rays, tHit, idx)
+		}},
+		{name: "sphere list", run: func(tHit []float64, idx []int32) {
+			list := make(hittableList, len(spheres))
+			for k, s := range spheres {
+				list[k] = s
+			}
+			for i, r := range rays {
+				var rec hitRecord
+				tHit[i], idx[i] = math.Inf(1), -1
+				if list.hit(r, batchTMin, math.Inf(1), &rec) {
+					tHit[i], idx[i] = rec.t, 0
+				}
+			}
+		}},
+		{name: "soa nearest", run: func(tHit []float64, idx []int32) {
+			for i, r := range rays {
+				t, k := ss.nearest(r.orig, r.dir, batchTMin, math.Inf(1))
+				tHit[i], idx[i] = t, int32(k)
+			}
+		}},
+		{name: "soa hit", run: func(tHit []float64, idx []int32) {
+			for i, r := range rays {
+				var rec hitRecord
+				tHit[i], idx[i] = math.Inf(1), -1
+				if ss.hit(r, batchTMin, math.Inf(1), &rec) {
+					tHit[i], idx[i] = rec.t, 0
+				}
+			}
+		}},
+	}
+	inside := 0
+	for i := range rays {
+		if i%4 == 3 && refIdx[i] >= 0 {
+			inside++
+		}
+	}
+	if inside < len(rays)/4*9/10 {
+		t.Fatalf("only %d rays from inside a sphere hit it", inside)
+	}
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			tHit := make([]float64, len(rays))
+			idx := make([]int32, len(rays))
+			v.run(tHit, idx)
+			for i := range rays {
+				if (idx[i] < 0) != (refIdx[i] < 0) {
+					t.Errorf("ray %d %v: hit %v, want %v", i, rays[i], idx[i] >= 0, refIdx[i] >= 0)
+					continue
+				}
+				// different spheres at the same distance are both right
+				if idx[i] >= 0 && math.Abs(tHit[i]-refT[i]) > 1e-9*math.Max(1, refT[i]) {
+					t.Errorf("ray %d %v: t %v, want %v", i, rays[i], tHit[i], refT[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSpheresSceneAnimatesOneSphere(t *testing.T) {
+	// the turntable bobs object 0, which has to be the original sphere alone
+	s := spheresScene()
+	if _, ok := s.objects[0].(*sphere); !ok {
+		t.Fatalf("object 0 is %T, want the original sphere", s.objects[0])
+	}
+	var set *sphereSet
+	for _, obj := range s.objects {
+		if ss, ok := obj.(*sphereSet); ok {
+			set = ss
+		}
+	}
+	if set == nil || len(set.cx) < 100 {
+		t.Fatal("the sphere field isn't packed into a set")
+	}
+}
+
+// benchmarks report ns per ray/sphere test, the unit the variants compare in
+
+type benchScene struct {
+	spheres []*sphere
+	rays    []ray
+	ss      *sphereSet
+}
+
+func newBenchScene() *benchScene {
+	rng := rand.New(rand.NewPCG(1, 1))
+	bs := &benchScene{spheres: randomSpheres(rng, 64)}
+	bs.rays = randomRays(rng, bs.spheres, 4096)
+	bs.ss = newSphereSet(bs.spheres)
+	return bs
+}
+
+func (bs *benchScene) report(b *testing.B) {
+	tests := float64(b.N) * float64(len(bs.rays)*len(bs.spheres))
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/tests, "ns/test")
+}
+
+func BenchmarkSphereQuadratic(b *testing.B) {
+	bs := newBenchScene()
+	tHit := make([]float64, len(bs.rays))
+	idx := make([]int32, len(bs.rays))
+	for b.Loop() {
+		scalarNearest(hitSphereQuadratic, bs.spheres, bs.rays, tHit, idx)
+	}
+	bs.report(b)
+}
+
+func BenchmarkSphereHalfB(b *testing.B) {
+	bs := newBenchScene()
+	tHit := make([]float64, len(bs.rays))
+	idx := make([]int32, len(bs.rays))
+	for b.Loop() {
+		scalarNearest(hitSphere, bs.spheres, bs.rays, tHit, idx)
+	}
+	bs.report(b)
+}
+
+func BenchmarkSphereSoANearest(b *testing.B) {
+	bs := newBenchScene()
+	for b.Loop() {
+		for _, r := range bs.rays {
+			bs.ss.nearest(r.orig, r.dir, batchTMin, math.Inf(1))
+		}
+	}
+	bs.report(b)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// histogram counts paths by how many segments they had, index 0 unused
+type histogram []int64
+
+func (h histogram) add(length int) {
+	if length >= 0 && length < len(h) {
+		h[length]++
+	}
+}
+
+// workerStats is owned by one render goroutine, so no locking. they're only
+// summed once the render is done
+type workerStats struct {
+	primaryRays   int64
+	secondaryRays int64
+	// objectVisits counts the scene's top level objects rays were tested
+	// against. a sphere set, bvh or csg tree is one visit whatever is inside
+	objectVisits int64
+	pathLengths  histogram
+	rows         int
+	busy         time.Duration
+}
+
+func newWorkerStats(maxDepth int) *workerStats {
+	return &workerStats{pathLengths: make(histogram, maxDepth+2)}
+}
+
+type workerReport struct {
+	Rows          int     `json:"rows"`
+	Rays          int64   `json:"rays"`
+	BusySeconds   float64 `json:"busy_seconds"`
+	RaysPerSecond float64 `json:"rays_per_second"`
+}
+
+// renderReport is the summary printed after a render and written as json
+type renderReport struct {
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	Samples        int            `json:"samples"`
+	TotalSeconds   float64        `json:"total_seconds"`
+	PrimaryRays    int64          `json:"primary_rays"`
+	SecondaryRays  int64          `json:"secondary_rays"`
+	ObjectVisits   int64          `json:"object_visits"`
+	RaysPerSecond  float64        `json:"rays_per_second"`
+	PathLengths    map[int]int64  `json:"path_lengths"`
+	Workers        []workerReport `json:"workers"`
+	CausticPhotons int            `json:"caustic_photons,omitempty"`
+}
+
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+func (f *film) report() *renderReport {
+	total, _, _ := f.sampleStats()
+	rep := &renderReport{
+		Width:        f.width,
+		Height:       f.height,
+		Samples:      total,
+		TotalSeconds: f.elapsed.Seconds(),
+		PathLengths:  map[int]int64{},
+	}
+	rep.CausticPhotons = f.photons
+	for _, st := range f.stats {
+		rays := st.primaryRays + st.secondaryRays
+		rep.PrimaryRays += st.primaryRays
+		rep.SecondaryRays += st.secondaryRays
+		rep.ObjectVisits += st.objectVisits
+		for length, n := range st.pathLengths {
+			if n > 0 {
+				rep.PathLengths[length] += n
+			}
+		}
+		rep.Workers = append(rep.Workers, workerReport{
+			Rows:          st.rows,
+			Rays:          rays,
+			BusySeconds:   st.busy.Seconds(),
+			RaysPerSecond: perSecond(rays, st.busy),
+		})
+	}
+	rep.RaysPerSecond = perSecond(rep.PrimaryRays+rep.SecondaryRays, f.elapsed)
+	return rep
+}
+
+func (rep *renderReport) print(w io.Writer) {
+	fmt.Fprintf(w, "rendered %dx%d in %.2fs, %d samples\n", rep.Width, rep.Height, rep.TotalSeconds, rep.Samples)
+	fmt.Fprintf(w, "rays: %d primary, %d secondary, %.0f rays/s\n", rep.PrimaryRays, rep.SecondaryRays, rep.RaysPerSecond)
+	fmt.Fprintf(w, "object visits: %d\n", rep.ObjectVisits)
+	if rep.CausticPhotons > 0 {
+		fmt.Fprintf(w, "caustic photons: %d\n", rep.CausticPhotons)
+	}
+
+	for k, wr := range rep.Workers {
+		fmt.Fprintf(w, "  worker %2d: %4d rows, %10d rays, %.0f rays/s\n", k, wr.Rows, wr.Rays, wr.RaysPerSecond)
+	}
+
+	// bar chart of path lengths scaled to the most common
+	longest := 0
+	var most int64
+	for length, n := range rep.PathLengths {
+		longest = max(longest, length)
+		most = max(most, n)
+	}
+	fmt.Fprintln(w, "path lengths:")
+	for length := 1; length <= longest; length++ {
+		n := rep.PathLengths[length]
+		bar := 0
+		if most > 0 {
+			bar = int(40 * n / most)
+		}
+		fmt.Fprintf(w, "  %3d %12d %s\n", length, n, strings.Repeat("#", bar))
+	}
+}
+
+func (rep *renderReport) writeJSON(path string) error {
+	return writeJSONFile(path, rep)
+}
+
+// frameReport is one animation frame's report, written as an array of them
+type frameReport struct {
+	Frame int `json:"frame"`
+	*renderReport
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,85 @@
+package main
+
+import "math"
+
+// triangle with texture coordinates at each vertex
+type triangle struct {
+	p0, p1, p2 vec3
+	uv0        [2]float64
+	uv1        [2]float64
+	uv2        [2]float64
+}
+
+func newTriangle(p0, p1, p2 vec3) *triangle {
+	return &triangle{p0: p0, p1: p1, p2: p2, uv0: [2]float64{0, 0}, uv1: [2]float64{1, 0}, uv2: [2]float64{0, 1}}
+}
+
+// hit is the moller-trumbore test, solving for barycentric coordinates and t
+// together
+func (tr *triangle) hit(r ray, tMin, tMax float64, rec *hitRecord) bool {
+	e1 := tr.p1.sub(tr.p0)
+	e2 := tr.p2.sub(tr.p0)
+
+	pv := r.dir.cross(e2)
+	det := e1.dot(pv)
+	if math.Abs(det) < 1e-12 {
+		return false // ray parallel to the triangle
+	}
+	inv := 1 / det
+
+	tv := r.orig.sub(tr.p0)
+	b1 := tv.dot(pv) * inv
+	if b1 < 0 || b1 > 1 {
+		return false
+	}
+	qv := tv.cross(e1)
+	b2 := r.dir.dot(qv) * inv
+	if b2 < 0 || b1+b2 > 1 {
+		return false
+	}
+	t := e2.dot(qv) * inv
+	if t <= tMin || t >= tMax {
+		return false
+	}
+
+	b0 := 1 - b1 - b2
+	rec.t = t
+	rec.p = r.at(t)
+	outward := e1.cross(e2).unit()
+	rec.setFaceNormal(r, outward)
+	rec.u = b0*tr.uv0[0] + b1*tr.uv1[0] + b2*tr.uv2[0]
+	rec.v = b0*tr.uv0[1] + b1*tr.uv1[1] + b2*tr.uv2[1]
+	rec.tangent = tr.tangent(outward)
+	return true
+}
+
+// tangent is dp/du: solve the edge vectors against their uv deltas
+func (tr *triangle) tangent(n vec3) vec3 {
+	e1 := tr.p1.sub(tr.p0)
+	e2 := tr.p2.sub(tr.p0)
+	du1, dv1 := tr.uv1[0]-tr.uv0[0], tr.uv1[1]-tr.uv0[1]
+	du2, dv2 := tr.uv2[0]-tr.uv0[0], tr.uv2[1]-tr.uv0[1]
+
+	det := du1*dv2 - du2*dv1
+	if math.Abs(det) < 1e-12 {
+		t, _ := tangentBasis(n) // degenerate uvs, any frame will do
+		return t
+	}
+	return e1.mul(dv2).sub(e2.mul(dv1)).div(det).unit()
+}
+
+func (tr *triangle) boundingBox() aabb {
+	b := aabb{tr.p0, tr.p0}.union(aabb{tr.p1, tr.p1}).union(aabb{tr.p2, tr.p2})
+	// pad flat boxes so the slab test still sees them
+	const pad = 1e-4
+	if b.max.x-b.min.x < pad {
+		b.min.x, b.max.x = b.min.x-pad, b.max.x+pad
+	}
+	if b.max.y-b.min.y < pad {
+		b.min.y, b.max.y = b.min.y-pad, b.max.y+pad
+	}
+	if b.max.z-b.min.z < pad {
+		b.min.z, b.max.z = b.min.z-pad, b.max.z+pad
+	}
+	return b
+}
@@ -0,0 +1,67 @@
+package main
+
+import "math"
+
+// vec3 is used for points, directions and linear rgb colors alike
+type vec3 struct {
+	x, y, z float64
+}
+
+func (a vec3) add(b vec3) vec3 {
+	return vec3{a.x + b.x, a.y + b.y, a.z + b.z}
+}
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a.x - b.x, a.y - b.y, a.z - b.z}
+}
+
+func (a vec3) mul(s float64) vec3 {
+	return vec3{a.x * s, a.y * s, a.z * s}
+}
+
+// component-wise product, used for attenuating colors
+func (a vec3) mulv(b vec3) vec3 {
+	return vec3{a.x * b.x, a.y * b.y, a.z * b.z}
+}
+
+func (a vec3) div(s float64) vec3 {
+	return vec3{a.x / s, a.y / s, a.z / s}
+}
+
+func (a vec3) neg() vec3 {
+	return vec3{-a.x, -a.y, -a.z}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a.x*b.x + a.y*b.y + a.z*b.z
+}
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{
+		a.y*b.z - a.z*b.y,
+		a.z*b.x - a.x*b.z,
+		a.x*b.y - a.y*b.x,
+	}
+}
+
+func (a vec3) lengthSquared() float64 {
+	return a.dot(a)
+}
+
+func (a vec3) length() float64 {
+	return math.Sqrt(a.lengthSquared())
+}
+
+// unit vector, i.e. root(ux2 + uy2 + uz2) = 1
+func (a vec3) unit() vec3 {
+	return a.div(a.length())
+}
+
+// ray: P(t) = origin + t*direction
+type ray struct {
+	orig, dir vec3
+}
+
+func (r ray) at(t float64) vec3 {
+	return r.orig.add(r.dir.mul(t))
+}
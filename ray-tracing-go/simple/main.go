@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"unsafe"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"gfx/capture"
+	"gfx/framebuffer"
+	"gfx/platform"
 )
 
 const (
@@ -13,82 +15,28 @@ const (
 	winHeight   = int(float64(winWidth) / aspectRatio)
 )
 
-type color struct {
-	r, g, b byte
-}
-
-func setPixel(x, y int, c color, pixels []byte) {
-	if x < 0 || x >= winWidth || y < 0 || y >= winHeight {
-		return
-	}
-	idx := (y*winWidth + x) * 3
-	if idx+2 >= len(pixels) {
-		return
-	}
-	pixels[idx+0] = c.r
-	pixels[idx+1] = c.g
-	pixels[idx+2] = c.b
-}
-
 func main() {
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
-		log.Fatalf("could not initialize sdl: %v", err)
-	}
-	defer sdl.Quit()
-
-	win, err := sdl.CreateWindow(
-		"Gradient",
-		sdl.WINDOWPOS_UNDEFINED,
-		sdl.WINDOWPOS_UNDEFINED,
-		int32(winWidth),
-		int32(winHeight),
-		sdl.WINDOW_SHOWN,
-	)
-	if err != nil {
-		log.Fatalf("could not create window: %v", err)
-	}
-	defer win.Destroy()
+	var opts platform.Options
+	opts.Register(flag.CommandLine)
+	var shots capture.Options
+	shots.Register(flag.CommandLine)
+	flag.Parse()
 
-	renderer, err := sdl.CreateRenderer(win, -1, sdl.RENDERER_ACCELERATED)
-	if err != nil {
-		log.Fatalf("could not create renderer: %v", err)
-	}
-	defer renderer.Destroy()
-	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGB24, sdl.TEXTUREACCESS_STREAMING, int32(winWidth), int32(winHeight))
+	win, err := opts.Open("Gradient", winWidth, winHeight)
 	if err != nil {
-		log.Fatalf("could not create texture: %v", err)
+		log.Fatalf("could not open window: %v", err)
 	}
-	defer tex.Destroy()
-
-	pixels := make([]byte, winWidth*winHeight*3)
-
-	for j := range winHeight {
-		for i := range winWidth {
-			c := color{255, 0, 0}
-			setPixel(i, j, c, pixels)
+	p := capture.Wrap(win, shots, "simple")
+	defer func() {
+		if err := p.Close(); err != nil {
+			log.Printf("could not close window: %v", err)
 		}
-	}
+	}()
 
-	if err := tex.Update(nil, unsafe.Pointer(&pixels[0]), winWidth*3); err != nil {
-		log.Fatalf("texture update failed: %v", err)
-	}
-	if err := renderer.Copy(tex, nil, nil); err != nil {
-		log.Fatalf("renderer copy failed: %v", err)
+	fill := func(fb *framebuffer.Buffer) {
+		fb.Fill(framebuffer.RGB(255, 0, 0))
 	}
-	renderer.Present()
-
-	running := true
-	for running {
-		for ev := sdl.PollEvent(); ev != nil; ev = sdl.PollEvent() {
-			switch e := ev.(type) {
-			case *sdl.QuitEvent:
-				running = false
-			case *sdl.KeyboardEvent:
-				if e.Type == sdl.KEYDOWN && e.Keysym.Sym == sdl.K_ESCAPE {
-					running = false
-				}
-			}
-		}
-		sdl.Delay(16)
+	if err := platform.ShowResizable(p, framebuffer.RGB24, fill); err != nil {
+		log.Fatalf("could not show frame: %v", err)
 	}
 }